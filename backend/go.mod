@@ -23,5 +23,8 @@ require (
 	github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms v1.0.752
 	github.com/gin-contrib/cors v1.4.0
 	github.com/gin-contrib/sessions v0.0.5
+	github.com/oschwald/geoip2-golang v1.9.0
+	github.com/nyaruka/phonenumbers v1.1.7
 	gopkg.in/gomail.v2 v2.0.0-20160411212932-81ebce5c23df
+	github.com/alicebob/miniredis/v2 v2.37.0
 )
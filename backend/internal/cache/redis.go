@@ -49,11 +49,21 @@ func Exists(key string) (bool, error) {
 	return result > 0, err
 }
 
+// TTL 获取剩余过期时间
+func TTL(key string) (time.Duration, error) {
+	return RDB.TTL(ctx, key).Result()
+}
+
 // Incr 递增
 func Incr(key string) (int64, error) {
 	return RDB.Incr(ctx, key).Result()
 }
 
+// Decr 递减计数
+func Decr(key string) (int64, error) {
+	return RDB.Decr(ctx, key).Result()
+}
+
 // Expire 设置过期时间
 func Expire(key string, expiration time.Duration) error {
 	return RDB.Expire(ctx, key, expiration).Err()
@@ -126,3 +136,50 @@ func SMembers(key string) ([]string, error) {
 func SRem(key string, members ...interface{}) error {
 	return RDB.SRem(ctx, key, members...).Err()
 }
+
+// Publish 发布消息
+func Publish(channel string, message interface{}) error {
+	return RDB.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe 订阅频道
+func Subscribe(channels ...string) *redis.PubSub {
+	return RDB.Subscribe(ctx, channels...)
+}
+
+// GetDel 获取并删除缓存（原子操作）
+func GetDel(key string) (string, error) {
+	return RDB.GetDel(ctx, key).Result()
+}
+
+// reserveCountersScript 先检查全部计数，任一达到上限时返回其序号（从1开始）且不累加；
+// 全部未达上限时逐个累加，新建的计数设置过期时间，返回0
+var reserveCountersScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	if tonumber(redis.call('GET', key) or '0') >= tonumber(ARGV[i + 1]) then
+		return i
+	end
+end
+for _, key in ipairs(KEYS) do
+	if redis.call('INCR', key) == 1 then
+		redis.call('PEXPIRE', key, ARGV[1])
+	end
+end
+return 0
+`)
+
+// ReserveCounters 原子地检查并累加多个计数，limits为对应计数的上限。
+// 返回达到上限的计数下标，全部未达上限并已累加时返回-1
+func ReserveCounters(keys []string, limits []int, window time.Duration) (int, error) {
+	args := make([]interface{}, 0, len(limits)+1)
+	args = append(args, window.Milliseconds())
+	for _, limit := range limits {
+		args = append(args, limit)
+	}
+	
+	index, err := reserveCountersScript.Run(ctx, RDB, keys, args...).Int()
+	if err != nil {
+		return 0, err
+	}
+	return index - 1, nil
+}
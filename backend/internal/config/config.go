@@ -6,20 +6,32 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	SMTP     SMTPConfig     `mapstructure:"smtp"`
-	SMS      SMSConfig      `mapstructure:"sms"`
-	Security SecurityConfig `mapstructure:"security"`
-	Upload   UploadConfig   `mapstructure:"upload"`
-	Log      LogConfig      `mapstructure:"log"`
+	Server       ServerConfig       `mapstructure:"server"`
+	Database     DatabaseConfig     `mapstructure:"database"`
+	Redis        RedisConfig        `mapstructure:"redis"`
+	JWT          JWTConfig          `mapstructure:"jwt"`
+	Session      SessionConfig      `mapstructure:"session"`
+	SMTP         SMTPConfig         `mapstructure:"smtp"`
+	SMS          SMSConfig          `mapstructure:"sms"`
+	Phone        PhoneConfig        `mapstructure:"phone"`
+	Security     SecurityConfig     `mapstructure:"security"`
+	GeoIP        GeoIPConfig        `mapstructure:"geoip"`
+	Captcha      CaptchaConfig      `mapstructure:"captcha"`
+	Verification VerificationConfig `mapstructure:"verification"`
+	Templates    TemplateConfig     `mapstructure:"templates"`
+	Outbound     OutboundConfig     `mapstructure:"outbound"`
+	Upload       UploadConfig       `mapstructure:"upload"`
+	Log          LogConfig          `mapstructure:"log"`
 }
 
 type ServerConfig struct {
 	Port string `mapstructure:"port"`
 	Mode string `mapstructure:"mode"`
+	// 受信任的反向代理（IP或CIDR），只有来自这些地址的请求才按RemoteIPHeaders解析客户端IP，
+	// 为空时不信任任何代理，直接使用连接的对端地址。部署在负载均衡或nginx之后时需列出其地址，
+	// 不要填写整个内网网段，否则内网中的任何主机都能伪造X-Forwarded-For绕过IP访问规则
+	TrustedProxies  []string `mapstructure:"trusted_proxies"`
+	RemoteIPHeaders []string `mapstructure:"remote_ip_headers"`
 }
 
 type DatabaseConfig struct {
@@ -40,34 +52,236 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	Secret  string        `mapstructure:"secret"`
-	Expires time.Duration `mapstructure:"expires"`
+	Secret              string        `mapstructure:"secret"`
+	Expires             time.Duration `mapstructure:"expires"`
+	ServiceTokenExpires time.Duration `mapstructure:"service_token_expires"`
+	// 密码过期或被要求修改密码时签发的受限Token有效期
+	PasswordChangeTokenExpires time.Duration `mapstructure:"password_change_token_expires"`
 }
 
+// SessionConfig 浏览器Cookie会话配置，会话有效期与JWT一致
+type SessionConfig struct {
+	CookieName     string `mapstructure:"cookie_name"`
+	Secret         string `mapstructure:"secret"`
+	Domain         string `mapstructure:"domain"`
+	Secure         bool   `mapstructure:"secure"`
+	SameSite       string `mapstructure:"same_site"` // lax, strict, none
+	CSRFCookieName string `mapstructure:"csrf_cookie_name"`
+	CSRFHeaderName string `mapstructure:"csrf_header_name"`
+}
+
+// SMTPConfig 邮件发送配置，Transport为maildir或memory时不连接邮件服务器
 type SMTPConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
+	Transport   string        `mapstructure:"transport"` // smtp, maildir, memory
+	Host        string        `mapstructure:"host"`
+	Port        int           `mapstructure:"port"`
+	Username    string        `mapstructure:"username"`
+	Password    string        `mapstructure:"password"`
+	Encryption  string        `mapstructure:"encryption"`   // auto（465端口使用TLS，其他端口服务器支持时使用STARTTLS）, starttls, tls, none
+	PoolSize    int           `mapstructure:"pool_size"`    // 保留的空闲连接数
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"` // 空闲连接超过该时间后不再复用
+	FromName    string        `mapstructure:"from_name"`
+	FromAddress string        `mapstructure:"from_address"` // 为空时使用Username
+	ReplyTo     string        `mapstructure:"reply_to"`
+	MaildirPath string        `mapstructure:"maildir_path"` // maildir方式下邮件保存的目录
 }
 
+// SMSConfig 短信服务商配置，Provider为空且未配置腾讯云时不启用短信
 type SMSConfig struct {
-	Tencent TencentSMSConfig `mapstructure:"tencent"`
+	Provider  string           `mapstructure:"provider"`  // 主服务商：tencent, aliyun, twilio, file
+	Fallbacks []string         `mapstructure:"fallbacks"` // 主服务商发送失败时依次尝试的备用服务商
+	Tencent   TencentSMSConfig `mapstructure:"tencent"`
+	Aliyun    AliyunSMSConfig  `mapstructure:"aliyun"`
+	Twilio    TwilioSMSConfig  `mapstructure:"twilio"`
+	File      FileSMSConfig    `mapstructure:"file"`
+	
+	// 按号码所属地区（ISO 3166-1代码，如CN、US）指定服务商及尝试顺序，未配置的地区使用Provider和Fallbacks
+	Routes map[string][]string `mapstructure:"routes"`
 }
 
+// TencentSMSConfig 腾讯云短信，Templates按消息类型（verification、notification、login_alert）配置模板ID，
+// 其他语言的模板以消息类型加语言代码为键，如verification_en
 type TencentSMSConfig struct {
-	SecretID   string `mapstructure:"secret_id"`
-	SecretKey  string `mapstructure:"secret_key"`
-	AppID      string `mapstructure:"app_id"`
-	SignName   string `mapstructure:"sign_name"`
-	TemplateID string `mapstructure:"template_id"`
+	SecretID   string            `mapstructure:"secret_id"`
+	SecretKey  string            `mapstructure:"secret_key"`
+	Region     string            `mapstructure:"region"`
+	AppID      string            `mapstructure:"app_id"`
+	SignName   string            `mapstructure:"sign_name"`
+	TemplateID string            `mapstructure:"template_id"` // 验证码模板，Templates中未配置verification时使用
+	Templates  map[string]string `mapstructure:"templates"`
+	VoiceAppID string            `mapstructure:"voice_app_id"` // 语音验证码应用ID，为空时不支持语音验证码
+}
+
+// AliyunSMSConfig 阿里云短信，Templates按消息类型配置模板CODE，其他语言的模板键与腾讯云相同
+type AliyunSMSConfig struct {
+	AccessKeyID     string            `mapstructure:"access_key_id"`
+	AccessKeySecret string            `mapstructure:"access_key_secret"`
+	RegionID        string            `mapstructure:"region_id"`
+	Endpoint        string            `mapstructure:"endpoint"`
+	SignName        string            `mapstructure:"sign_name"`
+	Templates       map[string]string `mapstructure:"templates"`
+}
+
+// TwilioSMSConfig Twilio不使用模板，From和MessagingServiceSID二选一
+type TwilioSMSConfig struct {
+	AccountSID          string `mapstructure:"account_sid"`
+	AuthToken           string `mapstructure:"auth_token"`
+	From                string `mapstructure:"from"`
+	MessagingServiceSID string `mapstructure:"messaging_service_sid"`
+}
+
+// FileSMSConfig 开发测试用，短信写入Path，Path为空时输出到标准输出
+type FileSMSConfig struct {
+	Path string `mapstructure:"path"`
+}
+
+// PhoneConfig 手机号解析规则，号码统一以E.164格式保存
+type PhoneConfig struct {
+	DefaultRegion  string   `mapstructure:"default_region"`  // 未带国家码的号码按该地区解析
+	AllowedRegions []string `mapstructure:"allowed_regions"` // 允许注册和绑定的地区，为空时不限制
+	MobileOnly     bool     `mapstructure:"mobile_only"`     // 只接受手机号，拒绝固定电话等无法接收短信的号码
+}
+
+// TemplateConfig 邮件和短信内容模板，Dir中的同名文件覆盖内置模板
+type TemplateConfig struct {
+	Dir           string `mapstructure:"dir"`
+	DefaultLocale string `mapstructure:"default_locale"` // 用户未设置语言或没有对应语言的模板时使用
+}
+
+// OutboundConfig 邮件和短信发送队列，未启用时在请求中同步发送
+type OutboundConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Workers      int           `mapstructure:"workers"`
+	MaxAttempts  int           `mapstructure:"max_attempts"`  // 达到最大发送次数后进入死信，需管理员手动重试
+	BaseDelay    time.Duration `mapstructure:"base_delay"`    // 第一次重试前的等待时间，之后每次翻倍
+	MaxDelay     time.Duration `mapstructure:"max_delay"`     // 重试等待时间上限
+	PollInterval time.Duration `mapstructure:"poll_interval"` // 队列为空时检查新消息的间隔，用于接收其他实例加入的消息
+	Lease        time.Duration `mapstructure:"lease"`         // 取出后超过该时间仍未完成（如进程退出）的消息重新发送
 }
 
 type SecurityConfig struct {
 	MaxLoginAttempts   int           `mapstructure:"max_login_attempts"`
 	LockDuration       time.Duration `mapstructure:"lock_duration"`
 	PasswordMinLength  int           `mapstructure:"password_min_length"`
+	ReauthWindow       time.Duration `mapstructure:"reauth_window"` // 敏感操作要求的最近验证时间窗口
 	RateLimit          RateLimitConfig `mapstructure:"rate_limit"`
+	LoginProtection    LoginProtectionConfig `mapstructure:"login_protection"`
+	
+	// 密码策略，角色策略与默认策略合并后取更严格的要求
+	PasswordPolicy       PasswordPolicyConfig            `mapstructure:"password_policy"`
+	RolePasswordPolicies map[string]PasswordPolicyConfig `mapstructure:"role_password_policies"`
+	CommonPasswordsFile  string                          `mapstructure:"common_passwords_file"` // 追加的常见弱密码列表，每行一个
+	
+	// 密码历史与有效期，PasswordMaxAge为0时不限制有效期
+	PasswordHistorySize    int           `mapstructure:"password_history_size"`
+	PasswordMaxAge         time.Duration `mapstructure:"password_max_age"`
+	PasswordExpiryReminder time.Duration `mapstructure:"password_expiry_reminder"` // 到期前多久发送提醒邮件
+	
+	BreachedPasswords BreachedPasswordConfig `mapstructure:"breached_passwords"`
+	LoginRisk         LoginRiskConfig        `mapstructure:"login_risk"`
+	
+	// 管理员发送的密码重置链接地址，链接中附带token参数
+	PasswordResetURL string `mapstructure:"password_reset_url"`
+	
+	// 新密码使用的argon2id参数，参数变化后已有哈希在用户下次登录时升级
+	Argon2 Argon2Config `mapstructure:"argon2"`
+}
+
+type Argon2Config struct {
+	Time    uint32 `mapstructure:"time"`
+	Memory  uint32 `mapstructure:"memory"` // KiB
+	Threads uint8  `mapstructure:"threads"`
+	KeyLen  uint32 `mapstructure:"key_len"`
+}
+
+// BreachedPasswordConfig 已泄露密码检查，数据为Have I Been Pwned格式的本地文件
+type BreachedPasswordConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Path        string `mapstructure:"path"`         // 按前缀分桶的目录，或按哈希排序的单个文件
+	MinCount    int    `mapstructure:"min_count"`    // 出现次数达到该值才视为已泄露
+	FlagOnLogin bool   `mapstructure:"flag_on_login"` // 登录时检查现有密码并标记
+}
+
+// LoginRiskConfig 登录风险检测：新设备、新的IP网段、不可能的移动速度（需配置IP地理位置库）
+type LoginRiskConfig struct {
+	Enabled             bool     `mapstructure:"enabled"`
+	NotifyEmail         bool     `mapstructure:"notify_email"`
+	NotifySMS           bool     `mapstructure:"notify_sms"`
+	RequireSecondFactor bool     `mapstructure:"require_second_factor"` // 风险登录需完成二次验证
+	MaxTravelSpeed      float64  `mapstructure:"max_travel_speed"`      // 两次登录之间允许的最大移动速度（公里/小时）
+	AllowedCountries    []string `mapstructure:"allowed_countries"`     // 允许登录的国家代码（ISO 3166-1），为空不限制，其他国家的登录视为风险登录
+	ReportURL           string   `mapstructure:"report_url"`            // “不是我本人”链接指向的前端页面
+}
+
+type PasswordPolicyConfig struct {
+	MinLength            int  `mapstructure:"min_length" json:"min_length"`
+	MaxLength            int  `mapstructure:"max_length" json:"max_length"`
+	RequireUppercase     bool `mapstructure:"require_uppercase" json:"require_uppercase"`
+	RequireLowercase     bool `mapstructure:"require_lowercase" json:"require_lowercase"`
+	RequireDigit         bool `mapstructure:"require_digit" json:"require_digit"`
+	RequireSymbol        bool `mapstructure:"require_symbol" json:"require_symbol"`
+	MinCharClasses       int  `mapstructure:"min_char_classes" json:"min_char_classes"` // 大写、小写、数字、符号中至少包含的种类数
+	DisallowPersonalInfo bool `mapstructure:"disallow_personal_info" json:"disallow_personal_info"` // 禁止包含用户名、邮箱、手机号
+	BlockCommon          bool `mapstructure:"block_common" json:"block_common"`
+}
+
+// LoginProtectionConfig 登录暴力破解防护：账号连续失败DelayAfter次后开始渐进延迟，
+// 达到MaxLoginAttempts次后锁定LockDuration；同一IP在IPWindow内失败IPMaxFailures次后封禁IPBlockDuration
+type LoginProtectionConfig struct {
+	DelayAfter      int           `mapstructure:"delay_after"`
+	BaseDelay       time.Duration `mapstructure:"base_delay"`
+	MaxDelay        time.Duration `mapstructure:"max_delay"`
+	IPMaxFailures   int           `mapstructure:"ip_max_failures"`
+	IPWindow        time.Duration `mapstructure:"ip_window"`
+	IPBlockDuration time.Duration `mapstructure:"ip_block_duration"`
+	CaptchaAfter    int           `mapstructure:"captcha_after"` // 登录名或IP失败达到该次数后登录需要图形验证码，0表示始终需要，小于0不要求
+}
+
+// GeoIPConfig 本地MaxMind格式（GeoLite2/GeoIP2）数据库，用于解析登录和操作日志IP的地理位置
+type GeoIPConfig struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	CityDatabase string `mapstructure:"city_database"` // GeoLite2-City.mmdb
+	ASNDatabase  string `mapstructure:"asn_database"`  // GeoLite2-ASN.mmdb
+	Language     string `mapstructure:"language"`      // 地名语言，如zh-CN、en
+}
+
+// CaptchaConfig 图形验证码配置，多实例部署时必须使用redis存储
+type CaptchaConfig struct {
+	Store           string        `mapstructure:"store"` // redis, memory
+	Expiration      time.Duration `mapstructure:"expiration"`
+	Driver          string        `mapstructure:"driver"` // 默认类型：digit, string, math, audio, slider
+	Width           int           `mapstructure:"width"`
+	Height          int           `mapstructure:"height"`
+	Length          int           `mapstructure:"length"`
+	AudioLanguage   string        `mapstructure:"audio_language"`   // en, ja, ru, zh
+	AllowedDrivers  []string      `mapstructure:"allowed_drivers"`  // 除默认类型外客户端可通过type参数选择的类型，如为视障用户提供audio
+	SliderTolerance int           `mapstructure:"slider_tolerance"` // 滑块验证码允许的横坐标误差（像素），越大越容易被随机猜中
+	
+	// 邮箱/短信验证码以HMAC-SHA256摘要保存，CodeSecret为空时使用JWT密钥
+	CodeSecret      string `mapstructure:"code_secret"`
+	CodeMaxAttempts int    `mapstructure:"code_max_attempts"` // 每个验证码允许的错误次数，达到后验证码作废
+}
+
+// VerificationConfig 邮箱/短信验证码，按用途覆盖默认的有效期、长度、发送间隔和每日上限
+type VerificationConfig struct {
+	RequireCaptcha bool                                 `mapstructure:"require_captcha"` // 未登录发送验证码前需通过图形验证码
+	Limits         map[string]VerificationLimitConfig   `mapstructure:"limits"`          // 按接收方类型（email、phone）的每日发送上限
+	Purposes       map[string]VerificationPurposeConfig `mapstructure:"purposes"`
+}
+
+// VerificationLimitConfig 每日发送上限，0表示不限制
+type VerificationLimitConfig struct {
+	PerIP     int `mapstructure:"per_ip"`
+	PerTarget int `mapstructure:"per_target"`
+	Global    int `mapstructure:"global"`
+}
+
+// VerificationPurposeConfig 单个用途的验证码配置，为零值的项使用默认值
+type VerificationPurposeConfig struct {
+	TTL        time.Duration `mapstructure:"ttl"`
+	Length     int           `mapstructure:"length"`
+	Cooldown   time.Duration `mapstructure:"cooldown"`
+	DailyQuota int           `mapstructure:"daily_quota"` // 每个接收方每天的发送上限
 }
 
 type RateLimitConfig struct {
@@ -114,6 +328,8 @@ func LoadConfig(configPath string) (*Config, error) {
 func setDefaults() {
 	viper.SetDefault("server.port", "8080")
 	viper.SetDefault("server.mode", "debug")
+	viper.SetDefault("server.trusted_proxies", []string{})
+	viper.SetDefault("server.remote_ip_headers", []string{"X-Forwarded-For", "X-Real-IP"})
 	
 	viper.SetDefault("database.host", "localhost")
 	viper.SetDefault("database.port", 5432)
@@ -125,13 +341,108 @@ func setDefaults() {
 	viper.SetDefault("redis.db", 0)
 	
 	viper.SetDefault("jwt.expires", "24h")
+	viper.SetDefault("jwt.service_token_expires", "1h")
+	viper.SetDefault("jwt.password_change_token_expires", "15m")
+	
+	viper.SetDefault("session.cookie_name", "uc_session")
+	viper.SetDefault("session.secure", true)
+	viper.SetDefault("session.same_site", "strict")
+	viper.SetDefault("session.csrf_cookie_name", "uc_csrf")
+	viper.SetDefault("session.csrf_header_name", "X-CSRF-Token")
+	
+	viper.SetDefault("smtp.transport", "smtp")
+	viper.SetDefault("smtp.encryption", "auto")
+	viper.SetDefault("smtp.pool_size", 4)
+	viper.SetDefault("smtp.idle_timeout", "30s")
+	viper.SetDefault("smtp.from_name", "用户中心")
+	viper.SetDefault("smtp.maildir_path", "./mail")
+	
+	viper.SetDefault("templates.default_locale", "zh-CN")
+	
+	viper.SetDefault("outbound.enabled", true)
+	viper.SetDefault("outbound.workers", 4)
+	viper.SetDefault("outbound.max_attempts", 6)
+	viper.SetDefault("outbound.base_delay", "10s")
+	viper.SetDefault("outbound.max_delay", "30m")
+	viper.SetDefault("outbound.poll_interval", "1s")
+	viper.SetDefault("outbound.lease", "5m")
 	
 	viper.SetDefault("security.max_login_attempts", 5)
 	viper.SetDefault("security.lock_duration", "30m")
 	viper.SetDefault("security.password_min_length", 8)
+	viper.SetDefault("security.reauth_window", "5m")
+	viper.SetDefault("security.password_history_size", 5)
+	viper.SetDefault("security.password_max_age", "0")
+	viper.SetDefault("security.password_expiry_reminder", "168h")
+	viper.SetDefault("security.breached_passwords.min_count", 1)
+	viper.SetDefault("security.breached_passwords.flag_on_login", true)
+	viper.SetDefault("security.login_risk.enabled", true)
+	viper.SetDefault("security.login_risk.notify_email", true)
+	viper.SetDefault("security.login_risk.notify_sms", false)
+	viper.SetDefault("security.login_risk.require_second_factor", false)
+	viper.SetDefault("security.login_risk.max_travel_speed", 900)
+	viper.SetDefault("security.login_risk.report_url", "http://localhost:3000/login-alert")
+	viper.SetDefault("security.password_reset_url", "http://localhost:3000/reset-password")
+	viper.SetDefault("security.argon2.time", 3)
+	viper.SetDefault("security.argon2.memory", 64*1024)
+	viper.SetDefault("security.argon2.threads", 4)
+	viper.SetDefault("security.argon2.key_len", 32)
+	viper.SetDefault("security.password_policy.max_length", 128)
+	viper.SetDefault("security.password_policy.min_char_classes", 2)
+	viper.SetDefault("security.password_policy.disallow_personal_info", true)
+	viper.SetDefault("security.password_policy.block_common", true)
+	viper.SetDefault("security.role_password_policies", map[string]interface{}{
+		"admin": map[string]interface{}{
+			"min_length":       12,
+			"min_char_classes": 3,
+		},
+		"super_admin": map[string]interface{}{
+			"min_length":       14,
+			"min_char_classes": 4,
+		},
+	})
+	viper.SetDefault("security.login_protection.delay_after", 3)
+	viper.SetDefault("security.login_protection.base_delay", "2s")
+	viper.SetDefault("security.login_protection.max_delay", "1m")
+	viper.SetDefault("security.login_protection.ip_max_failures", 20)
+	viper.SetDefault("security.login_protection.ip_window", "15m")
+	viper.SetDefault("security.login_protection.ip_block_duration", "30m")
+	viper.SetDefault("security.login_protection.captcha_after", 2)
 	viper.SetDefault("security.rate_limit.requests_per_minute", 60)
 	viper.SetDefault("security.rate_limit.burst", 10)
 	
+	viper.SetDefault("phone.default_region", "CN")
+	viper.SetDefault("phone.mobile_only", true)
+	
+	viper.SetDefault("geoip.enabled", false)
+	viper.SetDefault("geoip.language", "zh-CN")
+	
+	viper.SetDefault("captcha.store", "redis")
+	viper.SetDefault("captcha.expiration", "5m")
+	viper.SetDefault("captcha.driver", "digit")
+	viper.SetDefault("captcha.width", 240)
+	viper.SetDefault("captcha.height", 80)
+	viper.SetDefault("captcha.length", 4)
+	viper.SetDefault("captcha.audio_language", "zh")
+	viper.SetDefault("captcha.allowed_drivers", []string{})
+	viper.SetDefault("captcha.slider_tolerance", 5)
+	viper.SetDefault("captcha.code_max_attempts", 5)
+	
+	// 验证码发送限制，短信按条计费，上限比邮件低
+	viper.SetDefault("verification.require_captcha", true)
+	viper.SetDefault("verification.limits", map[string]interface{}{
+		"phone": map[string]interface{}{
+			"per_ip":     20,
+			"per_target": 10,
+			"global":     10000,
+		},
+		"email": map[string]interface{}{
+			"per_ip":     50,
+			"per_target": 20,
+			"global":     50000,
+		},
+	})
+	
 	viper.SetDefault("upload.max_size", "10MB")
 	viper.SetDefault("upload.path", "./uploads")
 	
@@ -46,9 +46,16 @@ func autoMigrate() error {
 		&models.UserDevice{},
 		&models.UserLog{},
 		&models.VerificationCode{},
+		&models.VerificationBlock{},
+		&models.SMSDelivery{},
+		&models.OutboundMessage{},
 		&models.SystemNotification{},
 		&models.UserNotification{},
 		&models.DataBackup{},
+		&models.ServiceAccount{},
+		&models.PasswordHistory{},
+		&models.IPRule{},
+		&models.LoginLog{},
 	)
 }
 
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
 	
 	"usercenter/internal/middleware"
 	"usercenter/internal/service"
@@ -12,12 +11,14 @@ import (
 )
 
 type AdminHandler struct {
-	userService *service.UserService
+	userService     *service.UserService
+	loginLogService *service.LoginLogService
 }
 
 func NewAdminHandler() *AdminHandler {
 	return &AdminHandler{
-		userService: service.NewUserService(),
+		userService:     service.NewUserService(),
+		loginLogService: service.NewLoginLogService(),
 	}
 }
 
@@ -33,6 +34,7 @@ func NewAdminHandler() *AdminHandler {
 // @Param keyword query string false "搜索关键词"
 // @Param status query int false "用户状态"
 // @Param role_code query string false "角色代码"
+// @Param country query string false "最后登录国家代码（ISO 3166-1）"
 // @Success 200 {object} map[string]interface{} "用户列表"
 // @Router /admin/users [get]
 func (h *AdminHandler) GetUsers(c *gin.Context) {
@@ -109,6 +111,43 @@ func (h *AdminHandler) CreateUser(c *gin.Context) {
 	})
 }
 
+// ImportUsers 导入旧系统用户
+// @Summary 导入旧系统用户
+// @Description 批量导入旧系统用户及其密码哈希，支持argon2id、bcrypt（$2a$/$2b$/$2y$）和加盐MD5（$md5$<salt>$<hex>）格式，
+// @Description 用户首次登录成功后密码哈希自动升级为当前的argon2id参数
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body service.ImportUsersRequest true "导入的用户"
+// @Success 200 {object} map[string]interface{} "导入结果"
+// @Router /admin/users/import [post]
+func (h *AdminHandler) ImportUsers(c *gin.Context) {
+	var req service.ImportUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	result, err := h.userService.AdminImportUsers(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "导入用户失败",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": result,
+		"message": "导入完成",
+	})
+}
+
 // GetUser 获取用户详情
 // @Summary 获取用户详情
 // @Description 管理员获取指定用户的详细信息
@@ -308,13 +347,15 @@ func (h *AdminHandler) UpdateUserStatus(c *gin.Context) {
 
 // ResetUserPassword 重置用户密码
 // @Summary 重置用户密码
-// @Description 管理员重置用户密码
+// @Description 管理员重置用户密码。mode=temporary（默认）生成随机临时密码并在响应中返回；
+// @Description mode=link向用户邮箱发送一次性重置链接；mode=manual使用管理员指定的new_password。
+// @Description 临时密码和指定密码默认要求用户下次登录时修改
 // @Tags 管理员
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
 // @Param id path string true "用户ID"
-// @Param request body map[string]string true "新密码"
+// @Param request body service.AdminResetPasswordRequest false "重置方式"
 // @Success 200 {object} map[string]interface{} "重置结果"
 // @Router /admin/users/{id}/reset-password [put]
 func (h *AdminHandler) ResetUserPassword(c *gin.Context) {
@@ -328,10 +369,56 @@ func (h *AdminHandler) ResetUserPassword(c *gin.Context) {
 		return
 	}
 	
-	var req struct {
-		NewPassword string `json:"new_password" binding:"required,min=8"`
+	// 请求体可为空，此时生成临时密码
+	var req service.AdminResetPasswordRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "参数错误: " + err.Error(),
+			})
+			return
+		}
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
+	
+	result, err := h.userService.AdminResetPassword(userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": result,
+		"message": "密码重置成功",
+	})
+}
+
+// GetLoginLogs 获取登录记录
+// @Summary 获取登录记录
+// @Description 管理员查询所有用户的登录记录
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param user_id query string false "用户ID"
+// @Param username query string false "登录账号"
+// @Param status query int false "状态（1成功 2失败 3待二次验证）"
+// @Param method query string false "登录方式"
+// @Param ip query string false "IP"
+// @Param country query string false "国家代码（ISO 3166-1）"
+// @Param start_date query string false "开始日期（2006-01-02）"
+// @Param end_date query string false "结束日期（2006-01-02）"
+// @Success 200 {object} map[string]interface{} "登录记录"
+// @Router /admin/login-logs [get]
+func (h *AdminHandler) GetLoginLogs(c *gin.Context) {
+	var query service.LoginLogQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
 			"message": "参数错误: " + err.Error(),
@@ -339,18 +426,30 @@ func (h *AdminHandler) ResetUserPassword(c *gin.Context) {
 		return
 	}
 	
-	err = h.userService.AdminResetUserPassword(userID, req.NewPassword)
+	// 设置默认值
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = 10
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+	
+	result, err := h.loginLogService.List(&query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "重置密码失败",
+			"message": "获取登录记录失败",
 		})
 		return
 	}
 	
 	c.JSON(http.StatusOK, gin.H{
-		"code":    200,
-		"message": "密码重置成功",
+		"code": 200,
+		"data": result,
+		"message": "获取登录记录成功",
 	})
 }
 
@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	
 	"usercenter/internal/middleware"
 	"usercenter/internal/service"
@@ -11,26 +13,43 @@ import (
 )
 
 type AuthHandler struct {
-	authService *service.AuthService
+	authService           *service.AuthService
+	permissionService     *service.PermissionService
+	passwordPolicyService *service.PasswordPolicyService
 }
 
 func NewAuthHandler() *AuthHandler {
 	return &AuthHandler{
-		authService: service.NewAuthService(),
+		authService:           service.NewAuthService(),
+		permissionService:     service.NewPermissionService(),
+		passwordPolicyService: service.NewPasswordPolicyService(),
 	}
 }
 
+// CodeCaptchaRequired 登录需要图形验证码或验证码错误的业务错误码，前端收到后应获取并展示验证码
+const CodeCaptchaRequired = 40303
+
 // GetCaptcha 获取图形验证码
 // @Summary 获取图形验证码
-// @Description 获取用于登录/注册的图形验证码
+// @Description 获取用于登录/注册的图形验证码，默认使用配置的类型。type只能选择配置中允许的其他类型（如audio），
+// @Description 其他类型返回400。滑块验证码（slider）另返回拼图块图片captcha_piece和纵坐标captcha_piece_y，
+// @Description 提交拼图块的横坐标作为captcha_code，由服务端按captcha.slider_tolerance校验
 // @Tags 认证
 // @Accept json
 // @Produce json
+// @Param type query string false "验证码类型"
 // @Success 200 {object} map[string]interface{} "验证码信息"
 // @Router /auth/captcha [get]
 func (h *AuthHandler) GetCaptcha(c *gin.Context) {
-	id, b64s, err := captcha.GenerateBase64Captcha()
+	challenge, err := captcha.Generate(c.Query("type"))
 	if err != nil {
+		if errors.Is(err, captcha.ErrUnsupportedDriver) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"message": "生成验证码失败",
@@ -40,29 +59,23 @@ func (h *AuthHandler) GetCaptcha(c *gin.Context) {
 	
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
-		"data": gin.H{
-			"captcha_id":  id,
-			"captcha_img": b64s,
-		},
+		"data": challenge,
 		"message": "获取验证码成功",
 	})
 }
 
-// SendEmailCode 发送邮箱验证码
-// @Summary 发送邮箱验证码
-// @Description 发送邮箱验证码用于注册、绑定等操作
+// SendVerificationCode 发送验证码
+// @Summary 发送验证码
+// @Description 向邮箱或手机号发送验证码，用于注册、找回密码、绑定等操作。手机号可通过channel=voice改为语音播报。
+// @Description 需要先通过图形验证码，未提交或验证码错误时返回code 40303；超过每日发送上限或接收方在黑名单中时拒绝发送
 // @Tags 认证
 // @Accept json
 // @Produce json
-// @Param request body map[string]string true "邮箱和用途"
+// @Param request body service.SendVerificationRequest true "接收方和用途"
 // @Success 200 {object} map[string]interface{} "发送结果"
-// @Router /auth/send-email-code [post]
-func (h *AuthHandler) SendEmailCode(c *gin.Context) {
-	var req struct {
-		Email   string `json:"email" binding:"required,email"`
-		Purpose string `json:"purpose" binding:"required"`
-	}
-	
+// @Router /auth/verification/send [post]
+func (h *AuthHandler) SendVerificationCode(c *gin.Context) {
+	var req service.SendVerificationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
@@ -71,8 +84,19 @@ func (h *AuthHandler) SendEmailCode(c *gin.Context) {
 		return
 	}
 	
-	err := h.authService.SendEmailCode(req.Email, req.Purpose)
-	if err != nil {
+	req.IP = c.ClientIP()
+	if req.Language == "" {
+		req.Language = c.GetHeader("Accept-Language")
+	}
+	
+	if err := h.authService.SendVerificationCode(&req); err != nil {
+		if errors.Is(err, service.ErrCaptchaRequired) || errors.Is(err, service.ErrCaptchaInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    CodeCaptchaRequired,
+				"message": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
 			"message": err.Error(),
@@ -86,21 +110,17 @@ func (h *AuthHandler) SendEmailCode(c *gin.Context) {
 	})
 }
 
-// SendSMSCode 发送短信验证码
-// @Summary 发送短信验证码
-// @Description 发送短信验证码用于注册、绑定等操作
+// Register 用户注册
+// @Summary 用户注册
+// @Description 用户注册接口
 // @Tags 认证
 // @Accept json
 // @Produce json
-// @Param request body map[string]string true "手机号和用途"
-// @Success 200 {object} map[string]interface{} "发送结果"
-// @Router /auth/send-sms-code [post]
-func (h *AuthHandler) SendSMSCode(c *gin.Context) {
-	var req struct {
-		Phone   string `json:"phone" binding:"required"`
-		Purpose string `json:"purpose" binding:"required"`
-	}
-	
+// @Param request body service.RegisterRequest true "注册信息"
+// @Success 200 {object} map[string]interface{} "注册结果"
+// @Router /auth/register [post]
+func (h *AuthHandler) Register(c *gin.Context) {
+	var req service.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
@@ -108,8 +128,11 @@ func (h *AuthHandler) SendSMSCode(c *gin.Context) {
 		})
 		return
 	}
+	if req.Language == "" {
+		req.Language = c.GetHeader("Accept-Language")
+	}
 	
-	err := h.authService.SendSMSCode(req.Phone, req.Purpose)
+	err := h.authService.Register(&req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
@@ -120,21 +143,21 @@ func (h *AuthHandler) SendSMSCode(c *gin.Context) {
 	
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
-		"message": "验证码发送成功",
+		"message": "注册成功",
 	})
 }
 
-// Register 用户注册
-// @Summary 用户注册
-// @Description 用户注册接口
+// ResetPassword 重置密码
+// @Summary 重置密码
+// @Description 使用用途为reset_password的邮箱或短信验证码重置密码，新密码需满足密码策略
 // @Tags 认证
 // @Accept json
 // @Produce json
-// @Param request body service.RegisterRequest true "注册信息"
-// @Success 200 {object} map[string]interface{} "注册结果"
-// @Router /auth/register [post]
-func (h *AuthHandler) Register(c *gin.Context) {
-	var req service.RegisterRequest
+// @Param request body service.ResetPasswordRequest true "重置信息"
+// @Success 200 {object} map[string]interface{} "重置结果"
+// @Router /auth/reset-password [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req service.ResetPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
@@ -143,7 +166,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 	
-	err := h.authService.Register(&req)
+	err := h.authService.ResetPassword(&req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
@@ -154,13 +177,35 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
-		"message": "注册成功",
+		"message": "密码重置成功",
+	})
+}
+
+// GetPasswordPolicy 获取密码策略
+// @Summary 获取密码策略
+// @Description 获取指定角色适用的密码策略，便于客户端展示密码规则。未指定角色时返回普通用户的策略
+// @Tags 认证
+// @Produce json
+// @Param role query string false "角色编码，多个用逗号分隔"
+// @Success 200 {object} map[string]interface{} "密码策略"
+// @Router /auth/password-policy [get]
+func (h *AuthHandler) GetPasswordPolicy(c *gin.Context) {
+	roleCodes := splitCommaList(c.Query("role"))
+	if len(roleCodes) == 0 {
+		roleCodes = []string{"user"}
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": h.passwordPolicyService.PolicyForRoles(roleCodes),
+		"message": "获取密码策略成功",
 	})
 }
 
 // Login 用户登录
 // @Summary 用户登录
-// @Description 用户登录接口
+// @Description 用户登录接口。mode=session时写入HttpOnly会话Cookie并返回csrf_token，后续写请求需在X-CSRF-Token头中携带。
+// @Description 登录名或IP近期失败次数较多时需要图形验证码，未提交或验证码错误时返回code 40303
 // @Tags 认证
 // @Accept json
 // @Produce json
@@ -190,13 +235,54 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	
 	resp, err := h.authService.Login(&req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		if errors.Is(err, service.ErrCaptchaRequired) || errors.Is(err, service.ErrCaptchaInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    CodeCaptchaRequired,
+				"message": err.Error(),
+			})
+			return
+		}
+		
+		// 失败次数达到阈值后提示前端下次登录需要图形验证码
+		body := gin.H{
 			"code":    400,
 			"message": err.Error(),
+		}
+		if h.authService.LoginCaptchaRequired(req.Username, req.DeviceInfo.IP) {
+			body["data"] = gin.H{"captcha_required": true}
+		}
+		c.JSON(http.StatusBadRequest, body)
+		return
+	}
+	
+	// 风险登录需要二次验证，暂不签发Token
+	if resp.ChallengeRequired {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 200,
+			"data": resp,
+			"message": "检测到异常登录，请完成二次验证",
 		})
 		return
 	}
 	
+	h.respondLogin(c, req.Mode, resp)
+}
+
+// respondLogin 返回登录结果，会话模式下Token保存在服务端会话中，不返回给前端脚本
+func (h *AuthHandler) respondLogin(c *gin.Context, mode string, resp *service.LoginResponse) {
+	if mode == service.LoginModeSession {
+		csrfToken, err := middleware.StartSession(c, resp.Token)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "创建会话失败",
+			})
+			return
+		}
+		resp.Token = ""
+		resp.CSRFToken = csrfToken
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"data": resp,
@@ -204,6 +290,108 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	})
 }
 
+// VerifyLogin 完成风险登录的二次验证
+// @Summary 风险登录二次验证
+// @Description 登录返回challenge_required时，使用challenge_id和两步验证码或邮箱/短信验证码完成登录
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param request body service.LoginChallengeRequest true "二次验证信息"
+// @Success 200 {object} map[string]interface{} "登录结果"
+// @Router /auth/login/verify [post]
+func (h *AuthHandler) VerifyLogin(c *gin.Context) {
+	var req service.LoginChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	resp, err := h.authService.VerifyLoginChallenge(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	h.respondLogin(c, req.Mode, resp)
+}
+
+// SendLoginChallengeCode 发送风险登录二次验证的验证码
+// @Summary 发送风险登录验证码
+// @Description 向用户已绑定的邮箱或手机号发送风险登录二次验证的验证码
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param request body map[string]string true "challenge_id和method（email_code或sms_code）"
+// @Success 200 {object} map[string]interface{} "发送结果"
+// @Router /auth/login/challenge/send-code [post]
+func (h *AuthHandler) SendLoginChallengeCode(c *gin.Context) {
+	var req struct {
+		ChallengeID string `json:"challenge_id" binding:"required"`
+		Method      string `json:"method" binding:"required,oneof=email_code sms_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	if err := h.authService.SendLoginChallengeCode(req.ChallengeID, req.Method); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "验证码发送成功",
+	})
+}
+
+// DenyLogin 确认登录不是本人操作
+// @Summary 不是我本人登录
+// @Description 使用登录提醒中的链接令牌注销该用户的全部登录（包括已刷新的Token和其他设备）、停用对应设备，并使当前密码失效，重置链接将发送到用户邮箱
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param request body map[string]string true "登录提醒中的token"
+// @Success 200 {object} map[string]interface{} "处理结果"
+// @Router /auth/login-alert/deny [post]
+func (h *AuthHandler) DenyLogin(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	if err := h.authService.DenyLogin(req.Token); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "已注销全部登录，请通过邮件中的链接重置密码",
+	})
+}
+
 // Logout 用户登出
 // @Summary 用户登出
 // @Description 用户登出接口
@@ -226,6 +414,10 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		return
 	}
 	
+	if middleware.IsSessionAuth(c) {
+		middleware.EndSession(c)
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "登出成功",
@@ -253,6 +445,22 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 	
+	// 会话模式下只更新会话中的Token
+	if middleware.IsSessionAuth(c) {
+		if err := middleware.UpdateSessionToken(c, newToken); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "Token刷新失败",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"code":    200,
+			"message": "Token刷新成功",
+		})
+		return
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"data": gin.H{
@@ -262,6 +470,100 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	})
 }
 
+// Reauth 重新验证身份
+// @Summary 重新验证身份
+// @Description 执行修改密码、绑定邮箱/手机、删除设备及管理员操作等敏感操作前需重新验证身份，
+// @Description 验证成功后当前Token在配置的时间窗口（security.reauth_window）内可执行敏感操作。
+// @Description 敏感接口在未验证时返回code 40301
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body service.ReauthRequest true "验证信息"
+// @Success 200 {object} map[string]interface{} "验证结果"
+// @Router /auth/reauth [post]
+func (h *AuthHandler) Reauth(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+	
+	var req service.ReauthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	expiresAt, err := h.authService.Reauthenticate(userID, middleware.GetTokenID(c), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"expires_at": expiresAt.Unix(),
+		},
+		"message": "身份验证成功",
+	})
+}
+
+// SendReauthCode 发送重新验证身份的验证码
+// @Summary 发送重新验证身份的验证码
+// @Description 向当前用户已绑定的邮箱或手机号发送验证码
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body object true "验证方式（email_code或sms_code）"
+// @Success 200 {object} map[string]interface{} "发送结果"
+// @Router /auth/reauth/send-code [post]
+func (h *AuthHandler) SendReauthCode(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+	
+	var req struct {
+		Method string `json:"method" binding:"required,oneof=email_code sms_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	if err := h.authService.SendReauthCode(userID, req.Method); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "验证码发送成功",
+	})
+}
+
 // GetUserInfo 获取当前用户信息
 // @Summary 获取当前用户信息
 // @Description 获取当前登录用户的基本信息
@@ -297,3 +599,121 @@ func (h *AuthHandler) GetUserInfo(c *gin.Context) {
 		"message": "获取用户信息成功",
 	})
 }
+
+// Verify 转发认证
+// @Summary 转发认证
+// @Description 供nginx auth_request和Traefik ForwardAuth调用。按AuthMiddleware相同的规则校验凭据，
+// @Description 原始请求方法通过X-Forwarded-Method或X-Original-Method传入，用于会话请求的CSRF校验。
+// @Description 可在代理配置的认证地址中通过role/permission查询参数（逗号分隔）要求角色或权限：
+// @Description 角色满足其一即可，权限需全部满足。要求只从认证地址读取，客户端请求头无法修改。
+// @Description 成功时返回200并通过X-User-Id、X-Username、X-Roles响应头传递主体信息
+// @Tags 认证
+// @Produce json
+// @Param role query string false "要求的角色"
+// @Param permission query string false "要求的权限"
+// @Success 200 "认证通过"
+// @Failure 401 {object} map[string]interface{} "未认证"
+// @Failure 403 {object} map[string]interface{} "权限不足"
+// @Router /auth/verify [get]
+func (h *AuthHandler) Verify(c *gin.Context) {
+	claims, message := middleware.Authenticate(c)
+	if claims == nil {
+		c.Header("WWW-Authenticate", `Bearer realm="usercenter"`)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": message,
+		})
+		return
+	}
+	
+	// 会话Cookie认证的写请求同样需要CSRF校验，原始请求方法由代理通过请求头传递
+	method := c.GetHeader("X-Forwarded-Method")
+	if method == "" {
+		method = c.GetHeader("X-Original-Method")
+	}
+	if method == "" {
+		method = c.Request.Method
+	}
+	if !middleware.VerifyCSRF(c, method) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    403,
+			"message": "CSRF校验失败",
+		})
+		return
+	}
+	
+	// 必须先修改密码的用户不能访问其他服务
+	if middleware.RequiresPasswordChange(claims) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    middleware.CodePasswordChangeRequired,
+			"message": "请先修改密码",
+		})
+		return
+	}
+	
+	roles := claims.RoleCodes()
+	
+	// 角色要求：满足其一即可
+	if requiredRoles := forwardAuthRequirement(c, "role"); len(requiredRoles) > 0 {
+		if !containsAny(roles, requiredRoles) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "权限不足",
+			})
+			return
+		}
+	}
+	
+	// 权限要求：需全部满足
+	for _, permission := range forwardAuthRequirement(c, "permission") {
+		allowed, err := h.permissionService.HasPermission(roles, permission)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "权限校验失败",
+			})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "权限不足",
+			})
+			return
+		}
+	}
+	
+	c.Header("X-User-Id", claims.UserID.String())
+	c.Header("X-Username", claims.Username)
+	c.Header("X-Roles", strings.Join(roles, ","))
+	c.Status(http.StatusOK)
+}
+
+// forwardAuthRequirement 从查询参数读取逗号分隔的要求列表。查询参数由代理配置的认证地址决定，
+// 不读取请求头，因为代理通常会把客户端的请求头原样转发过来，客户端可借此降低要求
+func forwardAuthRequirement(c *gin.Context, query string) []string {
+	return splitCommaList(c.Query(query))
+}
+
+// splitCommaList 拆分逗号分隔的列表并去除空项
+func splitCommaList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// containsAny 判断两个列表是否存在交集
+func containsAny(values, candidates []string) bool {
+	for _, value := range values {
+		for _, candidate := range candidates {
+			if value == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"net/http"
+	
+	"usercenter/internal/middleware"
+	"usercenter/internal/service"
+	
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type IPRuleHandler struct {
+	ipRuleService *service.IPRuleService
+}
+
+func NewIPRuleHandler() *IPRuleHandler {
+	return &IPRuleHandler{
+		ipRuleService: service.NewIPRuleService(),
+	}
+}
+
+// GetIPRules 获取IP规则列表
+// @Summary 获取IP规则列表
+// @Description 超级管理员获取IP访问规则列表
+// @Tags 超级管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param scope query string false "范围（global、login、admin、super_admin、user）"
+// @Param action query string false "动作（allow、deny）"
+// @Param user_id query string false "个人白名单所属用户ID"
+// @Success 200 {object} map[string]interface{} "IP规则列表"
+// @Router /super-admin/ip-rules [get]
+func (h *IPRuleHandler) GetIPRules(c *gin.Context) {
+	var query service.IPRuleListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	// 设置默认值
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = 10
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+	
+	result, err := h.ipRuleService.List(&query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取IP规则列表失败",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": result,
+		"message": "获取IP规则列表成功",
+	})
+}
+
+// CreateIPRule 创建IP规则
+// @Summary 创建IP规则
+// @Description 超级管理员创建IP访问规则。同一范围内命中拒绝规则即拒绝，存在允许规则时必须命中其中之一；
+// @Description scope=user为指定用户的个人白名单。会导致当前IP无法访问管理接口的变更将被拒绝
+// @Tags 超级管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body service.IPRuleRequest true "IP规则"
+// @Success 200 {object} map[string]interface{} "创建结果"
+// @Router /super-admin/ip-rules [post]
+func (h *IPRuleHandler) CreateIPRule(c *gin.Context) {
+	var req service.IPRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	rule, err := h.ipRuleService.Create(&req, ipRuleOperator(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": rule,
+		"message": "IP规则创建成功",
+	})
+}
+
+// UpdateIPRule 更新IP规则
+// @Summary 更新IP规则
+// @Description 超级管理员更新IP访问规则
+// @Tags 超级管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "IP规则ID"
+// @Param request body service.IPRuleRequest true "IP规则"
+// @Success 200 {object} map[string]interface{} "更新结果"
+// @Router /super-admin/ip-rules/{id} [put]
+func (h *IPRuleHandler) UpdateIPRule(c *gin.Context) {
+	ruleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "IP规则ID格式错误",
+		})
+		return
+	}
+	
+	var req service.IPRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	err = h.ipRuleService.Update(ruleID, &req, ipRuleOperator(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "IP规则更新成功",
+	})
+}
+
+// DeleteIPRule 删除IP规则
+// @Summary 删除IP规则
+// @Description 超级管理员删除IP访问规则
+// @Tags 超级管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "IP规则ID"
+// @Success 200 {object} map[string]interface{} "删除结果"
+// @Router /super-admin/ip-rules/{id} [delete]
+func (h *IPRuleHandler) DeleteIPRule(c *gin.Context) {
+	ruleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "IP规则ID格式错误",
+		})
+		return
+	}
+	
+	err = h.ipRuleService.Delete(ruleID, ipRuleOperator(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "IP规则删除成功",
+	})
+}
+
+// ipRuleOperator 当前操作者的用户ID和IP
+func ipRuleOperator(c *gin.Context) *service.IPRuleOperator {
+	userID, _ := middleware.GetUserID(c)
+	return &service.IPRuleOperator{
+		UserID: userID,
+		IP:     c.ClientIP(),
+	}
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+	
+	"usercenter/internal/middleware"
+	"usercenter/internal/models"
+	"usercenter/internal/service"
+	
+	"github.com/gin-gonic/gin"
+)
+
+type OAuthHandler struct {
+	serviceAccountService *service.ServiceAccountService
+}
+
+func NewOAuthHandler() *OAuthHandler {
+	return &OAuthHandler{
+		serviceAccountService: service.NewServiceAccountService(),
+	}
+}
+
+// Token 服务账号获取访问令牌
+// @Summary 获取服务账号访问令牌
+// @Description client_credentials授权，支持client_secret（表单或Basic认证）和JWT断言两种客户端认证方式，响应格式遵循RFC 6749
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "固定为client_credentials"
+// @Param client_id formData string false "客户端ID"
+// @Param client_secret formData string false "客户端密钥"
+// @Param client_assertion_type formData string false "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+// @Param client_assertion formData string false "客户端签名的JWT断言"
+// @Param scope formData string false "申请的scope，空格分隔"
+// @Success 200 {object} service.TokenResponse "访问令牌"
+// @Router /oauth/token [post]
+func (h *OAuthHandler) Token(c *gin.Context) {
+	var req service.TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	
+	applyBasicAuth(c, &req.ClientCredentials)
+	req.IP = c.ClientIP()
+	
+	resp, err := h.serviceAccountService.IssueToken(&req)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidClient:
+			c.Header("WWW-Authenticate", `Basic realm="usercenter"`)
+			oauthError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+		case service.ErrUnsupportedGrantType:
+			oauthError(c, http.StatusBadRequest, "unsupported_grant_type", err.Error())
+		case service.ErrInvalidScope:
+			oauthError(c, http.StatusBadRequest, "invalid_scope", err.Error())
+		default:
+			oauthError(c, http.StatusInternalServerError, "server_error", "签发令牌失败")
+		}
+		return
+	}
+	
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, resp)
+}
+
+// oauthError 按RFC 6749 5.2格式返回错误
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, gin.H{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// Introspect 令牌自省
+// @Summary 令牌自省
+// @Description 按RFC 7662返回令牌的有效状态及主体信息，调用方需使用服务账号凭据认证。
+// @Description 只能用于修改密码的受限令牌和被要求修改密码的用户的令牌返回active=false。
+// @Description 设置了个人IP白名单的用户按请求来源IP判断，网关需在server.trusted_proxies中并通过X-Forwarded-For传递用户IP
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "待检查的令牌"
+// @Param token_type_hint formData string false "令牌类型提示"
+// @Success 200 {object} map[string]interface{} "自省结果"
+// @Router /oauth/introspect [post]
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	var req service.TokenOperationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	
+	if _, ok := h.authenticateClient(c, &req.ClientCredentials); !ok {
+		return
+	}
+	
+	c.Header("Cache-Control", "no-store")
+	
+	// 与AuthMiddleware使用相同的校验逻辑（签名、过期、黑名单、吊销、个人IP白名单），
+	// 必须先修改密码的Token只能访问修改密码接口，对其他服务视为无效
+	claims, _ := middleware.ValidateRequestToken(c, req.Token)
+	if claims == nil || middleware.RequiresPasswordChange(claims) {
+		c.JSON(http.StatusOK, gin.H{"active": false})
+		return
+	}
+	
+	principalType := models.PrincipalTypeUser
+	if claims.IsServiceAccount() {
+		principalType = models.PrincipalTypeServiceAccount
+	}
+	
+	resp := gin.H{
+		"active":         true,
+		"token_type":     "Bearer",
+		"sub":            claims.UserID.String(),
+		"username":       claims.Username,
+		"principal_type": principalType,
+		"roles":          claims.RoleCodes(),
+		"iss":            claims.Issuer,
+		"jti":            claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		resp["exp"] = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		resp["iat"] = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		resp["nbf"] = claims.NotBefore.Unix()
+	}
+	if claims.DeviceID != "" {
+		resp["device_id"] = claims.DeviceID
+	}
+	if claims.ClientID != "" {
+		resp["client_id"] = claims.ClientID
+	}
+	if claims.Scope != "" {
+		resp["scope"] = claims.Scope
+	}
+	
+	c.JSON(http.StatusOK, resp)
+}
+
+// Revoke 令牌吊销
+// @Summary 令牌吊销
+// @Description 按RFC 7009吊销令牌。客户端只能吊销签发给自己的令牌，拥有管理员角色的服务账号可吊销任意令牌
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "待吊销的令牌"
+// @Param token_type_hint formData string false "令牌类型提示"
+// @Success 200 "吊销成功（令牌无效时同样返回200）"
+// @Router /oauth/revoke [post]
+func (h *OAuthHandler) Revoke(c *gin.Context) {
+	var req service.TokenOperationRequest
+	if err := c.ShouldBind(&req); err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	
+	account, ok := h.authenticateClient(c, &req.ClientCredentials)
+	if !ok {
+		return
+	}
+	
+	// 无效或已失效的令牌视为吊销成功（RFC 7009 2.2）
+	claims, _ := middleware.ValidateAccessToken(req.Token)
+	if claims == nil {
+		c.Status(http.StatusOK)
+		return
+	}
+	
+	if claims.ClientID != account.ClientID && !h.serviceAccountService.CanRevokeAnyToken(account) {
+		oauthError(c, http.StatusForbidden, "unauthorized_client", "无权吊销该令牌")
+		return
+	}
+	
+	expiration := time.Until(claims.ExpiresAt.Time)
+	if err := middleware.BlacklistToken(req.Token, expiration); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":             "temporarily_unavailable",
+			"error_description": "吊销令牌失败",
+		})
+		return
+	}
+	
+	c.Status(http.StatusOK)
+}
+
+// authenticateClient 认证调用自省/吊销接口的客户端，失败时直接写入错误响应
+func (h *OAuthHandler) authenticateClient(c *gin.Context, creds *service.ClientCredentials) (*models.ServiceAccount, bool) {
+	applyBasicAuth(c, creds)
+	
+	account, err := h.serviceAccountService.AuthenticateClient(creds)
+	if err != nil {
+		if err == service.ErrInvalidClient {
+			c.Header("WWW-Authenticate", `Basic realm="usercenter"`)
+			oauthError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+		} else {
+			oauthError(c, http.StatusInternalServerError, "server_error", "客户端认证失败")
+		}
+		return nil, false
+	}
+	
+	return account, true
+}
+
+// applyBasicAuth 支持HTTP Basic方式传递客户端凭据
+func applyBasicAuth(c *gin.Context, creds *service.ClientCredentials) {
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		creds.ClientID = clientID
+		creds.ClientSecret = clientSecret
+	}
+}
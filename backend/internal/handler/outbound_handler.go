@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"net/http"
+	
+	"usercenter/internal/service"
+	
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type OutboundHandler struct {
+	outboundService *service.OutboundService
+}
+
+func NewOutboundHandler() *OutboundHandler {
+	return &OutboundHandler{
+		outboundService: service.NewOutboundService(),
+	}
+}
+
+// GetMessages 获取发送队列消息
+// @Summary 获取发送队列消息
+// @Description 管理员查看邮件和短信发送队列，包括等待重试和进入死信的消息。消息内容发送后不保留
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param channel query string false "渠道（email、sms）"
+// @Param status query int false "状态（1等待发送 2发送中 3已发送 4死信）"
+// @Param recipient query string false "接收方"
+// @Success 200 {object} map[string]interface{} "消息列表"
+// @Router /admin/outbound/messages [get]
+func (h *OutboundHandler) GetMessages(c *gin.Context) {
+	var query service.OutboundQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	// 设置默认值
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = 10
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+	
+	result, err := h.outboundService.List(&query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取发送队列失败",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": result,
+		"message": "获取发送队列成功",
+	})
+}
+
+// GetStats 获取发送队列统计
+// @Summary 获取发送队列统计
+// @Description 管理员查看各渠道各状态的消息数量
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{} "统计结果"
+// @Router /admin/outbound/stats [get]
+func (h *OutboundHandler) GetStats(c *gin.Context) {
+	stats, err := h.outboundService.Stats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取发送队列统计失败",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": stats,
+		"message": "获取发送队列统计成功",
+	})
+}
+
+// RetryMessage 重新发送死信
+// @Summary 重新发送死信
+// @Description 管理员将一条死信重新加入发送队列，发送次数清零。包含验证码、重置链接等凭据的消息进入死信时内容已清除，无法重新发送
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "消息ID"
+// @Success 200 {object} map[string]interface{} "重试结果"
+// @Router /admin/outbound/messages/{id}/retry [post]
+func (h *OutboundHandler) RetryMessage(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "消息ID格式错误",
+		})
+		return
+	}
+	
+	if err := h.outboundService.Retry(messageID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "消息已重新加入发送队列",
+	})
+}
+
+// RetryDead 重新发送所有死信
+// @Summary 重新发送所有死信
+// @Description 管理员将所有未过期的死信重新加入发送队列，用于服务商故障恢复后补发
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{} "重新加入队列的数量"
+// @Router /admin/outbound/retry-dead [post]
+func (h *OutboundHandler) RetryDead(c *gin.Context) {
+	count, err := h.outboundService.RetryDead()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "重新发送死信失败",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{"count": count},
+		"message": "死信已重新加入发送队列",
+	})
+}
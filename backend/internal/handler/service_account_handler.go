@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"net/http"
+	
+	"usercenter/internal/middleware"
+	"usercenter/internal/service"
+	
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ServiceAccountHandler struct {
+	serviceAccountService *service.ServiceAccountService
+}
+
+func NewServiceAccountHandler() *ServiceAccountHandler {
+	return &ServiceAccountHandler{
+		serviceAccountService: service.NewServiceAccountService(),
+	}
+}
+
+// GetServiceAccounts 获取服务账号列表
+// @Summary 获取服务账号列表
+// @Description 超级管理员获取服务账号列表
+// @Tags 超级管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param keyword query string false "名称或client_id"
+// @Param status query int false "状态"
+// @Success 200 {object} map[string]interface{} "服务账号列表"
+// @Router /super-admin/service-accounts [get]
+func (h *ServiceAccountHandler) GetServiceAccounts(c *gin.Context) {
+	var query service.ServiceAccountListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	// 设置默认值
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = 10
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+	
+	result, err := h.serviceAccountService.List(&query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取服务账号列表失败",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": result,
+		"message": "获取服务账号列表成功",
+	})
+}
+
+// CreateServiceAccount 创建服务账号
+// @Summary 创建服务账号
+// @Description 超级管理员创建服务账号，client_secret仅在响应中返回一次
+// @Tags 超级管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body service.CreateServiceAccountRequest true "服务账号信息"
+// @Success 200 {object} map[string]interface{} "创建结果"
+// @Router /super-admin/service-accounts [post]
+func (h *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	var req service.CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	currentUserID, _ := middleware.GetUserID(c)
+	
+	result, err := h.serviceAccountService.Create(&req, currentUserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": result,
+		"message": "服务账号创建成功，请妥善保存client_secret",
+	})
+}
+
+// GetServiceAccount 获取服务账号详情
+// @Summary 获取服务账号详情
+// @Description 超级管理员获取服务账号详情
+// @Tags 超级管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "服务账号ID"
+// @Success 200 {object} map[string]interface{} "服务账号详情"
+// @Router /super-admin/service-accounts/{id} [get]
+func (h *ServiceAccountHandler) GetServiceAccount(c *gin.Context) {
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "服务账号ID格式错误",
+		})
+		return
+	}
+	
+	account, err := h.serviceAccountService.Get(accountID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "服务账号不存在",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": account,
+		"message": "获取服务账号详情成功",
+	})
+}
+
+// UpdateServiceAccount 更新服务账号
+// @Summary 更新服务账号
+// @Description 超级管理员更新服务账号的描述、公钥、scope、状态和角色
+// @Tags 超级管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "服务账号ID"
+// @Param request body service.UpdateServiceAccountRequest true "更新信息"
+// @Success 200 {object} map[string]interface{} "更新结果"
+// @Router /super-admin/service-accounts/{id} [put]
+func (h *ServiceAccountHandler) UpdateServiceAccount(c *gin.Context) {
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "服务账号ID格式错误",
+		})
+		return
+	}
+	
+	var req service.UpdateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	err = h.serviceAccountService.Update(accountID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "服务账号更新成功",
+	})
+}
+
+// DeleteServiceAccount 删除服务账号
+// @Summary 删除服务账号
+// @Description 超级管理员删除服务账号，已签发的Token立即失效
+// @Tags 超级管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "服务账号ID"
+// @Success 200 {object} map[string]interface{} "删除结果"
+// @Router /super-admin/service-accounts/{id} [delete]
+func (h *ServiceAccountHandler) DeleteServiceAccount(c *gin.Context) {
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "服务账号ID格式错误",
+		})
+		return
+	}
+	
+	err = h.serviceAccountService.Delete(accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "删除服务账号失败",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "服务账号删除成功",
+	})
+}
+
+// RotateServiceAccountSecret 轮换服务账号密钥
+// @Summary 轮换服务账号密钥
+// @Description 生成新的client_secret，旧密钥及其签发的Token立即失效
+// @Tags 超级管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "服务账号ID"
+// @Success 200 {object} map[string]interface{} "新的凭据"
+// @Router /super-admin/service-accounts/{id}/rotate-secret [post]
+func (h *ServiceAccountHandler) RotateServiceAccountSecret(c *gin.Context) {
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "服务账号ID格式错误",
+		})
+		return
+	}
+	
+	result, err := h.serviceAccountService.RotateSecret(accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "轮换密钥失败",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": result,
+		"message": "密钥轮换成功，请妥善保存client_secret",
+	})
+}
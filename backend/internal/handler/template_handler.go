@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"net/http"
+	
+	"usercenter/internal/service"
+	
+	"github.com/gin-gonic/gin"
+)
+
+type TemplateHandler struct {
+	templateService *service.TemplateService
+}
+
+func NewTemplateHandler() *TemplateHandler {
+	return &TemplateHandler{
+		templateService: service.NewTemplateService(),
+	}
+}
+
+// GetTemplates 获取邮件和短信模板列表
+// @Summary 获取邮件和短信模板列表
+// @Description 管理员获取内置和模板目录中的所有模板及其语言版本
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{} "模板列表"
+// @Router /admin/templates [get]
+func (h *TemplateHandler) GetTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": h.templateService.List(),
+		"message": "获取模板列表成功",
+	})
+}
+
+// PreviewTemplate 预览邮件或短信模板
+// @Summary 预览邮件或短信模板
+// @Description 管理员使用示例数据渲染模板，data中的字段覆盖示例数据。模板文件修改后无需重启即可预览
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body service.TemplatePreviewRequest true "模板类型、名称和语言"
+// @Success 200 {object} map[string]interface{} "渲染结果"
+// @Router /admin/templates/preview [post]
+func (h *TemplateHandler) PreviewTemplate(c *gin.Context) {
+	var req service.TemplatePreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	preview, err := h.templateService.Preview(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": preview,
+		"message": "模板预览成功",
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 	
+	"usercenter/internal/config"
 	"usercenter/internal/middleware"
 	"usercenter/internal/service"
 	
@@ -11,12 +12,14 @@ import (
 )
 
 type UserHandler struct {
-	userService *service.UserService
+	userService     *service.UserService
+	loginLogService *service.LoginLogService
 }
 
 func NewUserHandler() *UserHandler {
 	return &UserHandler{
-		userService: service.NewUserService(),
+		userService:     service.NewUserService(),
+		loginLogService: service.NewLoginLogService(),
 	}
 }
 
@@ -137,6 +140,15 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 	
+	// 受限Token完成修改密码后立即作废，需要使用新密码重新登录
+	if middleware.IsPasswordChangeToken(c) {
+		token, _ := middleware.GetToken(c)
+		middleware.BlacklistToken(token, config.GlobalConfig.JWT.PasswordChangeTokenExpires)
+		if middleware.IsSessionAuth(c) {
+			middleware.EndSession(c)
+		}
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "密码修改成功",
@@ -380,6 +392,12 @@ func (h *UserHandler) GetLogs(c *gin.Context) {
 	
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 10
+	}
 	
 	logs, err := h.userService.GetUserLogs(userID, page, pageSize)
 	if err != nil {
@@ -396,3 +414,99 @@ func (h *UserHandler) GetLogs(c *gin.Context) {
 		"message": "获取操作日志成功",
 	})
 }
+
+// GetLoginLogs 获取登录记录
+// @Summary 获取登录记录
+// @Description 获取当前用户的登录记录，包括成功和失败的登录、登录方式、IP、地理位置、设备和失败原因
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param status query int false "状态（1成功 2失败 3待二次验证）"
+// @Param method query string false "登录方式"
+// @Param start_date query string false "开始日期（2006-01-02）"
+// @Param end_date query string false "结束日期（2006-01-02）"
+// @Success 200 {object} map[string]interface{} "登录记录"
+// @Router /profile/login-logs [get]
+func (h *UserHandler) GetLoginLogs(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+	
+	var query service.LoginLogQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	// 设置默认值
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = 10
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+	
+	logs, err := h.loginLogService.ListForUser(userID, &query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取登录记录失败",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": logs,
+		"message": "获取登录记录成功",
+	})
+}
+
+// GetSecuritySettings 获取账号安全概况
+// @Summary 获取账号安全概况
+// @Description 获取两步验证状态、绑定的邮箱和手机号、密码状态、活跃会话以及最近30天的登录失败和风险登录
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{} "安全概况"
+// @Router /profile/security-settings [get]
+func (h *UserHandler) GetSecuritySettings(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    401,
+			"message": "未登录",
+		})
+		return
+	}
+	
+	settings, err := h.userService.GetSecuritySettings(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取安全设置失败",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": settings,
+		"message": "获取安全设置成功",
+	})
+}
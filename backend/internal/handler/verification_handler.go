@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"net/http"
+	
+	"usercenter/internal/middleware"
+	"usercenter/internal/service"
+	
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type VerificationHandler struct {
+	verificationService *service.VerificationService
+}
+
+func NewVerificationHandler() *VerificationHandler {
+	return &VerificationHandler{
+		verificationService: service.NewVerificationService(),
+	}
+}
+
+// GetRecords 获取验证码发送记录
+// @Summary 获取验证码发送记录
+// @Description 管理员查看验证码发送记录，包括被拦截和发送失败的请求
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(10)
+// @Param type query string false "接收方类型（email、phone）"
+// @Param target query string false "接收方"
+// @Param purpose query string false "用途"
+// @Param status query int false "状态（1已发送 2被拦截 3发送失败）"
+// @Param ip query string false "IP"
+// @Param start_date query string false "开始日期（2006-01-02）"
+// @Param end_date query string false "结束日期（2006-01-02）"
+// @Success 200 {object} map[string]interface{} "发送记录"
+// @Router /admin/verification/records [get]
+func (h *VerificationHandler) GetRecords(c *gin.Context) {
+	var query service.VerificationRecordQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	// 设置默认值
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = 10
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+	
+	result, err := h.verificationService.ListRecords(&query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取发送记录失败",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": result,
+		"message": "获取发送记录成功",
+	})
+}
+
+// GetStats 获取验证码发送统计
+// @Summary 获取验证码发送统计
+// @Description 管理员查看每日发送量、拦截原因、被拦截最多的IP和发送最多的接收方，默认统计最近7天
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param type query string false "接收方类型（email、phone）"
+// @Param start_date query string false "开始日期（2006-01-02）"
+// @Param end_date query string false "结束日期（2006-01-02）"
+// @Success 200 {object} map[string]interface{} "发送统计"
+// @Router /admin/verification/stats [get]
+func (h *VerificationHandler) GetStats(c *gin.Context) {
+	var query service.VerificationRecordQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	stats, err := h.verificationService.Stats(&query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取发送统计失败",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": stats,
+		"message": "获取发送统计成功",
+	})
+}
+
+// GetBlocks 获取验证码发送黑名单
+// @Summary 获取验证码发送黑名单
+// @Description 管理员获取禁止接收验证码的邮箱、邮箱域名和号码
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param type query string false "接收方类型（email、phone）"
+// @Success 200 {object} map[string]interface{} "黑名单"
+// @Router /admin/verification/blocklist [get]
+func (h *VerificationHandler) GetBlocks(c *gin.Context) {
+	blocks, err := h.verificationService.ListBlocks(c.Query("type"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取黑名单失败",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": blocks,
+		"message": "获取黑名单成功",
+	})
+}
+
+// CreateBlock 添加验证码发送黑名单
+// @Summary 添加验证码发送黑名单
+// @Description 管理员添加黑名单规则。pattern为完整的邮箱或号码；@开头表示整个邮箱域名；*结尾表示号码前缀，前缀使用E.164格式（如+86170*）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body service.VerificationBlockRequest true "黑名单规则"
+// @Success 200 {object} map[string]interface{} "添加结果"
+// @Router /admin/verification/blocklist [post]
+func (h *VerificationHandler) CreateBlock(c *gin.Context) {
+	var req service.VerificationBlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+	
+	operatorID, _ := middleware.GetUserID(c)
+	block, err := h.verificationService.CreateBlock(&req, operatorID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": block,
+		"message": "黑名单添加成功",
+	})
+}
+
+// DeleteBlock 删除验证码发送黑名单
+// @Summary 删除验证码发送黑名单
+// @Description 管理员删除黑名单规则
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "黑名单规则ID"
+// @Success 200 {object} map[string]interface{} "删除结果"
+// @Router /admin/verification/blocklist/{id} [delete]
+func (h *VerificationHandler) DeleteBlock(c *gin.Context) {
+	blockID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "黑名单规则ID格式错误",
+		})
+		return
+	}
+	
+	if err := h.verificationService.DeleteBlock(blockID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "黑名单删除成功",
+	})
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 	
 	"usercenter/internal/cache"
+	"usercenter/internal/models"
+	"usercenter/pkg/ipfilter"
 	"usercenter/pkg/jwt"
 	
 	"github.com/gin-gonic/gin"
@@ -15,83 +17,187 @@ import (
 // AuthMiddleware JWT认证中间件
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-		if token == "" {
+		claims, message := Authenticate(c)
+		if claims == nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code":    401,
-				"message": "请先登录",
+				"message": message,
 			})
 			c.Abort()
 			return
 		}
 		
-		// 移除Bearer前缀
-		if strings.HasPrefix(token, "Bearer ") {
-			token = token[7:]
-		}
-		
-		// 解析Token
-		claims, err := jwt.ParseToken(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    401,
-				"message": "Token无效",
+		// 使用Cookie会话的写操作需要校验CSRF Token
+		if !VerifyCSRF(c, c.Request.Method) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "CSRF校验失败",
 			})
 			c.Abort()
 			return
 		}
 		
-		// 检查Token是否在黑名单中
-		blacklistKey := "token_blacklist:" + token
-		exists, _ := cache.Exists(blacklistKey)
-		if exists {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code":    401,
-				"message": "Token已失效",
+		// 受限Token或被要求修改密码的用户只能访问修改密码相关接口
+		if RequiresPasswordChange(claims) && !passwordChangeRoutes[c.FullPath()] {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    CodePasswordChangeRequired,
+				"message": "请先修改密码",
 			})
 			c.Abort()
 			return
 		}
 		
-		// 将用户信息存储到上下文中
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
-		c.Set("device_id", claims.DeviceID)
-		c.Set("token", token)
-		
 		c.Next()
 	}
 }
 
+// CodePasswordChangeRequired 需要先修改密码的业务错误码（密码过期或被要求修改密码）
+const CodePasswordChangeRequired = 40302
+
+// RequiresPasswordChange 是否必须先修改密码：使用受限Token，或管理员在Token签发后要求用户修改密码。
+// 此时只能访问passwordChangeRoutes中的接口，已登录的会话可先重新验证身份再修改密码
+func RequiresPasswordChange(claims *jwt.Claims) bool {
+	if claims.IsPasswordChangeOnly() {
+		return true
+	}
+	if claims.IsServiceAccount() {
+		return false
+	}
+	
+	exists, _ := cache.Exists("must_change_password:" + claims.UserID.String())
+	return exists
+}
+
+// passwordChangeRoutes 受限Token允许访问的接口。修改密码要求最近验证过身份，
+// 签发受限Token时的验证超过security.reauth_window后需重新验证，因此重新验证接口同样允许访问
+var passwordChangeRoutes = map[string]bool{
+	"/api/v1/profile/password":      true,
+	"/api/v1/auth/reauth":           true,
+	"/api/v1/auth/reauth/send-code": true,
+	"/api/v1/auth/logout":           true,
+	"/api/v1/auth/user":             true,
+}
+
+// Authenticate 从请求中提取并校验凭据（Bearer Token或Cookie会话），成功时将主体信息写入上下文。
+// AuthMiddleware与转发认证接口共用此逻辑，保证两者的判定完全一致
+func Authenticate(c *gin.Context) (*jwt.Claims, string) {
+	// 优先使用Bearer Token，其次使用Cookie会话
+	token := extractBearerToken(c)
+	fromSession := false
+	if token == "" {
+		token = sessionToken(c)
+		fromSession = token != ""
+	}
+	if token == "" {
+		return nil, "请先登录"
+	}
+	
+	// 校验Token
+	claims, message := ValidateRequestToken(c, token)
+	if claims == nil {
+		return nil, message
+	}
+	
+	// 将用户信息存储到上下文中
+	setAuthContext(c, claims, token)
+	if fromSession {
+		c.Set("auth_source", AuthSourceSession)
+	}
+	
+	return claims, ""
+}
+
+// ValidateRequestToken 校验Token并检查请求来源IP是否在用户的个人IP白名单中。
+// 客户端IP取自c.ClientIP()，经server.trusted_proxies转发的请求按转发头中的IP判断
+func ValidateRequestToken(c *gin.Context, token string) (*jwt.Claims, string) {
+	claims, message := ValidateAccessToken(token)
+	if claims == nil {
+		return nil, message
+	}
+	
+	// 设置了个人IP白名单的账号只能从白名单内的IP访问
+	if !claims.IsServiceAccount() && !ipfilter.UserAllowed(claims.UserID, c.ClientIP()) {
+		return nil, "当前IP不允许使用该账号"
+	}
+	return claims, ""
+}
+
+// extractBearerToken 从Authorization头中提取Token
+func extractBearerToken(c *gin.Context) string {
+	token := c.GetHeader("Authorization")
+	
+	// 移除Bearer前缀
+	if strings.HasPrefix(token, "Bearer ") {
+		token = token[7:]
+	}
+	
+	return token
+}
+
 // OptionalAuthMiddleware 可选认证中间件
 func OptionalAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-		if token != "" {
-			if strings.HasPrefix(token, "Bearer ") {
-				token = token[7:]
-			}
-			
-			claims, err := jwt.ParseToken(token)
-			if err == nil {
-				// 检查Token是否在黑名单中
-				blacklistKey := "token_blacklist:" + token
-				exists, _ := cache.Exists(blacklistKey)
-				if !exists {
-					c.Set("user_id", claims.UserID)
-					c.Set("username", claims.Username)
-					c.Set("role", claims.Role)
-					c.Set("device_id", claims.DeviceID)
-					c.Set("token", token)
-				}
-			}
-		}
-		
+		Authenticate(c)
 		c.Next()
 	}
 }
 
+// ValidateAccessToken 校验Token签名、黑名单以及用户和服务账号的吊销状态，失败时返回提示信息
+func ValidateAccessToken(token string) (*jwt.Claims, string) {
+	// 解析Token
+	claims, err := jwt.ParseToken(token)
+	if err != nil {
+		return nil, "Token无效"
+	}
+	
+	// 检查Token是否在黑名单中
+	blacklistKey := "token_blacklist:" + token
+	exists, _ := cache.Exists(blacklistKey)
+	if exists {
+		return nil, "Token已失效"
+	}
+	
+	// 服务账号被禁用、删除或轮换密钥，或用户注销全部登录（如确认风险登录不是本人操作）后，
+	// 此前签发的Token失效
+	if jwt.IsRevoked(claims) {
+		return nil, "Token已失效"
+	}
+	
+	return claims, ""
+}
+
+// setAuthContext 将认证主体信息写入上下文
+func setAuthContext(c *gin.Context, claims *jwt.Claims, token string) {
+	if claims.IsServiceAccount() {
+		c.Set("principal_type", models.PrincipalTypeServiceAccount)
+		c.Set("service_account_id", claims.UserID)
+		c.Set("client_id", claims.ClientID)
+		c.Set("scope", claims.Scope)
+	} else {
+		c.Set("principal_type", models.PrincipalTypeUser)
+		c.Set("user_id", claims.UserID)
+		c.Set("device_id", claims.DeviceID)
+	}
+	c.Set("roles", claims.RoleCodes())
+	c.Set("username", claims.Username)
+	c.Set("role", claims.Role)
+	c.Set("token", token)
+	c.Set("token_id", claims.ID)
+	c.Set("token_type", claims.TokenType)
+}
+
+// GetTokenID 获取当前Token的jti
+func GetTokenID(c *gin.Context) string {
+	tokenID, _ := c.Get("token_id")
+	id, _ := tokenID.(string)
+	return id
+}
+
+// IsPasswordChangeToken 当前请求是否使用只能修改密码的受限Token
+func IsPasswordChangeToken(c *gin.Context) bool {
+	return c.GetString("token_type") == jwt.TokenTypePasswordChange
+}
+
 // RoleMiddleware 角色权限中间件
 func RoleMiddleware(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -105,11 +211,18 @@ func RoleMiddleware(roles ...string) gin.HandlerFunc {
 			return
 		}
 		
-		roleStr := userRole.(string)
+		// 服务账号可能拥有多个角色
+		userRoles := []string{userRole.(string)}
+		if all, ok := c.Get("roles"); ok {
+			userRoles = all.([]string)
+		}
+		
 		for _, role := range roles {
-			if roleStr == role {
-				c.Next()
-				return
+			for _, userRole := range userRoles {
+				if userRole == role {
+					c.Next()
+					return
+				}
 			}
 		}
 		
@@ -142,6 +255,28 @@ func GetUserID(c *gin.Context) (uuid.UUID, bool) {
 	return id, ok
 }
 
+// GetServiceAccountID 从上下文中获取服务账号ID
+func GetServiceAccountID(c *gin.Context) (uuid.UUID, bool) {
+	accountID, exists := c.Get("service_account_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+	
+	id, ok := accountID.(uuid.UUID)
+	return id, ok
+}
+
+// GetPrincipalType 从上下文中获取认证主体类型
+func GetPrincipalType(c *gin.Context) string {
+	principalType, exists := c.Get("principal_type")
+	if !exists {
+		return ""
+	}
+	
+	typeStr, _ := principalType.(string)
+	return typeStr
+}
+
 // GetUsername 从上下文中获取用户名
 func GetUsername(c *gin.Context) (string, bool) {
 	username, exists := c.Get("username")
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	
+	"usercenter/internal/cache"
+	"usercenter/internal/config"
+	"usercenter/pkg/jwt"
+	
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const testReauthWindow = 5 * time.Minute
+
+// setupAuth 使用miniredis和测试配置，测试结束后恢复
+func setupAuth(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	prevRDB, prevConfig := cache.RDB, config.GlobalConfig
+	cache.RDB = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	config.GlobalConfig = &config.Config{
+		JWT: config.JWTConfig{
+			Secret:                     "test-secret",
+			Expires:                    time.Hour,
+			PasswordChangeTokenExpires: 30 * time.Minute,
+		},
+		Security: config.SecurityConfig{ReauthWindow: testReauthWindow},
+	}
+	t.Cleanup(func() {
+		cache.RDB.Close()
+		cache.RDB, config.GlobalConfig = prevRDB, prevConfig
+	})
+	return mr
+}
+
+// passwordChangeRouter 按router.go的结构注册修改密码流程用到的接口，
+// 重新验证接口与AuthService.Reauthenticate一样在验证通过后记录最近验证时间
+func passwordChangeRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"code": 200}) }
+	reauth := func(c *gin.Context) {
+		userID, _ := GetUserID(c)
+		cache.Set(fmt.Sprintf("reauth:%s:%s", userID, GetTokenID(c)), time.Now().Unix(), testReauthWindow)
+		ok(c)
+	}
+	
+	protected := r.Group("/api/v1")
+	protected.Use(AuthMiddleware())
+	protected.POST("/auth/reauth", reauth)
+	protected.POST("/auth/reauth/send-code", ok)
+	protected.POST("/auth/logout", ok)
+	protected.GET("/auth/user", ok)
+	protected.GET("/profile", ok)
+	protected.PUT("/profile/password", RequireRecentAuth(), ok)
+	return r
+}
+
+// call 发送请求并返回响应中的业务码
+func call(t *testing.T, r *gin.Engine, method, path, token string) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	
+	var body struct {
+		Code int `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s %s: invalid response %q", method, path, w.Body.String())
+	}
+	return body.Code
+}
+
+// markReauth 模拟签发Token时已验证过身份
+func markReauth(t *testing.T, token string) {
+	t.Helper()
+	claims, err := jwt.ParseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Set(fmt.Sprintf("reauth:%s:%s", claims.UserID, claims.ID), time.Now().Unix(), testReauthWindow)
+}
+
+func TestPasswordChangeTokenAfterReauthWindow(t *testing.T) {
+	mr := setupAuth(t)
+	r := passwordChangeRouter()
+	
+	token, _, err := jwt.GeneratePasswordChangeToken(uuid.New(), "alice", "device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	markReauth(t, token)
+	mr.FastForward(testReauthWindow + time.Minute)
+	
+	steps := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{method: http.MethodPut, path: "/api/v1/profile/password", want: CodeReauthRequired},
+		{method: http.MethodGet, path: "/api/v1/profile", want: CodePasswordChangeRequired},
+		{method: http.MethodPost, path: "/api/v1/auth/reauth/send-code", want: 200},
+		{method: http.MethodPost, path: "/api/v1/auth/reauth", want: 200},
+		{method: http.MethodPut, path: "/api/v1/profile/password", want: 200},
+	}
+	for _, step := range steps {
+		if got := call(t, r, step.method, step.path, token); got != step.want {
+			t.Fatalf("%s %s code = %d, want %d", step.method, step.path, got, step.want)
+		}
+	}
+}
+
+func TestForcedPasswordChangeOnLiveSession(t *testing.T) {
+	mr := setupAuth(t)
+	r := passwordChangeRouter()
+	
+	userID := uuid.New()
+	token, err := jwt.GenerateToken(userID, "alice", "user", "device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	markReauth(t, token)
+	
+	// 会话使用一段时间后管理员要求修改密码，此时最近验证的记录已过期
+	mr.FastForward(testReauthWindow + time.Minute)
+	cache.Set("must_change_password:"+userID.String(), "1", 0)
+	
+	steps := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{method: http.MethodGet, path: "/api/v1/profile", want: CodePasswordChangeRequired},
+		{method: http.MethodGet, path: "/api/v1/auth/user", want: 200},
+		{method: http.MethodPut, path: "/api/v1/profile/password", want: CodeReauthRequired},
+		{method: http.MethodPost, path: "/api/v1/auth/reauth", want: 200},
+		{method: http.MethodPut, path: "/api/v1/profile/password", want: 200},
+	}
+	for _, step := range steps {
+		if got := call(t, r, step.method, step.path, token); got != step.want {
+			t.Fatalf("%s %s code = %d, want %d", step.method, step.path, got, step.want)
+		}
+	}
+}
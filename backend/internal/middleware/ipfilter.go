@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	
+	"usercenter/pkg/ipfilter"
+	
+	"github.com/gin-gonic/gin"
+)
+
+// IPFilterMiddleware 按IP访问规则限制请求来源，多个范围需全部通过。
+// 客户端IP取自c.ClientIP()，只有来自server.trusted_proxies的请求才信任X-Forwarded-For等请求头
+func IPFilterMiddleware(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		for _, scope := range scopes {
+			if !ipfilter.Allowed(scope, ip) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"code":    403,
+					"message": "当前IP不允许访问",
+				})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
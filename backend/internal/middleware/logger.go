@@ -8,6 +8,7 @@ import (
 	
 	"usercenter/internal/database"
 	"usercenter/internal/models"
+	"usercenter/pkg/geoip"
 	
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -27,6 +28,12 @@ func LoggerMiddleware(logger *zap.Logger) gin.HandlerFunc {
 // OperationLogMiddleware 操作日志中间件
 func OperationLogMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// 转发认证由反向代理对每个请求调用，不记录操作日志
+		if c.Request.URL.Path == "/api/v1/auth/verify" {
+			c.Next()
+			return
+		}
+		
 		start := time.Now()
 		
 		// 读取请求体
@@ -49,6 +56,15 @@ func OperationLogMiddleware() gin.HandlerFunc {
 				userID = uid.(uuid.UUID)
 			}
 			
+			// 获取操作主体（用户或服务账号）
+			actorType := models.PrincipalTypeUser
+			actorID := userID
+			if accountID, exists := GetServiceAccountID(c); exists {
+				actorType = models.PrincipalTypeServiceAccount
+				actorID = accountID
+			}
+			actorName, _ := GetUsername(c)
+			
 			// 构建请求详情
 			details := map[string]interface{}{
 				"method":     c.Request.Method,
@@ -68,6 +84,10 @@ func OperationLogMiddleware() gin.HandlerFunc {
 						delete(requestBody, "password")
 						delete(requestBody, "old_password")
 						delete(requestBody, "new_password")
+						delete(requestBody, "client_secret")
+						delete(requestBody, "client_assertion")
+						delete(requestBody, "users") // 批量导入的用户包含密码哈希
+						delete(requestBody, "token")
 						details["request_body"] = requestBody
 					}
 				}
@@ -85,12 +105,16 @@ func OperationLogMiddleware() gin.HandlerFunc {
 			// 保存日志
 			userLog := models.UserLog{
 				UserID:    userID,
+				ActorType: actorType,
+				ActorID:   actorID,
+				ActorName: actorName,
 				Action:    action,
 				Module:    getModuleFromPath(c.Request.URL.Path),
 				IP:        c.ClientIP(),
 				UserAgent: c.Request.UserAgent(),
 				Details:   string(detailsJSON),
 				Status:    status,
+				Location:  geoip.Lookup(c.ClientIP()),
 			}
 			
 			database.DB.Create(&userLog)
@@ -125,7 +149,7 @@ func shouldLogRequestBody(method, path string) bool {
 
 // getActionFromPath 从路径获取操作类型
 func getActionFromPath(method, path string) string {
-	if path == "/api/v1/auth/login" {
+	if path == "/api/v1/auth/login" || path == "/api/v1/auth/login/verify" {
 		return "用户登录"
 	}
 	if path == "/api/v1/auth/logout" {
@@ -175,6 +199,12 @@ func getModuleFromPath(path string) string {
 	if len(path) >= 18 && path[:18] == "/api/v1/permissions" {
 		return "权限管理"
 	}
+	if len(path) >= 13 && path[:13] == "/api/v1/oauth" {
+		return "OAuth"
+	}
+	if len(path) >= 19 && path[:19] == "/api/v1/super-admin" {
+		return "系统管理"
+	}
 	
 	return "系统"
 }
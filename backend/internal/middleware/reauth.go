@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	
+	"usercenter/internal/cache"
+	"usercenter/internal/models"
+	
+	"github.com/gin-gonic/gin"
+)
+
+// CodeReauthRequired 需要重新验证身份的业务错误码，前端收到后应引导用户调用 /auth/reauth
+const CodeReauthRequired = 40301
+
+// RequireRecentAuth 敏感操作中间件，要求当前会话在重新验证时间窗口内验证过身份
+func RequireRecentAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 服务账号没有交互式身份验证，由角色和scope控制
+		if GetPrincipalType(c) == models.PrincipalTypeServiceAccount {
+			c.Next()
+			return
+		}
+		
+		userID, exists := GetUserID(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code":    401,
+				"message": "用户未认证",
+			})
+			c.Abort()
+			return
+		}
+		
+		key := fmt.Sprintf("reauth:%s:%s", userID, GetTokenID(c))
+		if ok, _ := cache.Exists(key); !ok {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    CodeReauthRequired,
+				"message": "该操作需要重新验证身份",
+			})
+			c.Abort()
+			return
+		}
+		
+		c.Next()
+	}
+}
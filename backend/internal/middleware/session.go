@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	
+	"usercenter/internal/config"
+	"usercenter/pkg/crypto"
+	
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/redis"
+	"github.com/gin-gonic/gin"
+)
+
+// 会话中保存的键
+const (
+	sessionTokenKey = "token"
+	sessionCSRFKey  = "csrf_token"
+)
+
+// AuthSourceSession 通过Cookie会话完成认证
+const AuthSourceSession = "session"
+
+// SessionMiddleware 基于Redis的Cookie会话中间件
+func SessionMiddleware(cfg *config.Config) (gin.HandlerFunc, error) {
+	secret := cfg.Session.Secret
+	if secret == "" {
+		secret = cfg.JWT.Secret
+	}
+	
+	store, err := redis.NewStoreWithDB(10, "tcp",
+		fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port),
+		cfg.Redis.Password, strconv.Itoa(cfg.Redis.DB), []byte(secret))
+	if err != nil {
+		return nil, err
+	}
+	
+	store.Options(sessionOptions(&cfg.Session, int(cfg.JWT.Expires.Seconds())))
+	
+	return sessions.Sessions(cfg.Session.CookieName, store), nil
+}
+
+// StartSession 将Token保存到会话中并下发CSRF Cookie，返回CSRF Token
+func StartSession(c *gin.Context, token string) (string, error) {
+	session, ok := currentSession(c)
+	if !ok {
+		return "", fmt.Errorf("session middleware not installed")
+	}
+	
+	csrfToken, err := crypto.GenerateRandomString(32)
+	if err != nil {
+		return "", err
+	}
+	
+	session.Clear()
+	session.Set(sessionTokenKey, token)
+	session.Set(sessionCSRFKey, csrfToken)
+	if err := session.Save(); err != nil {
+		return "", err
+	}
+	
+	setCSRFCookie(c, csrfToken, int(config.GlobalConfig.JWT.Expires.Seconds()))
+	return csrfToken, nil
+}
+
+// UpdateSessionToken 刷新Token后更新会话中保存的Token
+func UpdateSessionToken(c *gin.Context, token string) error {
+	session, ok := currentSession(c)
+	if !ok {
+		return fmt.Errorf("session middleware not installed")
+	}
+	
+	session.Set(sessionTokenKey, token)
+	return session.Save()
+}
+
+// EndSession 销毁会话并清除CSRF Cookie
+func EndSession(c *gin.Context) error {
+	session, ok := currentSession(c)
+	if !ok {
+		return nil
+	}
+	
+	cfg := config.GlobalConfig
+	session.Clear()
+	options := sessionOptions(&cfg.Session, -1)
+	session.Options(options)
+	setCSRFCookie(c, "", -1)
+	
+	return session.Save()
+}
+
+// IsSessionAuth 当前请求是否通过Cookie会话认证
+func IsSessionAuth(c *gin.Context) bool {
+	source, _ := c.Get("auth_source")
+	return source == AuthSourceSession
+}
+
+// VerifyCSRF 对使用Cookie会话发起的写操作进行双重提交校验：
+// 请求头中的CSRF Token必须同时与CSRF Cookie和会话中保存的值一致
+func VerifyCSRF(c *gin.Context, method string) bool {
+	if !IsSessionAuth(c) || isSafeMethod(method) {
+		return true
+	}
+	
+	cfg := config.GlobalConfig.Session
+	headerToken := c.GetHeader(cfg.CSRFHeaderName)
+	cookieToken, _ := c.Cookie(cfg.CSRFCookieName)
+	if headerToken == "" || cookieToken == "" {
+		return false
+	}
+	
+	session, ok := currentSession(c)
+	if !ok {
+		return false
+	}
+	sessionToken, _ := session.Get(sessionCSRFKey).(string)
+	
+	return subtle.ConstantTimeCompare([]byte(headerToken), []byte(cookieToken)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(headerToken), []byte(sessionToken)) == 1
+}
+
+// sessionToken 从Cookie会话中读取Token
+func sessionToken(c *gin.Context) string {
+	// 未携带会话Cookie时不访问会话存储
+	if _, err := c.Cookie(config.GlobalConfig.Session.CookieName); err != nil {
+		return ""
+	}
+	
+	session, ok := currentSession(c)
+	if !ok {
+		return ""
+	}
+	
+	token, _ := session.Get(sessionTokenKey).(string)
+	return token
+}
+
+// currentSession 获取当前请求的会话（未注册会话中间件时返回false）
+func currentSession(c *gin.Context) (sessions.Session, bool) {
+	if _, exists := c.Get(sessions.DefaultKey); !exists {
+		return nil, false
+	}
+	return sessions.Default(c), true
+}
+
+// setCSRFCookie 下发CSRF Cookie，前端需要读取该值，因此不设置HttpOnly
+func setCSRFCookie(c *gin.Context, value string, maxAge int) {
+	cfg := config.GlobalConfig.Session
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     cfg.CSRFCookieName,
+		Value:    value,
+		Path:     "/",
+		Domain:   cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   cfg.Secure,
+		HttpOnly: false,
+		SameSite: parseSameSite(cfg.SameSite),
+	})
+}
+
+func sessionOptions(cfg *config.SessionConfig, maxAge int) sessions.Options {
+	return sessions.Options{
+		Path:     "/",
+		Domain:   cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   cfg.Secure,
+		HttpOnly: true,
+		SameSite: parseSameSite(cfg.SameSite),
+	}
+}
+
+func parseSameSite(value string) http.SameSite {
+	switch strings.ToLower(value) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
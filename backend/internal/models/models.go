@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 	"gorm.io/gorm"
 	"github.com/google/uuid"
@@ -26,6 +27,7 @@ type User struct {
 	Gender          int        `json:"gender" gorm:"default:0"` // 0:未知 1:男 2:女
 	Birthday        *time.Time `json:"birthday"`
 	Bio             string     `json:"bio" gorm:"size:500"`
+	Language        string     `json:"language" gorm:"size:16"` // 偏好语言，如zh-CN、en，决定邮件和短信使用的模板
 	Status          int        `json:"status" gorm:"default:1"` // 1:正常 2:禁用 3:锁定
 	EmailVerified   bool       `json:"email_verified" gorm:"default:false"`
 	PhoneVerified   bool       `json:"phone_verified" gorm:"default:false"`
@@ -35,6 +37,10 @@ type User struct {
 	LockedUntil     *time.Time `json:"locked_until"`
 	TwoFactorSecret string     `json:"-"`
 	TwoFactorEnabled bool      `json:"two_factor_enabled" gorm:"default:false"`
+	PasswordChangedAt *time.Time `json:"password_changed_at"`
+	PasswordBreached  bool       `json:"password_breached" gorm:"default:false"` // 当前密码出现在已泄露密码库中
+	MustChangePassword bool      `json:"must_change_password" gorm:"default:false"` // 下次登录必须修改密码
+	LastLoginLocation  GeoLocation `json:"last_login_location" gorm:"embedded;embeddedPrefix:last_login_"`
 	
 	// 关联关系
 	Roles       []Role       `json:"roles" gorm:"many2many:user_roles;"`
@@ -75,6 +81,27 @@ type Permission struct {
 	Roles    []Role      `json:"roles" gorm:"many2many:role_permissions;"`
 }
 
+// GeoLocation IP地理位置，由本地GeoIP数据库解析
+type GeoLocation struct {
+	Country     string `json:"country,omitempty"`
+	CountryCode string `json:"country_code,omitempty" gorm:"size:2;index"`
+	Region      string `json:"region,omitempty"`
+	City        string `json:"city,omitempty"`
+	ASN         uint   `json:"asn,omitempty"`
+	ASOrg       string `json:"as_org,omitempty"`
+}
+
+// String 返回“国家 地区 城市”形式的位置描述
+func (l GeoLocation) String() string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{l.Country, l.Region, l.City} {
+		if part != "" && (len(parts) == 0 || parts[len(parts)-1] != part) {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
 // UserDevice 用户设备模型
 type UserDevice struct {
 	BaseModel
@@ -86,36 +113,136 @@ type UserDevice struct {
 	UserAgent   string    `json:"user_agent"`
 	LastActive  time.Time `json:"last_active"`
 	IsActive    bool      `json:"is_active" gorm:"default:true"`
+	Location    GeoLocation `json:"location" gorm:"embedded;embeddedPrefix:geo_"`
 	
 	// 关联关系
 	User User `json:"user"`
 }
 
+// LoginLog 登录记录，与操作日志分开保存，UserID为空表示登录的账号不存在
+type LoginLog struct {
+	BaseModel
+	UserID        *uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	Username      string      `json:"username"`              // 登录时输入的账号
+	Status        int         `json:"status" gorm:"index"`   // 1:成功 2:失败 3:待二次验证
+	Method        string      `json:"method" gorm:"size:20"` // password, totp, email_code, sms_code
+	FailureReason string      `json:"failure_reason"`
+	RiskReasons   string      `json:"risk_reasons"` // 逗号分隔的风险原因
+	IP            string      `json:"ip"`
+	UserAgent     string      `json:"user_agent"`
+	DeviceID      string      `json:"device_id"`
+	DeviceType    string      `json:"device_type"`
+	DeviceName    string      `json:"device_name"`
+	Location      GeoLocation `json:"location" gorm:"embedded;embeddedPrefix:geo_"`
+}
+
+// IPRule IP访问规则（CIDR），UserID不为空时为该用户的个人白名单
+type IPRule struct {
+	BaseModel
+	CIDR        string     `json:"cidr" gorm:"not null"`
+	Action      string     `json:"action" gorm:"size:10;not null"`      // allow, deny
+	Scope       string     `json:"scope" gorm:"size:20;not null;index"` // global, login, admin, super_admin, user
+	UserID      *uuid.UUID `json:"user_id" gorm:"type:uuid;index"`
+	Description string     `json:"description" gorm:"size:500"`
+	Status      int        `json:"status" gorm:"default:1"` // 1:启用 2:停用
+	CreatedBy   uuid.UUID  `json:"created_by"`
+}
+
 // UserLog 用户日志模型
 type UserLog struct {
 	BaseModel
 	UserID     uuid.UUID `json:"user_id"`
+	ActorType  string    `json:"actor_type" gorm:"size:20;default:user"` // user, service_account
+	ActorID    uuid.UUID `json:"actor_id" gorm:"type:uuid;index"`
+	ActorName  string    `json:"actor_name"`
 	Action     string    `json:"action" gorm:"not null"`
 	Module     string    `json:"module"`
 	IP         string    `json:"ip"`
 	UserAgent  string    `json:"user_agent"`
 	Details    string    `json:"details" gorm:"type:text"`
 	Status     int       `json:"status"` // 1:成功 2:失败
+	Location   GeoLocation `json:"location" gorm:"embedded;embeddedPrefix:geo_"`
 	
 	// 关联关系
 	User User `json:"user"`
 }
 
-// VerificationCode 验证码模型
+// ServiceAccount 服务账号模型（供后端服务调用的机器身份）
+type ServiceAccount struct {
+	BaseModel
+	Name         string     `json:"name" gorm:"uniqueIndex;not null"`
+	ClientID     string     `json:"client_id" gorm:"uniqueIndex;not null"`
+	ClientSecret string     `json:"-" gorm:"not null"`
+	PublicKey    string     `json:"public_key" gorm:"type:text"` // 用于校验JWT断言的PEM公钥
+	Scopes       string     `json:"scopes"`                      // 允许申请的scope，空格分隔
+	Description  string     `json:"description" gorm:"size:500"`
+	Status       int        `json:"status" gorm:"default:1"` // 1:正常 2:禁用
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	LastUsedIP   string     `json:"last_used_ip"`
+	CreatedBy    uuid.UUID  `json:"created_by"`
+	
+	// 关联关系
+	Roles []Role `json:"roles" gorm:"many2many:service_account_roles;"`
+}
+
+// VerificationCode 验证码模型，每次发送请求（包括被拦截的）对应一条记录
 type VerificationCode struct {
 	BaseModel
-	Type      string    `json:"type" gorm:"not null"` // email, sms, captcha
-	Target    string    `json:"target" gorm:"not null"` // 邮箱或手机号
-	Code      string    `json:"code" gorm:"not null"`
-	Purpose   string    `json:"purpose" gorm:"not null"` // register, reset_password, login
-	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
-	Used      bool      `json:"used" gorm:"default:false"`
-	IP        string    `json:"ip"`
+	Type        string    `json:"type" gorm:"not null;index"` // 接收方类型：email, phone, user
+	Target      string    `json:"target" gorm:"not null;index"` // 邮箱或手机号
+	Code        string    `json:"-" gorm:"not null"` // 验证码的HMAC摘要
+	Purpose     string    `json:"purpose" gorm:"not null"` // register, reset_password, login
+	Channel     string    `json:"channel"` // email, sms, voice, in_app
+	Status      int       `json:"status" gorm:"default:1;index"` // 1:已发送 2:被拦截 3:发送失败
+	BlockReason string    `json:"block_reason"`
+	ExpiresAt   time.Time `json:"expires_at" gorm:"not null"`
+	Used        bool      `json:"used" gorm:"default:false"`
+	IP          string    `json:"ip" gorm:"index"`
+}
+
+// SMSDelivery 短信发送记录，每条短信一条，记录最终使用的服务商和发送状态，不保存短信内容
+type SMSDelivery struct {
+	BaseModel
+	Phone     string `json:"phone" gorm:"not null;index"`
+	Type      string `json:"type" gorm:"not null"` // verification, notification, login_alert, voice_code
+	Provider  string `json:"provider"` // 发送成功的服务商
+	MessageID string `json:"message_id" gorm:"index"` // 服务商返回的消息ID
+	Status    int    `json:"status" gorm:"default:1;index"` // 1:发送中 2:已提交 3:失败
+	Attempts  int    `json:"attempts"` // 尝试过的服务商数量
+	Error     string `json:"error" gorm:"type:text"` // 失败服务商的错误信息
+}
+
+// OutboundMessage 发送队列中的邮件或短信，发送失败后按指数退避重试，达到最大次数后进入死信
+type OutboundMessage struct {
+	BaseModel
+	Channel       string     `json:"channel" gorm:"not null;index"` // email, sms
+	Recipient     string     `json:"recipient" gorm:"not null;index"`
+	Summary       string     `json:"summary"`            // 邮件标题或短信类型，便于管理员查看
+	Payload       string     `json:"-" gorm:"type:text"` // 发送内容，发送成功或过期后清空，避免验证码等内容长期保存
+	Status        int        `json:"status" gorm:"not null;index"`
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	MaxAttempts   int        `json:"max_attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	ExpiresAt     *time.Time `json:"expires_at"` // 过期后不再发送，用于验证码等有时效的消息
+	SentAt        *time.Time `json:"sent_at"`
+	LastError     string     `json:"last_error" gorm:"type:text"`
+}
+
+// VerificationBlock 验证码发送黑名单
+type VerificationBlock struct {
+	BaseModel
+	Type      string    `json:"type" gorm:"not null;index"` // email, phone
+	Pattern   string    `json:"pattern" gorm:"not null"` // 完整的邮箱或号码；@开头表示整个邮箱域名；*结尾表示号码前缀
+	Reason    string    `json:"reason"`
+	CreatedBy uuid.UUID `json:"created_by" gorm:"type:uuid"`
+}
+
+// PasswordHistory 历史密码模型，用于禁止重复使用最近的密码
+type PasswordHistory struct {
+	BaseModel
+	UserID   uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Password string    `json:"-" gorm:"not null"`
 }
 
 // SystemNotification 系统通知模型
@@ -170,6 +297,62 @@ const (
 	UserStatusLocked   = 3
 )
 
+// 服务账号状态常量
+const (
+	ServiceAccountStatusNormal   = 1
+	ServiceAccountStatusDisabled = 2
+)
+
+// 登录记录状态常量
+const (
+	LoginStatusSuccess   = 1
+	LoginStatusFailed    = 2
+	LoginStatusChallenge = 3
+)
+
+// 验证码发送状态常量
+const (
+	VerificationStatusSent    = 1
+	VerificationStatusBlocked = 2
+	VerificationStatusFailed  = 3
+)
+
+// 短信发送状态常量
+const (
+	SMSStatusPending = 1
+	SMSStatusSent    = 2
+	SMSStatusFailed  = 3
+)
+
+// 发送队列消息状态
+const (
+	OutboundStatusPending    = 1 // 等待发送或等待重试
+	OutboundStatusProcessing = 2
+	OutboundStatusSent       = 3
+	OutboundStatusDead       = 4 // 死信：达到最大发送次数或已过期
+)
+
+// IP规则常量
+const (
+	IPRuleStatusEnabled  = 1
+	IPRuleStatusDisabled = 2
+	
+	IPRuleActionAllow = "allow"
+	IPRuleActionDeny  = "deny"
+	
+	IPRuleScopeGlobal     = "global"      // 所有请求
+	IPRuleScopeLogin      = "login"       // 登录接口
+	IPRuleScopeAdmin      = "admin"       // 管理员和超级管理员接口
+	IPRuleScopeSuperAdmin = "super_admin" // 超级管理员接口
+	IPRuleScopeUser       = "user"        // 个人白名单，仅允许规则
+)
+
+// 主体类型常量
+const (
+	PrincipalTypeUser           = "user"
+	PrincipalTypeServiceAccount = "service_account"
+)
+
 // 性别常量
 const (
 	GenderUnknown = 0
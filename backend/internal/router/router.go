@@ -3,6 +3,7 @@ package router
 import (
 	"usercenter/internal/handler"
 	"usercenter/internal/middleware"
+	"usercenter/internal/models"
 	
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -14,6 +15,12 @@ func SetupRoutes(r *gin.Engine) {
 	authHandler := handler.NewAuthHandler()
 	userHandler := handler.NewUserHandler()
 	adminHandler := handler.NewAdminHandler()
+	oauthHandler := handler.NewOAuthHandler()
+	serviceAccountHandler := handler.NewServiceAccountHandler()
+	ipRuleHandler := handler.NewIPRuleHandler()
+	verificationHandler := handler.NewVerificationHandler()
+	templateHandler := handler.NewTemplateHandler()
+	outboundHandler := handler.NewOutboundHandler()
 	
 	// API版本组
 	api := r.Group("/api/v1")
@@ -25,10 +32,27 @@ func SetupRoutes(r *gin.Engine) {
 			auth := public.Group("/auth")
 			{
 				auth.GET("/captcha", authHandler.GetCaptcha)
-				auth.POST("/send-email-code", authHandler.SendEmailCode)
-				auth.POST("/send-sms-code", authHandler.SendSMSCode)
+				auth.POST("/verification/send", authHandler.SendVerificationCode)
+				auth.POST("/send-verification-code", authHandler.SendVerificationCode)
 				auth.POST("/register", authHandler.Register)
-				auth.POST("/login", authHandler.Login)
+				loginFilter := middleware.IPFilterMiddleware(models.IPRuleScopeLogin)
+				auth.POST("/login", loginFilter, authHandler.Login)
+				auth.POST("/login/verify", loginFilter, authHandler.VerifyLogin)
+				auth.POST("/login/challenge/send-code", authHandler.SendLoginChallengeCode)
+				auth.POST("/login-alert/deny", authHandler.DenyLogin)
+				auth.POST("/reset-password", authHandler.ResetPassword)
+				auth.GET("/password-policy", authHandler.GetPasswordPolicy)
+				
+				// 转发认证（nginx auth_request / Traefik ForwardAuth）
+				auth.Any("/verify", authHandler.Verify)
+			}
+			
+			// OAuth（机器客户端）
+			oauth := public.Group("/oauth")
+			{
+				oauth.POST("/token", oauthHandler.Token)
+				oauth.POST("/introspect", oauthHandler.Introspect)
+				oauth.POST("/revoke", oauthHandler.Revoke)
 			}
 		}
 		
@@ -42,38 +66,73 @@ func SetupRoutes(r *gin.Engine) {
 				auth.POST("/logout", authHandler.Logout)
 				auth.POST("/refresh", authHandler.RefreshToken)
 				auth.GET("/user", authHandler.GetUserInfo)
+				auth.POST("/reauth", authHandler.Reauth)
+				auth.POST("/reauth/send-code", authHandler.SendReauthCode)
 			}
 			
-			// 用户个人中心
+			// 用户个人中心（敏感操作需要最近重新验证过身份）
+			recentAuth := middleware.RequireRecentAuth()
 			profile := protected.Group("/profile")
 			{
 				profile.GET("", userHandler.GetProfile)
 				profile.PUT("", userHandler.UpdateProfile)
-				profile.PUT("/password", userHandler.ChangePassword)
+				profile.PUT("/password", recentAuth, userHandler.ChangePassword)
 				profile.POST("/avatar", userHandler.UploadAvatar)
-				profile.POST("/bind-email", userHandler.BindEmail)
-				profile.POST("/bind-phone", userHandler.BindPhone)
+				profile.POST("/bind-email", recentAuth, userHandler.BindEmail)
+				profile.POST("/bind-phone", recentAuth, userHandler.BindPhone)
 				profile.GET("/devices", userHandler.GetDevices)
-				profile.DELETE("/devices/:device_id", userHandler.RemoveDevice)
+				profile.DELETE("/devices/:device_id", recentAuth, userHandler.RemoveDevice)
 				profile.GET("/logs", userHandler.GetLogs)
+				profile.GET("/login-logs", userHandler.GetLoginLogs)
+				profile.GET("/security-settings", userHandler.GetSecuritySettings)
 			}
 		}
 		
 		// 管理员路由
 		admin := api.Group("/admin")
+		admin.Use(middleware.IPFilterMiddleware(models.IPRuleScopeAdmin))
 		admin.Use(middleware.AuthMiddleware())
 		admin.Use(middleware.AdminMiddleware())
 		{
+			recentAuth := middleware.RequireRecentAuth()
+			
 			// 用户管理
 			users := admin.Group("/users")
 			{
 				users.GET("", adminHandler.GetUsers)
 				users.POST("", adminHandler.CreateUser)
+				users.POST("/import", recentAuth, adminHandler.ImportUsers)
 				users.GET("/:id", adminHandler.GetUser)
 				users.PUT("/:id", adminHandler.UpdateUser)
-				users.DELETE("/:id", adminHandler.DeleteUser)
-				users.PUT("/:id/status", adminHandler.UpdateUserStatus)
-				users.PUT("/:id/reset-password", adminHandler.ResetUserPassword)
+				users.DELETE("/:id", recentAuth, adminHandler.DeleteUser)
+				users.PUT("/:id/status", recentAuth, adminHandler.UpdateUserStatus)
+				users.PUT("/:id/reset-password", recentAuth, adminHandler.ResetUserPassword)
+			}
+			
+			// 登录记录
+			admin.GET("/login-logs", adminHandler.GetLoginLogs)
+			
+			// 验证码发送记录、统计和黑名单
+			verification := admin.Group("/verification")
+			{
+				verification.GET("/records", verificationHandler.GetRecords)
+				verification.GET("/stats", verificationHandler.GetStats)
+				verification.GET("/blocklist", verificationHandler.GetBlocks)
+				verification.POST("/blocklist", recentAuth, verificationHandler.CreateBlock)
+				verification.DELETE("/blocklist/:id", recentAuth, verificationHandler.DeleteBlock)
+			}
+			
+			// 邮件和短信模板
+			admin.GET("/templates", templateHandler.GetTemplates)
+			admin.POST("/templates/preview", templateHandler.PreviewTemplate)
+			
+			// 邮件和短信发送队列
+			outbound := admin.Group("/outbound")
+			{
+				outbound.GET("/messages", outboundHandler.GetMessages)
+				outbound.GET("/stats", outboundHandler.GetStats)
+				outbound.POST("/messages/:id/retry", outboundHandler.RetryMessage)
+				outbound.POST("/retry-dead", outboundHandler.RetryDead)
 			}
 			
 			// 统计信息
@@ -82,11 +141,31 @@ func SetupRoutes(r *gin.Engine) {
 		
 		// 超级管理员路由
 		superAdmin := api.Group("/super-admin")
+		superAdmin.Use(middleware.IPFilterMiddleware(models.IPRuleScopeAdmin, models.IPRuleScopeSuperAdmin))
 		superAdmin.Use(middleware.AuthMiddleware())
 		superAdmin.Use(middleware.SuperAdminMiddleware())
 		{
-			// 系统管理功能
-			// 这里可以添加只有超级管理员才能访问的功能
+			recentAuth := middleware.RequireRecentAuth()
+			
+			// 服务账号管理
+			serviceAccounts := superAdmin.Group("/service-accounts")
+			{
+				serviceAccounts.GET("", serviceAccountHandler.GetServiceAccounts)
+				serviceAccounts.POST("", recentAuth, serviceAccountHandler.CreateServiceAccount)
+				serviceAccounts.GET("/:id", serviceAccountHandler.GetServiceAccount)
+				serviceAccounts.PUT("/:id", recentAuth, serviceAccountHandler.UpdateServiceAccount)
+				serviceAccounts.DELETE("/:id", recentAuth, serviceAccountHandler.DeleteServiceAccount)
+				serviceAccounts.POST("/:id/rotate-secret", recentAuth, serviceAccountHandler.RotateServiceAccountSecret)
+			}
+			
+			// IP访问规则管理
+			ipRules := superAdmin.Group("/ip-rules")
+			{
+				ipRules.GET("", ipRuleHandler.GetIPRules)
+				ipRules.POST("", recentAuth, ipRuleHandler.CreateIPRule)
+				ipRules.PUT("/:id", recentAuth, ipRuleHandler.UpdateIPRule)
+				ipRules.DELETE("/:id", recentAuth, ipRuleHandler.DeleteIPRule)
+			}
 		}
 	}
 	
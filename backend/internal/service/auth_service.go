@@ -3,6 +3,8 @@ package service
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 	
 	"usercenter/internal/cache"
@@ -12,16 +14,24 @@ import (
 	"usercenter/pkg/captcha"
 	"usercenter/pkg/crypto"
 	"usercenter/pkg/email"
+	"usercenter/pkg/geoip"
+	"usercenter/pkg/ipfilter"
 	"usercenter/pkg/jwt"
-	"usercenter/pkg/sms"
+	"usercenter/pkg/phone"
+	"usercenter/pkg/templates"
+	"usercenter/pkg/totp"
 	
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type AuthService struct {
-	emailService *email.EmailService
-	smsService   *sms.SMSService
+	emailService          *email.EmailService
+	verification          *VerificationService
+	passwordPolicyService *PasswordPolicyService
+	loginProtection       *LoginProtectionService
+	loginRisk             *LoginRiskService
+	loginLogs             *LoginLogService
 }
 
 type LoginRequest struct {
@@ -30,18 +40,22 @@ type LoginRequest struct {
 	CaptchaID   string `json:"captcha_id"`
 	CaptchaCode string `json:"captcha_code"`
 	DeviceInfo  DeviceInfo `json:"device_info"`
+	Mode        string `json:"mode"` // token(默认)：返回Token；session：写入HttpOnly会话Cookie
 }
 
 type RegisterRequest struct {
 	Username     string `json:"username" binding:"required,min=3,max=50"`
-	Email        string `json:"email" binding:"email"`
+	Email        string `json:"email" binding:"omitempty,email"`
 	Phone        string `json:"phone"`
-	Password     string `json:"password" binding:"required,min=8"`
+	Password     string `json:"password" binding:"required"`
 	Nickname     string `json:"nickname"`
 	EmailCode    string `json:"email_code"`
 	SMSCode      string `json:"sms_code"`
 	CaptchaID    string `json:"captcha_id" binding:"required"`
 	CaptchaCode  string `json:"captcha_code" binding:"required"`
+	Language     string `json:"language"` // 偏好语言，为空时使用请求的Accept-Language
+	// 仅管理员创建用户时有效：用户首次登录必须修改密码
+	MustChangePassword bool `json:"must_change_password"`
 }
 
 type DeviceInfo struct {
@@ -53,76 +67,225 @@ type DeviceInfo struct {
 }
 
 type LoginResponse struct {
-	Token     string      `json:"token"`
+	Token     string      `json:"token,omitempty"`
+	CSRFToken string      `json:"csrf_token,omitempty"`
 	User      models.User `json:"user"`
 	ExpiresAt int64       `json:"expires_at"`
+	// 为true时Token只能用于修改密码（密码已过期或被要求修改密码）
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+	// 风险登录需要二次验证时不返回Token，使用ChallengeID调用 /auth/login/verify 完成登录
+	ChallengeRequired bool     `json:"challenge_required,omitempty"`
+	ChallengeID       string   `json:"challenge_id,omitempty"`
+	ChallengeMethods  []string `json:"challenge_methods,omitempty"`
+	RiskReasons       []string `json:"risk_reasons,omitempty"`
 }
 
+// LoginChallengeRequest 风险登录二次验证请求
+type LoginChallengeRequest struct {
+	ChallengeID string `json:"challenge_id" binding:"required"`
+	Method      string `json:"method" binding:"required,oneof=totp email_code sms_code"`
+	Code        string `json:"code" binding:"required"`
+	Mode        string `json:"mode"` // 同登录请求的mode
+}
+
+// ResetPasswordRequest 重置密码请求：使用邮箱/短信验证码（type、target、code），
+// 或使用管理员发送的重置链接中的token
+type ResetPasswordRequest struct {
+	Type        string `json:"type" binding:"omitempty,oneof=email phone"`
+	Target      string `json:"target"`
+	Code        string `json:"code"`
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ReauthRequest 敏感操作前的重新验证请求
+type ReauthRequest struct {
+	Method   string `json:"method" binding:"required,oneof=password totp email_code sms_code"`
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// 重新验证方式
+const (
+	ReauthMethodPassword  = "password"
+	ReauthMethodTOTP      = "totp"
+	ReauthMethodEmailCode = "email_code"
+	ReauthMethodSMSCode   = "sms_code"
+)
+
+// 登录图形验证码错误，前端收到后应展示验证码
+var (
+	ErrCaptchaRequired = errors.New("请输入图形验证码")
+	ErrCaptchaInvalid  = errors.New("验证码错误")
+)
+
+// 登录模式
+const (
+	LoginModeToken   = "token"
+	LoginModeSession = "session"
+)
+
 func NewAuthService() *AuthService {
 	cfg := config.GlobalConfig
 	emailSvc := email.NewEmailService(&cfg.SMTP)
-	smsSvc, _ := sms.NewSMSService(&cfg.SMS.Tencent)
 	
 	return &AuthService{
-		emailService: emailSvc,
-		smsService:   smsSvc,
+		emailService:          emailSvc,
+		verification:          NewVerificationService(),
+		passwordPolicyService: NewPasswordPolicyService(),
+		loginProtection:       NewLoginProtectionService(),
+		loginRisk:             NewLoginRiskService(),
+		loginLogs:             NewLoginLogService(),
 	}
 }
 
-// Login 用户登录
+// Login 用户登录，每次尝试都写入登录记录
 func (s *AuthService) Login(req *LoginRequest) (*LoginResponse, error) {
-	// 验证图形验证码
-	if req.CaptchaID != "" && req.CaptchaCode != "" {
+	resp, user, err := s.login(req)
+	s.loginLogs.Record(user, req.Username, LoginMethodPassword, req.DeviceInfo, resp, err)
+	return resp, err
+}
+
+// LoginCaptchaRequired 该登录名或IP下次登录是否需要图形验证码
+func (s *AuthService) LoginCaptchaRequired(username, ip string) bool {
+	return s.loginProtection.CaptchaRequired(username, ip)
+}
+
+// loginPhone 登录名可以是国内格式或国际格式的手机号，能解析为号码时转换为保存的E.164格式
+func loginPhone(username string) string {
+	if number, err := phone.Normalize(username); err == nil {
+		return number
+	}
+	return username
+}
+
+// login 校验密码并完成登录，返回的用户用于写入登录记录，账号不存在时为nil
+func (s *AuthService) login(req *LoginRequest) (*LoginResponse, *models.User, error) {
+	// 提交了图形验证码时总是校验，验证码只能使用一次
+	captchaPassed := false
+	if req.CaptchaID != "" || req.CaptchaCode != "" {
 		if !captcha.VerifyImageCaptcha(req.CaptchaID, req.CaptchaCode) {
-			return nil, errors.New("验证码错误")
+			return nil, nil, ErrCaptchaInvalid
 		}
+		captchaPassed = true
+	}
+	
+	// 检查IP是否因撞库被暂时封禁
+	if err := s.loginProtection.CheckIP(req.DeviceInfo.IP); err != nil {
+		return nil, nil, err
+	}
+	
+	// 登录名或IP近期失败次数较多时需要图形验证码，在查找用户之前判断，不暴露账号是否存在
+	if !captchaPassed && s.loginProtection.CaptchaRequired(req.Username, req.DeviceInfo.IP) {
+		return nil, nil, ErrCaptchaRequired
 	}
 	
 	// 查找用户
 	var user models.User
-	err := database.DB.Preload("Roles").Where("username = ? OR email = ? OR phone = ?", 
-		req.Username, req.Username, req.Username).First(&user).Error
+	err := database.DB.Preload("Roles").Where("username = ? OR LOWER(email) = ? OR phone = ?", 
+		req.Username, strings.ToLower(req.Username), loginPhone(req.Username)).First(&user).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, errors.New("用户名或密码错误")
+			s.loginProtection.RecordFailure(nil, req.Username, req.DeviceInfo.IP)
+			return nil, nil, errors.New("用户名或密码错误")
 		}
-		return nil, err
+		return nil, nil, err
 	}
 	
 	// 检查账号状态
 	if user.Status == models.UserStatusDisabled {
-		return nil, errors.New("账号已被禁用")
+		return nil, &user, errors.New("账号已被禁用")
+	}
+	
+	// 检查账号是否被锁定或处于渐进延迟中（锁定到期自动解锁）
+	if err := s.loginProtection.CheckAccount(&user); err != nil {
+		return nil, &user, err
 	}
 	
-	// 检查账号是否被锁定
-	if user.Status == models.UserStatusLocked && user.LockedUntil != nil && 
-		time.Now().Before(*user.LockedUntil) {
-		return nil, fmt.Errorf("账号已被锁定，请在 %s 后重试", user.LockedUntil.Format("2006-01-02 15:04:05"))
+	// 设置了个人IP白名单的账号只能从白名单内的IP登录。在验证密码之前检查并按用户不存在处理，
+	// 避免白名单外的IP借不同的提示确认密码是否正确；只计入登录名和IP的失败次数，不影响账号本身
+	if !ipfilter.UserAllowed(user.ID, req.DeviceInfo.IP) {
+		s.loginProtection.RecordFailure(nil, req.Username, req.DeviceInfo.IP)
+		return nil, &user, errors.New("用户名或密码错误")
 	}
 	
 	// 验证密码
 	isValid, err := crypto.VerifyPassword(req.Password, user.Password)
 	if err != nil {
-		return nil, err
+		return nil, &user, err
 	}
 	
 	if !isValid {
 		// 记录登录失败
-		s.recordLoginFailure(&user, req.DeviceInfo.IP)
-		return nil, errors.New("用户名或密码错误")
+		s.loginProtection.RecordFailure(&user, req.Username, req.DeviceInfo.IP)
+		return nil, &user, errors.New("用户名或密码错误")
 	}
 	
 	// 重置登录失败次数
-	s.resetLoginFailures(&user)
+	s.loginProtection.RecordSuccess(&user, req.Username)
+	
+	// 旧格式（bcrypt、MD5）或旧参数的哈希在登录成功后升级为当前的argon2id参数，
+	// 只在密码未被同时修改时替换
+	if crypto.NeedsRehash(user.Password) {
+		if hashedPassword, err := crypto.HashPassword(req.Password); err == nil {
+			database.DB.Model(&models.User{}).
+				Where("id = ? AND password = ?", user.ID, user.Password).
+				Update("password", hashedPassword)
+			user.Password = hashedPassword
+		}
+	}
+	
+	// 检查现有密码是否已泄露并标记，客户端可据此提示用户修改密码
+	if config.GlobalConfig.Security.BreachedPasswords.FlagOnLogin && !user.PasswordBreached {
+		if breached, _ := s.passwordPolicyService.IsBreached(req.Password); breached {
+			user.PasswordBreached = true
+			database.DB.Model(&user).Update("password_breached", true)
+		}
+	}
+	
+	// 评估登录风险，需在更新最后登录信息和设备记录之前进行
+	assessment := s.loginRisk.Assess(&user, req.DeviceInfo)
+	if s.loginRisk.RequireSecondFactor(assessment) {
+		if methods := s.loginRisk.ChallengeMethods(&user); len(methods) > 0 {
+			challengeID, err := s.loginRisk.StartChallenge(&user, req.DeviceInfo, assessment)
+			if err != nil {
+				return nil, &user, err
+			}
+			return &LoginResponse{
+				ChallengeRequired: true,
+				ChallengeID:       challengeID,
+				ChallengeMethods:  methods,
+				RiskReasons:       assessment.Reasons,
+			}, &user, nil
+		}
+	}
 	
-	// 更新最后登录信息
+	resp, err := s.completeLogin(&user, req.DeviceInfo, assessment)
+	return resp, &user, err
+}
+
+// completeLogin 密码（及风险登录的二次验证）验证通过后完成登录：更新登录信息、记录设备、签发Token，
+// 风险登录向用户发送提醒
+func (s *AuthService) completeLogin(user *models.User, deviceInfo DeviceInfo, assessment *RiskAssessment) (*LoginResponse, error) {
+	// 更新最后登录信息，只更新登录相关字段，避免覆盖同时发生的锁定、改密或管理员修改
 	now := time.Now()
 	user.LastLoginAt = &now
-	user.LastLoginIP = req.DeviceInfo.IP
-	database.DB.Save(&user)
+	user.LastLoginIP = deviceInfo.IP
+	user.LastLoginLocation = geoip.Lookup(deviceInfo.IP)
+	location := user.LastLoginLocation
+	database.DB.Model(user).Updates(map[string]interface{}{
+		"last_login_at":           now,
+		"last_login_ip":           user.LastLoginIP,
+		"last_login_country":      location.Country,
+		"last_login_country_code": location.CountryCode,
+		"last_login_region":       location.Region,
+		"last_login_city":         location.City,
+		"last_login_asn":          location.ASN,
+		"last_login_as_org":       location.ASOrg,
+	})
 	
 	// 记录设备信息
-	s.recordDeviceInfo(&user, req.DeviceInfo)
+	s.recordDeviceInfo(user, deviceInfo)
 	
 	// 获取用户角色
 	var roleCode string
@@ -130,19 +293,145 @@ func (s *AuthService) Login(req *LoginRequest) (*LoginResponse, error) {
 		roleCode = user.Roles[0].Code
 	}
 	
-	// 生成Token
-	token, err := jwt.GenerateToken(user.ID, user.Username, roleCode, req.DeviceInfo.DeviceID)
+	var resp *LoginResponse
+	if user.MustChangePassword || s.passwordPolicyService.IsPasswordExpired(user) {
+		// 被要求修改密码或密码已过期时只签发修改密码用的受限Token
+		var err error
+		resp, err = s.passwordChangeLogin(user, deviceInfo.DeviceID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// 生成Token
+		token, err := jwt.GenerateToken(user.ID, user.Username, roleCode, deviceInfo.DeviceID)
+		if err != nil {
+			return nil, err
+		}
+		
+		// 刚完成登录视为最近已验证身份
+		if claims, err := jwt.ParseToken(token); err == nil {
+			markRecentAuth(user.ID, claims.ID)
+		}
+		
+		resp = &LoginResponse{
+			Token:     token,
+			User:      *user,
+			ExpiresAt: time.Now().Add(config.GlobalConfig.JWT.Expires).Unix(),
+		}
+	}
+	
+	if assessment.Risky() {
+		resp.RiskReasons = assessment.Reasons
+		go s.loginRisk.Alert(user, deviceInfo, assessment)
+	}
+	
+	return resp, nil
+}
+
+// VerifyLoginChallenge 完成风险登录的二次验证，验证结果写入登录记录
+func (s *AuthService) VerifyLoginChallenge(req *LoginChallengeRequest) (*LoginResponse, error) {
+	challenge, err := s.loginRisk.loadChallenge(req.ChallengeID)
+	if err != nil {
+		return nil, err
+	}
+	
+	var user models.User
+	if err := database.DB.Preload("Roles").Where("id = ?", challenge.UserID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	
+	resp, err := s.verifyLoginChallenge(req, challenge, &user)
+	s.loginLogs.Record(&user, "", req.Method, challenge.DeviceInfo, resp, err)
+	return resp, err
+}
+
+// verifyLoginChallenge 校验二次验证码并完成登录
+func (s *AuthService) verifyLoginChallenge(req *LoginChallengeRequest, challenge *loginChallenge, user *models.User) (*LoginResponse, error) {
+	if user.Status == models.UserStatusDisabled {
+		return nil, errors.New("账号已被禁用")
+	}
+	
+	var verified bool
+	switch req.Method {
+	case ReauthMethodTOTP:
+		verified = user.TwoFactorEnabled && user.TwoFactorSecret != "" && totp.Validate(user.TwoFactorSecret, req.Code)
+	case ReauthMethodEmailCode:
+		verified = user.Email != "" && s.verification.Verify(VerificationTargetEmail, user.Email, req.Code, "login_challenge")
+	case ReauthMethodSMSCode:
+		verified = user.Phone != "" && s.verification.Verify(VerificationTargetPhone, user.Phone, req.Code, "login_challenge")
+	default:
+		return nil, errors.New("不支持的验证方式")
+	}
+	
+	if !verified {
+		// 失败次数过多时作废本次验证，需重新输入密码登录
+		failureKey := "login_challenge_failures:" + req.ChallengeID
+		count, _ := cache.Incr(failureKey)
+		cache.Expire(failureKey, loginChallengeTTL)
+		if count >= int64(config.GlobalConfig.Security.MaxLoginAttempts) {
+			cache.Del("login_challenge:" + req.ChallengeID)
+			cache.Del(failureKey)
+			s.loginProtection.RecordFailure(user, "", challenge.DeviceInfo.IP)
+		}
+		return nil, errors.New("验证码错误")
+	}
+	
+	cache.Del("login_challenge:" + req.ChallengeID)
+	cache.Del("login_challenge_failures:" + req.ChallengeID)
+	
+	return s.completeLogin(user, challenge.DeviceInfo, &RiskAssessment{Reasons: challenge.Reasons})
+}
+
+// SendLoginChallengeCode 向用户已绑定的邮箱或手机号发送风险登录的验证码
+func (s *AuthService) SendLoginChallengeCode(challengeID, method string) error {
+	challenge, err := s.loginRisk.loadChallenge(challengeID)
+	if err != nil {
+		return err
+	}
+	
+	var user models.User
+	if err := database.DB.Where("id = ?", challenge.UserID).First(&user).Error; err != nil {
+		return err
+	}
+	
+	switch method {
+	case ReauthMethodEmailCode:
+		if user.Email == "" {
+			return errors.New("未绑定邮箱")
+		}
+		return s.verification.Send(VerificationTargetEmail, user.Email, "login_challenge", "", user.Language)
+	case ReauthMethodSMSCode:
+		if user.Phone == "" {
+			return errors.New("未绑定手机号")
+		}
+		return s.verification.Send(VerificationTargetPhone, user.Phone, "login_challenge", "", user.Language)
+	default:
+		return errors.New("不支持的验证方式")
+	}
+}
+
+// DenyLogin 用户通过登录提醒中的链接确认不是本人登录
+func (s *AuthService) DenyLogin(token string) error {
+	return s.loginRisk.DenyLogin(token)
+}
+
+// passwordChangeLogin 签发只能用于修改密码的受限Token
+func (s *AuthService) passwordChangeLogin(user *models.User, deviceID string) (*LoginResponse, error) {
+	token, expiresAt, err := jwt.GeneratePasswordChangeToken(user.ID, user.Username, deviceID)
 	if err != nil {
 		return nil, err
 	}
 	
-	// 计算过期时间
-	expiresAt := time.Now().Add(config.GlobalConfig.JWT.Expires).Unix()
+	// 修改密码接口要求最近验证过身份，刚用密码登录即满足
+	if claims, err := jwt.ParseToken(token); err == nil {
+		markRecentAuth(user.ID, claims.ID)
+	}
 	
 	return &LoginResponse{
-		Token:     token,
-		User:      user,
-		ExpiresAt: expiresAt,
+		Token:              token,
+		User:               *user,
+		ExpiresAt:          expiresAt.Unix(),
+		MustChangePassword: true,
 	}, nil
 }
 
@@ -153,12 +442,35 @@ func (s *AuthService) Register(req *RegisterRequest) error {
 		return errors.New("验证码错误")
 	}
 	
+	// 邮箱统一以小写地址、手机号统一以E.164格式保存和校验验证码
+	if req.Email != "" {
+		address, err := email.NormalizeAddress(req.Email)
+		if err != nil {
+			return err
+		}
+		req.Email = address
+	}
+	if req.Phone != "" {
+		number, err := phone.Normalize(req.Phone)
+		if err != nil {
+			return err
+		}
+		req.Phone = number
+	}
+	
+	// 校验密码策略（注册用户使用普通用户角色的策略）
+	policy := s.passwordPolicyService.PolicyForRoles([]string{"user"})
+	owner := &PasswordOwner{Username: req.Username, Email: req.Email, Phone: req.Phone}
+	if err := s.passwordPolicyService.Validate(&policy, req.Password, owner); err != nil {
+		return err
+	}
+	
 	// 验证邮箱验证码（如果提供了邮箱）
 	if req.Email != "" {
 		if req.EmailCode == "" {
 			return errors.New("请输入邮箱验证码")
 		}
-		if !captcha.VerifyEmailCode(req.Email, req.EmailCode, "register") {
+		if !s.verification.Verify(VerificationTargetEmail, req.Email, req.EmailCode, "register") {
 			return errors.New("邮箱验证码错误或已过期")
 		}
 	}
@@ -168,7 +480,7 @@ func (s *AuthService) Register(req *RegisterRequest) error {
 		if req.SMSCode == "" {
 			return errors.New("请输入短信验证码")
 		}
-		if !captcha.VerifySMSCode(req.Phone, req.SMSCode, "register") {
+		if !s.verification.Verify(VerificationTargetPhone, req.Phone, req.SMSCode, "register") {
 			return errors.New("短信验证码错误或已过期")
 		}
 	}
@@ -182,7 +494,7 @@ func (s *AuthService) Register(req *RegisterRequest) error {
 	
 	// 检查邮箱是否已存在
 	if req.Email != "" {
-		err = database.DB.Where("email = ?", req.Email).First(&existingUser).Error
+		err = database.DB.Where("LOWER(email) = ?", req.Email).First(&existingUser).Error
 		if err == nil {
 			return errors.New("邮箱已存在")
 		}
@@ -203,15 +515,18 @@ func (s *AuthService) Register(req *RegisterRequest) error {
 	}
 	
 	// 创建用户
+	now := time.Now()
 	user := models.User{
-		Username:      req.Username,
-		Email:         req.Email,
-		Phone:         req.Phone,
-		Password:      hashedPassword,
-		Nickname:      req.Nickname,
-		Status:        models.UserStatusNormal,
-		EmailVerified: req.Email != "" && req.EmailCode != "",
-		PhoneVerified: req.Phone != "" && req.SMSCode != "",
+		Username:          req.Username,
+		Email:             req.Email,
+		Phone:             req.Phone,
+		Password:          hashedPassword,
+		Nickname:          req.Nickname,
+		Language:          templates.NormalizeLocale(req.Language),
+		Status:            models.UserStatusNormal,
+		EmailVerified:     req.Email != "" && req.EmailCode != "",
+		PhoneVerified:     req.Phone != "" && req.SMSCode != "",
+		PasswordChangedAt: &now,
 	}
 	
 	if req.Nickname == "" {
@@ -244,60 +559,129 @@ func (s *AuthService) Register(req *RegisterRequest) error {
 		return err
 	}
 	
+	// 记录密码历史
+	if err := s.passwordPolicyService.RecordPasswordHistory(tx, user.ID, hashedPassword); err != nil {
+		tx.Rollback()
+		return err
+	}
+	
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		return err
 	}
 	
-	// 发送欢迎邮件
+	// 发送欢迎邮件，邮件加入发送队列，发送失败由队列重试，不影响注册结果
 	if req.Email != "" {
-		go func() {
-			s.emailService.SendWelcomeEmail(req.Email, user.Username)
-		}()
+		s.emailService.SendWelcomeEmail(req.Email, user.Username, user.Language)
 	}
 	
 	return nil
 }
 
-// SendEmailCode 发送邮箱验证码
-func (s *AuthService) SendEmailCode(email, purpose string) error {
-	// 检查发送频率
-	canSend, remaining := captcha.CheckCodeSendFrequency(email, "email")
-	if !canSend {
-		return fmt.Errorf("发送过于频繁，请在 %d 秒后重试", int(remaining.Seconds()))
+// ResetPassword 重置密码，使用用途为reset_password的验证码或管理员发送的重置链接令牌
+func (s *AuthService) ResetPassword(req *ResetPasswordRequest) error {
+	user, err := s.findPasswordResetUser(req)
+	if err != nil {
+		return err
+	}
+	
+	targetType := VerificationTargetEmail
+	if req.Type == "phone" {
+		targetType = VerificationTargetPhone
+	}
+	
+	// 先确认验证码正确再校验密码策略和密码历史，否则密码不合规的提示只会对存在的账号返回，
+	// 可被用来探测账号和管理员角色。验证码在密码校验通过后才使用，密码不合规时可修改后重试
+	if req.Token == "" && !s.verification.Check(targetType, req.Target, req.Code, "reset_password") {
+		return errors.New("验证码错误或已过期")
+	}
+	if err := s.passwordPolicyService.ValidateForUser(user.ID, req.NewPassword); err != nil {
+		return err
+	}
+	if err := s.passwordPolicyService.CheckPasswordHistory(user, req.NewPassword); err != nil {
+		return err
+	}
+	
+	if req.Token != "" {
+		if err := revokePasswordResetToken(req.Token); err != nil {
+			return err
+		}
+	} else if !s.verification.Verify(targetType, req.Target, req.Code, "reset_password") {
+		return errors.New("验证码错误或已过期")
+	}
+	
+	hashedPassword, err := crypto.HashPassword(req.NewPassword)
+	if err != nil {
+		return err
 	}
 	
-	// 生成验证码
-	code, err := captcha.GenerateEmailCode(email, purpose)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(user).Updates(map[string]interface{}{
+			"password":             hashedPassword,
+			"password_changed_at":  time.Now(),
+			"password_breached":    false,
+			"must_change_password": false,
+			"login_attempts":       0,
+			"locked_until":         nil,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return s.passwordPolicyService.RecordPasswordHistory(tx, user.ID, hashedPassword)
+	})
 	if err != nil {
 		return err
 	}
 	
-	// 发送邮件
-	return s.emailService.SendVerificationCode(email, code, purpose)
+	markMustChangePassword(user.ID, false)
+	return nil
 }
 
-// SendSMSCode 发送短信验证码
-func (s *AuthService) SendSMSCode(phone, purpose string) error {
-	// 验证手机号格式
-	if !sms.ValidatePhoneNumber(phone) {
-		return errors.New("手机号格式不正确")
+// findPasswordResetUser 根据重置令牌或邮箱/手机号查找要重置密码的用户
+func (s *AuthService) findPasswordResetUser(req *ResetPasswordRequest) (*models.User, error) {
+	var user models.User
+	if req.Token != "" {
+		userID, err := lookupPasswordResetToken(req.Token)
+		if err != nil {
+			return nil, err
+		}
+		if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+			return nil, errors.New("重置链接无效或已过期")
+		}
+		return &user, nil
 	}
 	
-	// 检查发送频率
-	canSend, remaining := captcha.CheckCodeSendFrequency(phone, "sms")
-	if !canSend {
-		return fmt.Errorf("发送过于频繁，请在 %d 秒后重试", int(remaining.Seconds()))
+	if req.Type == "" || req.Target == "" || req.Code == "" {
+		return nil, errors.New("请提供验证码或重置链接")
 	}
 	
-	// 生成验证码
-	code, err := captcha.GenerateSMSCode(phone, purpose)
+	// 转换为发送验证码时使用的小写地址或E.164格式，后续校验验证码使用同一接收方。
+	// 统一小写前保存的邮箱可能含大写字母，按小写比较
+	field := "LOWER(email)"
+	normalize := email.NormalizeAddress
+	if req.Type == "phone" {
+		field = "phone"
+		normalize = phone.Normalize
+	}
+	target, err := normalize(req.Target)
 	if err != nil {
-		return err
+		return nil, errors.New("验证码错误或已过期")
 	}
+	req.Target = target
 	
-	// 发送短信
-	return s.smsService.SendVerificationCode(phone, code)
+	// 用户不存在时返回与验证码错误相同的提示，避免泄露账号是否存在
+	if err := database.DB.Where(field+" = ?", req.Target).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.New("验证码错误或已过期")
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SendVerificationCode 未登录用户通过通用接口发送验证码
+func (s *AuthService) SendVerificationCode(req *SendVerificationRequest) error {
+	return s.verification.SendPublic(req)
 }
 
 // Logout 用户登出
@@ -327,6 +711,11 @@ func (s *AuthService) RefreshToken(token string) (string, error) {
 		return "", err
 	}
 	
+	// 受限Token不能刷新
+	if claims.IsPasswordChangeOnly() {
+		return "", errors.New("请先修改密码")
+	}
+	
 	// 检查Token是否在黑名单中
 	blacklistKey := "token_blacklist:" + token
 	exists, _ := cache.Exists(blacklistKey)
@@ -334,6 +723,11 @@ func (s *AuthService) RefreshToken(token string) (string, error) {
 		return "", errors.New("Token已失效")
 	}
 	
+	// 注销全部登录前签发的Token不能刷新
+	if jwt.IsRevoked(claims) {
+		return "", errors.New("Token已失效")
+	}
+	
 	// 生成新Token
 	newToken, err := jwt.GenerateToken(claims.UserID, claims.Username, claims.Role, claims.DeviceID)
 	if err != nil {
@@ -347,30 +741,97 @@ func (s *AuthService) RefreshToken(token string) (string, error) {
 	return newToken, nil
 }
 
-// recordLoginFailure 记录登录失败
-func (s *AuthService) recordLoginFailure(user *models.User, ip string) {
-	user.LoginAttempts++
+// revokeUserTokens 使用户此前签发的所有Token（包括会话Cookie和已刷新的Token）失效，
+// 记录保留到最后一个Token过期
+func revokeUserTokens(userID uuid.UUID) error {
+	expiration := config.GlobalConfig.JWT.Expires
+	if config.GlobalConfig.JWT.PasswordChangeTokenExpires > expiration {
+		expiration = config.GlobalConfig.JWT.PasswordChangeTokenExpires
+	}
+	return jwt.RevokeUserTokens(userID, expiration)
+}
+
+// Reauthenticate 敏感操作前重新验证身份（sudo模式），成功后在时间窗口内允许执行敏感操作
+func (s *AuthService) Reauthenticate(userID uuid.UUID, tokenID string, req *ReauthRequest) (time.Time, error) {
+	// 限制重新验证的失败次数，防止借此暴力破解密码
+	failureKey := "reauth_failures:" + userID.String()
+	if failures, _ := cache.Get(failureKey); failures != "" {
+		if count, _ := strconv.Atoi(failures); count >= config.GlobalConfig.Security.MaxLoginAttempts {
+			return time.Time{}, errors.New("验证失败次数过多，请稍后重试")
+		}
+	}
+	
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return time.Time{}, err
+	}
+	
+	var verified bool
+	switch req.Method {
+	case ReauthMethodPassword:
+		isValid, err := crypto.VerifyPassword(req.Password, user.Password)
+		if err != nil {
+			return time.Time{}, err
+		}
+		verified = isValid
+	case ReauthMethodTOTP:
+		if !user.TwoFactorEnabled || user.TwoFactorSecret == "" {
+			return time.Time{}, errors.New("未启用两步验证")
+		}
+		verified = totp.Validate(user.TwoFactorSecret, req.Code)
+	case ReauthMethodEmailCode:
+		if user.Email == "" {
+			return time.Time{}, errors.New("未绑定邮箱")
+		}
+		verified = s.verification.Verify(VerificationTargetEmail, user.Email, req.Code, "reauth")
+	case ReauthMethodSMSCode:
+		if user.Phone == "" {
+			return time.Time{}, errors.New("未绑定手机号")
+		}
+		verified = s.verification.Verify(VerificationTargetPhone, user.Phone, req.Code, "reauth")
+	default:
+		return time.Time{}, errors.New("不支持的验证方式")
+	}
 	
-	// 如果失败次数达到限制，锁定账号
-	maxAttempts := config.GlobalConfig.Security.MaxLoginAttempts
-	if user.LoginAttempts >= maxAttempts {
-		lockDuration := config.GlobalConfig.Security.LockDuration
-		lockedUntil := time.Now().Add(lockDuration)
-		user.LockedUntil = &lockedUntil
-		user.Status = models.UserStatusLocked
+	if !verified {
+		cache.Incr(failureKey)
+		cache.Expire(failureKey, config.GlobalConfig.Security.LockDuration)
+		return time.Time{}, errors.New("身份验证失败")
 	}
 	
-	database.DB.Save(user)
+	cache.Del(failureKey)
+	markRecentAuth(userID, tokenID)
+	
+	return time.Now().Add(config.GlobalConfig.Security.ReauthWindow), nil
 }
 
-// resetLoginFailures 重置登录失败次数
-func (s *AuthService) resetLoginFailures(user *models.User) {
-	user.LoginAttempts = 0
-	user.LockedUntil = nil
-	if user.Status == models.UserStatusLocked {
-		user.Status = models.UserStatusNormal
+// SendReauthCode 向用户已绑定的邮箱或手机号发送重新验证身份的验证码
+func (s *AuthService) SendReauthCode(userID uuid.UUID, method string) error {
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+	
+	switch method {
+	case ReauthMethodEmailCode:
+		if user.Email == "" {
+			return errors.New("未绑定邮箱")
+		}
+		return s.verification.Send(VerificationTargetEmail, user.Email, "reauth", "", user.Language)
+	case ReauthMethodSMSCode:
+		if user.Phone == "" {
+			return errors.New("未绑定手机号")
+		}
+		return s.verification.Send(VerificationTargetPhone, user.Phone, "reauth", "", user.Language)
+	default:
+		return errors.New("不支持的验证方式")
 	}
-	database.DB.Save(user)
+}
+
+// markRecentAuth 记录当前会话（按Token的jti区分）最近一次验证身份的时间
+func markRecentAuth(userID uuid.UUID, tokenID string) {
+	key := fmt.Sprintf("reauth:%s:%s", userID, tokenID)
+	cache.Set(key, time.Now().Unix(), config.GlobalConfig.Security.ReauthWindow)
 }
 
 // recordDeviceInfo 记录设备信息
@@ -378,6 +839,7 @@ func (s *AuthService) recordDeviceInfo(user *models.User, deviceInfo DeviceInfo)
 	// 查找或创建设备记录
 	var device models.UserDevice
 	err := database.DB.Where("user_id = ? AND device_id = ?", user.ID, deviceInfo.DeviceID).First(&device).Error
+	location := geoip.Lookup(deviceInfo.IP)
 	
 	if err == gorm.ErrRecordNotFound {
 		// 创建新设备记录
@@ -390,6 +852,7 @@ func (s *AuthService) recordDeviceInfo(user *models.User, deviceInfo DeviceInfo)
 			UserAgent:  deviceInfo.UserAgent,
 			LastActive: time.Now(),
 			IsActive:   true,
+			Location:   location,
 		}
 		database.DB.Create(&device)
 	} else {
@@ -398,6 +861,7 @@ func (s *AuthService) recordDeviceInfo(user *models.User, deviceInfo DeviceInfo)
 		device.UserAgent = deviceInfo.UserAgent
 		device.LastActive = time.Now()
 		device.IsActive = true
+		device.Location = location
 		database.DB.Save(&device)
 	}
 }
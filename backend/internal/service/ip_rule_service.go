@@ -0,0 +1,222 @@
+package service
+
+import (
+	"errors"
+	
+	"usercenter/internal/database"
+	"usercenter/internal/models"
+	"usercenter/pkg/ipfilter"
+	
+	"github.com/google/uuid"
+)
+
+// IPRuleService IP访问规则管理，规则变更后通知所有实例重新加载
+type IPRuleService struct {
+}
+
+// IPRuleRequest 创建或更新IP规则的请求，scope为user时是user_id对应用户的个人白名单
+type IPRuleRequest struct {
+	CIDR        string     `json:"cidr" binding:"required"`
+	Action      string     `json:"action" binding:"required,oneof=allow deny"`
+	Scope       string     `json:"scope" binding:"required,oneof=global login admin super_admin user"`
+	UserID      *uuid.UUID `json:"user_id"`
+	Description string     `json:"description"`
+	Status      int        `json:"status" binding:"omitempty,oneof=1 2"`
+}
+
+type IPRuleListQuery struct {
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+	Scope    string `form:"scope"`
+	Action   string `form:"action"`
+	UserID   string `form:"user_id"`
+}
+
+type IPRuleListResponse struct {
+	Total int64           `json:"total"`
+	Items []models.IPRule `json:"items"`
+}
+
+// IPRuleOperator 操作者信息，用于防止规则变更把操作者自己挡在管理后台之外
+type IPRuleOperator struct {
+	UserID uuid.UUID
+	IP     string
+}
+
+func NewIPRuleService() *IPRuleService {
+	return &IPRuleService{}
+}
+
+// List 获取IP规则列表
+func (s *IPRuleService) List(query *IPRuleListQuery) (*IPRuleListResponse, error) {
+	var rules []models.IPRule
+	var total int64
+	
+	db := database.DB.Model(&models.IPRule{})
+	if query.Scope != "" {
+		db = db.Where("scope = ?", query.Scope)
+	}
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+	if query.UserID != "" {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	
+	db.Count(&total)
+	
+	offset := (query.Page - 1) * query.PageSize
+	err := db.Offset(offset).Limit(query.PageSize).Order("created_at desc").Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	
+	return &IPRuleListResponse{
+		Total: total,
+		Items: rules,
+	}, nil
+}
+
+// Get 获取IP规则详情
+func (s *IPRuleService) Get(id uuid.UUID) (*models.IPRule, error) {
+	var rule models.IPRule
+	if err := database.DB.Where("id = ?", id).First(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// Create 创建IP规则
+func (s *IPRuleService) Create(req *IPRuleRequest, operator *IPRuleOperator) (*models.IPRule, error) {
+	rule := models.IPRule{CreatedBy: operator.UserID}
+	if err := s.apply(&rule, req); err != nil {
+		return nil, err
+	}
+	
+	if err := s.checkLockout(operator, func(rules []models.IPRule) []models.IPRule {
+		return append(rules, rule)
+	}); err != nil {
+		return nil, err
+	}
+	
+	if err := database.DB.Create(&rule).Error; err != nil {
+		return nil, err
+	}
+	
+	ipfilter.Invalidate()
+	return &rule, nil
+}
+
+// Update 更新IP规则
+func (s *IPRuleService) Update(id uuid.UUID, req *IPRuleRequest, operator *IPRuleOperator) error {
+	rule, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if err := s.apply(rule, req); err != nil {
+		return err
+	}
+	
+	if err := s.checkLockout(operator, func(rules []models.IPRule) []models.IPRule {
+		return append(withoutRule(rules, id), *rule)
+	}); err != nil {
+		return err
+	}
+	
+	if err := database.DB.Save(rule).Error; err != nil {
+		return err
+	}
+	
+	ipfilter.Invalidate()
+	return nil
+}
+
+// Delete 删除IP规则
+func (s *IPRuleService) Delete(id uuid.UUID, operator *IPRuleOperator) error {
+	rule, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	
+	if err := s.checkLockout(operator, func(rules []models.IPRule) []models.IPRule {
+		return withoutRule(rules, id)
+	}); err != nil {
+		return err
+	}
+	
+	if err := database.DB.Delete(rule).Error; err != nil {
+		return err
+	}
+	
+	ipfilter.Invalidate()
+	return nil
+}
+
+// apply 校验请求并写入规则
+func (s *IPRuleService) apply(rule *models.IPRule, req *IPRuleRequest) error {
+	network, err := ipfilter.ParseCIDR(req.CIDR)
+	if err != nil {
+		return err
+	}
+	
+	if req.Scope == models.IPRuleScopeUser {
+		if req.UserID == nil {
+			return errors.New("个人白名单需要指定用户")
+		}
+		if req.Action != models.IPRuleActionAllow {
+			return errors.New("个人白名单只支持允许规则")
+		}
+		var user models.User
+		if err := database.DB.Where("id = ?", *req.UserID).First(&user).Error; err != nil {
+			return errors.New("用户不存在")
+		}
+		rule.UserID = req.UserID
+	} else {
+		if req.UserID != nil {
+			return errors.New("只有个人白名单可以指定用户")
+		}
+		rule.UserID = nil
+	}
+	
+	rule.CIDR = network.String()
+	rule.Action = req.Action
+	rule.Scope = req.Scope
+	rule.Description = req.Description
+	rule.Status = models.IPRuleStatusEnabled
+	if req.Status > 0 {
+		rule.Status = req.Status
+	}
+	
+	return nil
+}
+
+// checkLockout 检查变更后的规则是否仍允许操作者从当前IP访问超级管理员接口
+func (s *IPRuleService) checkLockout(operator *IPRuleOperator, change func([]models.IPRule) []models.IPRule) error {
+	var rules []models.IPRule
+	if err := database.DB.Where("status = ?", models.IPRuleStatusEnabled).Find(&rules).Error; err != nil {
+		return err
+	}
+	
+	set := ipfilter.Compile(change(rules))
+	for _, scope := range []string{models.IPRuleScopeGlobal, models.IPRuleScopeAdmin, models.IPRuleScopeSuperAdmin} {
+		if !set.Allowed(scope, operator.IP) {
+			return errors.New("该变更会导致当前IP无法访问管理接口，请先添加包含当前IP的允许规则")
+		}
+	}
+	if !set.UserAllowed(operator.UserID, operator.IP) {
+		return errors.New("该变更会导致当前IP无法使用您的账号，请先添加包含当前IP的个人白名单")
+	}
+	
+	return nil
+}
+
+// withoutRule 从规则列表中移除指定规则
+func withoutRule(rules []models.IPRule, id uuid.UUID) []models.IPRule {
+	result := make([]models.IPRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.ID != id {
+			result = append(result, rule)
+		}
+	}
+	return result
+}
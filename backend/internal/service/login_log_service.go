@@ -0,0 +1,138 @@
+package service
+
+import (
+	"strings"
+	"time"
+	
+	"usercenter/internal/database"
+	"usercenter/internal/models"
+	"usercenter/pkg/geoip"
+	
+	"github.com/google/uuid"
+)
+
+// 登录方式
+const (
+	LoginMethodPassword = "password"
+)
+
+// LoginLogService 登录记录：记录每次登录的结果、方式、IP、地理位置、设备和失败原因
+type LoginLogService struct {
+}
+
+type LoginLogQuery struct {
+	Page      int       `form:"page"`
+	PageSize  int       `form:"page_size"`
+	UserID    string    `form:"user_id"`  // 仅管理员查询时有效
+	Username  string    `form:"username"` // 仅管理员查询时有效
+	Status    int       `form:"status"`
+	Method    string    `form:"method"`
+	IP        string    `form:"ip"`
+	Country   string    `form:"country"`
+	StartDate time.Time `form:"start_date" time_format:"2006-01-02"`
+	EndDate   time.Time `form:"end_date" time_format:"2006-01-02"`
+}
+
+type LoginLogListResponse struct {
+	Total int64             `json:"total"`
+	Items []models.LoginLog `json:"items"`
+}
+
+func NewLoginLogService() *LoginLogService {
+	return &LoginLogService{}
+}
+
+// Record 记录一次登录尝试，根据结果判断成功、失败或待二次验证
+func (s *LoginLogService) Record(user *models.User, username, method string, deviceInfo DeviceInfo, resp *LoginResponse, loginErr error) {
+	entry := models.LoginLog{
+		Username:   username,
+		Method:     method,
+		IP:         deviceInfo.IP,
+		UserAgent:  deviceInfo.UserAgent,
+		DeviceID:   deviceInfo.DeviceID,
+		DeviceType: deviceInfo.DeviceType,
+		DeviceName: deviceInfo.DeviceName,
+		Location:   geoip.Lookup(deviceInfo.IP),
+	}
+	if user != nil {
+		entry.UserID = &user.ID
+		if entry.Username == "" {
+			entry.Username = user.Username
+		}
+	}
+	
+	switch {
+	case loginErr != nil:
+		entry.Status = models.LoginStatusFailed
+		entry.FailureReason = loginErr.Error()
+	case resp.ChallengeRequired:
+		entry.Status = models.LoginStatusChallenge
+		entry.RiskReasons = strings.Join(resp.RiskReasons, ",")
+	default:
+		entry.Status = models.LoginStatusSuccess
+		entry.RiskReasons = strings.Join(resp.RiskReasons, ",")
+	}
+	
+	database.DB.Create(&entry)
+}
+
+// List 分页查询登录记录
+func (s *LoginLogService) List(query *LoginLogQuery) (*LoginLogListResponse, error) {
+	var logs []models.LoginLog
+	var total int64
+	
+	db := database.DB.Model(&models.LoginLog{})
+	if query.UserID != "" {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.Username != "" {
+		db = db.Where("username LIKE ?", "%"+query.Username+"%")
+	}
+	if query.Status > 0 {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.Method != "" {
+		db = db.Where("method = ?", query.Method)
+	}
+	if query.IP != "" {
+		db = db.Where("ip = ?", query.IP)
+	}
+	if query.Country != "" {
+		db = db.Where("geo_country_code = ?", strings.ToUpper(query.Country))
+	}
+	if !query.StartDate.IsZero() {
+		db = db.Where("created_at >= ?", query.StartDate)
+	}
+	if !query.EndDate.IsZero() {
+		db = db.Where("created_at < ?", query.EndDate.AddDate(0, 0, 1))
+	}
+	
+	db.Count(&total)
+	
+	offset := (query.Page - 1) * query.PageSize
+	err := db.Offset(offset).Limit(query.PageSize).Order("created_at desc").Find(&logs).Error
+	if err != nil {
+		return nil, err
+	}
+	
+	return &LoginLogListResponse{
+		Total: total,
+		Items: logs,
+	}, nil
+}
+
+// ListForUser 查询用户自己的登录记录
+func (s *LoginLogService) ListForUser(userID uuid.UUID, query *LoginLogQuery) (*LoginLogListResponse, error) {
+	query.UserID = userID.String()
+	query.Username = ""
+	return s.List(query)
+}
+
+// RecentRiskyEvents 用户最近的风险事件：登录失败、风险登录和待二次验证的登录
+func (s *LoginLogService) RecentRiskyEvents(userID uuid.UUID, since time.Time, limit int) ([]models.LoginLog, error) {
+	var logs []models.LoginLog
+	err := database.DB.Where("user_id = ? AND created_at >= ?", userID, since).
+		Where("status <> ? OR risk_reasons <> ''", models.LoginStatusSuccess).
+		Order("created_at desc").Limit(limit).Find(&logs).Error
+	return logs, err
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	
+	"usercenter/internal/cache"
+	"usercenter/internal/config"
+	"usercenter/internal/database"
+	"usercenter/internal/models"
+	"usercenter/pkg/crypto"
+	"usercenter/pkg/email"
+	
+	"github.com/google/uuid"
+)
+
+// LoginProtectionService 登录暴力破解防护：基于Redis原子计数的账号/IP失败次数、
+// 渐进式延迟、账号临时锁定（到期自动解锁）和IP限流
+type LoginProtectionService struct {
+	emailService *email.EmailService
+}
+
+func NewLoginProtectionService() *LoginProtectionService {
+	return &LoginProtectionService{
+		emailService: email.NewEmailService(&config.GlobalConfig.SMTP),
+	}
+}
+
+func accountFailureKey(userID uuid.UUID) string {
+	return "login_failures:account:" + userID.String()
+}
+
+func accountDelayKey(userID uuid.UUID) string {
+	return "login_delay:account:" + userID.String()
+}
+
+// usernameFailureKey 按提交的登录名计数，不论账号是否存在，使是否需要图形验证码不会暴露账号是否存在
+func usernameFailureKey(username string) string {
+	return "login_failures:username:" + crypto.SHA256Hex(strings.ToLower(strings.TrimSpace(username)))
+}
+
+func ipFailureKey(ip string) string {
+	return "login_failures:ip:" + ip
+}
+
+func ipBlockKey(ip string) string {
+	return "login_blocked:ip:" + ip
+}
+
+// CheckIP 检查IP是否因大量登录失败（撞库）被暂时封禁
+func (s *LoginProtectionService) CheckIP(ip string) error {
+	if ip == "" {
+		return nil
+	}
+	
+	ttl, _ := cache.TTL(ipBlockKey(ip))
+	if ttl > 0 {
+		return fmt.Errorf("登录失败次数过多，请在 %d 秒后重试", int(math.Ceil(ttl.Seconds())))
+	}
+	return nil
+}
+
+// CheckAccount 检查账号是否处于锁定或渐进延迟中，锁定到期的账号自动解锁
+func (s *LoginProtectionService) CheckAccount(user *models.User) error {
+	if user.Status == models.UserStatusLocked {
+		if user.LockedUntil == nil {
+			return fmt.Errorf("账号已被锁定，请联系管理员")
+		}
+		if time.Now().Before(*user.LockedUntil) {
+			return fmt.Errorf("账号已被锁定，请在 %s 后重试", user.LockedUntil.Format("2006-01-02 15:04:05"))
+		}
+		
+		// 锁定已到期，无需成功登录即可解锁
+		s.unlock(user)
+	}
+	
+	ttl, _ := cache.TTL(accountDelayKey(user.ID))
+	if ttl > 0 {
+		return fmt.Errorf("登录尝试过于频繁，请在 %d 秒后重试", int(math.Ceil(ttl.Seconds())))
+	}
+	return nil
+}
+
+// CaptchaRequired 登录名或IP近期登录失败次数达到阈值后，登录需要图形验证码。
+// 只依据登录名和IP的计数，与账号是否存在无关，username为空时只检查IP
+func (s *LoginProtectionService) CaptchaRequired(username, ip string) bool {
+	threshold := config.GlobalConfig.Security.LoginProtection.CaptchaAfter
+	if threshold < 0 {
+		return false
+	}
+	if threshold == 0 {
+		return true
+	}
+	
+	if ip != "" && counterValue(ipFailureKey(ip)) >= threshold {
+		return true
+	}
+	return username != "" && counterValue(usernameFailureKey(username)) >= threshold
+}
+
+// RecordFailure 记录一次登录失败。username为提交的登录名，user为nil表示用户名不存在，
+// 此时只计入登录名和IP的失败次数
+func (s *LoginProtectionService) RecordFailure(user *models.User, username, ip string) {
+	security := config.GlobalConfig.Security
+	protection := security.LoginProtection
+	
+	if username != "" {
+		incrWithWindow(usernameFailureKey(username), security.LockDuration)
+	}
+	if ip != "" {
+		count := incrWithWindow(ipFailureKey(ip), protection.IPWindow)
+		if protection.IPMaxFailures > 0 && count >= int64(protection.IPMaxFailures) {
+			cache.Set(ipBlockKey(ip), "1", protection.IPBlockDuration)
+			cache.Del(ipFailureKey(ip))
+		}
+	}
+	
+	if user == nil {
+		return
+	}
+	
+	// 计数窗口与锁定时长一致，窗口内无新的失败则自动清零
+	count := incrWithWindow(accountFailureKey(user.ID), security.LockDuration)
+	database.DB.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("login_attempts", count)
+	
+	if security.MaxLoginAttempts > 0 && count >= int64(security.MaxLoginAttempts) {
+		s.lock(user, ip)
+		return
+	}
+	
+	// 达到阈值后每次失败的等待时间翻倍，直至上限
+	if protection.DelayAfter > 0 && count >= int64(protection.DelayAfter) {
+		delay := protection.BaseDelay << uint(count-int64(protection.DelayAfter))
+		if delay <= 0 || delay > protection.MaxDelay {
+			delay = protection.MaxDelay
+		}
+		cache.Set(accountDelayKey(user.ID), "1", delay)
+	}
+}
+
+// RecordSuccess 登录成功后清除账号和登录名的失败记录
+func (s *LoginProtectionService) RecordSuccess(user *models.User, username string) {
+	clearLoginFailures(user.ID)
+	cache.Del(usernameFailureKey(username))
+	
+	if user.LoginAttempts != 0 || user.LockedUntil != nil {
+		user.LoginAttempts = 0
+		user.LockedUntil = nil
+		database.DB.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"login_attempts": 0,
+			"locked_until":   nil,
+		})
+	}
+}
+
+// lock 锁定账号并通知用户
+func (s *LoginProtectionService) lock(user *models.User, ip string) {
+	lockedUntil := time.Now().Add(config.GlobalConfig.Security.LockDuration)
+	
+	// 仅在账号未被锁定时更新，避免并发失败重复锁定和重复通知
+	result := database.DB.Model(&models.User{}).
+		Where("id = ? AND status = ?", user.ID, models.UserStatusNormal).
+		Updates(map[string]interface{}{
+			"status":       models.UserStatusLocked,
+			"locked_until": lockedUntil,
+		})
+	
+	clearLoginFailures(user.ID)
+	
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	
+	user.Status = models.UserStatusLocked
+	user.LockedUntil = &lockedUntil
+	
+	if user.Email != "" {
+		s.emailService.SendTemplate(user.Email, "account_locked", user.Language, map[string]interface{}{
+			"username":  user.Username,
+			"unlock_at": lockedUntil.Format("2006-01-02 15:04:05"),
+			"ip":        ip,
+		})
+	}
+}
+
+// unlock 解除已到期的锁定
+func (s *LoginProtectionService) unlock(user *models.User) {
+	database.DB.Model(&models.User{}).
+		Where("id = ? AND status = ?", user.ID, models.UserStatusLocked).
+		Updates(map[string]interface{}{
+			"status":         models.UserStatusNormal,
+			"locked_until":   nil,
+			"login_attempts": 0,
+		})
+	
+	user.Status = models.UserStatusNormal
+	user.LockedUntil = nil
+	user.LoginAttempts = 0
+}
+
+// UnlockExpiredAccounts 解除所有锁定已到期的账号
+func (s *LoginProtectionService) UnlockExpiredAccounts() (int64, error) {
+	result := database.DB.Model(&models.User{}).
+		Where("status = ? AND locked_until IS NOT NULL AND locked_until <= ?", models.UserStatusLocked, time.Now()).
+		Updates(map[string]interface{}{
+			"status":         models.UserStatusNormal,
+			"locked_until":   nil,
+			"login_attempts": 0,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// StartAccountUnlocker 定期解除到期的账号锁定，使管理后台看到的状态及时更新
+func StartAccountUnlocker(interval time.Duration) {
+	service := NewLoginProtectionService()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			service.UnlockExpiredAccounts()
+		}
+	}()
+}
+
+// clearLoginFailures 清除账号的失败计数和延迟（登录成功、锁定或管理员解锁时）
+func clearLoginFailures(userID uuid.UUID) {
+	cache.Del(accountFailureKey(userID))
+	cache.Del(accountDelayKey(userID))
+}
+
+// counterValue 读取计数，不存在时为0
+func counterValue(key string) int {
+	value, err := cache.Get(key)
+	if err != nil {
+		return 0
+	}
+	count, _ := strconv.Atoi(value)
+	return count
+}
+
+// incrWithWindow 原子自增计数，首次计数时设置过期时间
+func incrWithWindow(key string, window time.Duration) int64 {
+	count, err := cache.Incr(key)
+	if err != nil {
+		return 0
+	}
+	if count == 1 && window > 0 {
+		cache.Expire(key, window)
+	}
+	return count
+}
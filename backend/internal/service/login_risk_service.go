@@ -0,0 +1,388 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	
+	"usercenter/internal/cache"
+	"usercenter/internal/config"
+	"usercenter/internal/database"
+	"usercenter/internal/models"
+	"usercenter/pkg/crypto"
+	"usercenter/pkg/email"
+	"usercenter/pkg/geoip"
+	"usercenter/pkg/sms"
+	"usercenter/pkg/templates"
+	
+	"github.com/google/uuid"
+)
+
+// GeoLocator 根据IP查询经纬度，用于检测不可能的移动速度
+type GeoLocator interface {
+	Locate(ip string) (latitude, longitude float64, ok bool)
+}
+
+var geoLocator GeoLocator
+
+// SetGeoLocator 设置IP地理位置查询，未设置时不检测不可能的移动速度
+func SetGeoLocator(locator GeoLocator) {
+	geoLocator = locator
+}
+
+// 登录风险类型
+const (
+	RiskNewDevice         = "new_device"
+	RiskNewNetwork        = "new_network"
+	RiskImpossibleTravel  = "impossible_travel"
+	RiskCountryNotAllowed = "country_not_allowed"
+)
+
+// loginAlertTTL “不是我本人”链接的有效期
+const loginAlertTTL = 7 * 24 * time.Hour
+
+// loginChallengeTTL 风险登录二次验证的有效期
+const loginChallengeTTL = 5 * time.Minute
+
+// RiskAssessment 登录风险评估结果
+type RiskAssessment struct {
+	Reasons []string `json:"reasons"`
+}
+
+// Risky 是否存在风险
+func (r *RiskAssessment) Risky() bool {
+	return len(r.Reasons) > 0
+}
+
+// loginAlert “不是我本人”链接对应的登录记录
+type loginAlert struct {
+	UserID   uuid.UUID `json:"user_id"`
+	DeviceID string    `json:"device_id"`
+}
+
+// loginChallenge 风险登录待完成的二次验证
+type loginChallenge struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	DeviceInfo DeviceInfo `json:"device_info"`
+	Reasons    []string   `json:"reasons"`
+}
+
+// LoginRiskService 登录风险检测：识别新设备、新的IP网段和不可能的移动速度，
+// 通知用户并提供“不是我本人”链接，可要求风险登录完成二次验证
+type LoginRiskService struct {
+	emailService *email.EmailService
+	smsService   *sms.SMSService
+}
+
+func NewLoginRiskService() *LoginRiskService {
+	return &LoginRiskService{
+		emailService: email.NewEmailService(&config.GlobalConfig.SMTP),
+		smsService:   sms.Default(),
+	}
+}
+
+// Assess 评估登录风险：新设备、新的IP网段、与上次登录之间不可能的移动速度。
+// 需要在更新最后登录信息和设备记录之前调用
+func (s *LoginRiskService) Assess(user *models.User, deviceInfo DeviceInfo) *RiskAssessment {
+	assessment := &RiskAssessment{Reasons: []string{}}
+	if !config.GlobalConfig.Security.LoginRisk.Enabled {
+		return assessment
+	}
+	
+	// 国家白名单对首次登录同样生效
+	if !countryAllowed(geoip.Lookup(deviceInfo.IP).CountryCode) {
+		assessment.Reasons = append(assessment.Reasons, RiskCountryNotAllowed)
+	}
+	
+	var devices []models.UserDevice
+	database.DB.Where("user_id = ?", user.ID).Find(&devices)
+	
+	// 首次登录没有可比较的历史记录
+	if len(devices) == 0 && user.LastLoginIP == "" {
+		return assessment
+	}
+	
+	knownDevice := false
+	knownNetworks := map[string]bool{}
+	if network := ipNetwork(user.LastLoginIP); network != "" {
+		knownNetworks[network] = true
+	}
+	for _, device := range devices {
+		if deviceInfo.DeviceID != "" && device.DeviceID == deviceInfo.DeviceID {
+			knownDevice = true
+		}
+		if network := ipNetwork(device.IP); network != "" {
+			knownNetworks[network] = true
+		}
+	}
+	
+	if !knownDevice {
+		assessment.Reasons = append(assessment.Reasons, RiskNewDevice)
+	}
+	if network := ipNetwork(deviceInfo.IP); network != "" && !knownNetworks[network] {
+		assessment.Reasons = append(assessment.Reasons, RiskNewNetwork)
+	}
+	if s.impossibleTravel(user, deviceInfo.IP) {
+		assessment.Reasons = append(assessment.Reasons, RiskImpossibleTravel)
+	}
+	
+	return assessment
+}
+
+// countryAllowed 国家是否在允许登录的白名单中，未配置白名单或无法解析国家时视为允许
+func countryAllowed(countryCode string) bool {
+	allowed := config.GlobalConfig.Security.LoginRisk.AllowedCountries
+	if len(allowed) == 0 || countryCode == "" {
+		return true
+	}
+	for _, code := range allowed {
+		if strings.EqualFold(code, countryCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// impossibleTravel 根据上次登录的位置和时间计算移动速度是否超过上限
+func (s *LoginRiskService) impossibleTravel(user *models.User, ip string) bool {
+	maxSpeed := config.GlobalConfig.Security.LoginRisk.MaxTravelSpeed
+	if geoLocator == nil || maxSpeed <= 0 || user.LastLoginAt == nil || user.LastLoginIP == "" || ip == user.LastLoginIP {
+		return false
+	}
+	
+	lat1, lon1, ok1 := geoLocator.Locate(user.LastLoginIP)
+	lat2, lon2, ok2 := geoLocator.Locate(ip)
+	if !ok1 || !ok2 {
+		return false
+	}
+	
+	distance := haversineKm(lat1, lon1, lat2, lon2)
+	hours := time.Since(*user.LastLoginAt).Hours()
+	// 不足一分钟按一分钟计算，避免除零并容忍同城网络切换
+	if hours < 1.0/60 {
+		hours = 1.0 / 60
+	}
+	return distance/hours > maxSpeed
+}
+
+// RequireSecondFactor 风险登录是否需要二次验证
+func (s *LoginRiskService) RequireSecondFactor(assessment *RiskAssessment) bool {
+	return assessment.Risky() && config.GlobalConfig.Security.LoginRisk.RequireSecondFactor
+}
+
+// ChallengeMethods 用户可用的二次验证方式
+func (s *LoginRiskService) ChallengeMethods(user *models.User) []string {
+	methods := []string{}
+	if user.TwoFactorEnabled && user.TwoFactorSecret != "" {
+		methods = append(methods, ReauthMethodTOTP)
+	}
+	if user.Email != "" {
+		methods = append(methods, ReauthMethodEmailCode)
+	}
+	if user.Phone != "" {
+		methods = append(methods, ReauthMethodSMSCode)
+	}
+	return methods
+}
+
+// StartChallenge 保存待完成的二次验证，返回验证ID
+func (s *LoginRiskService) StartChallenge(user *models.User, deviceInfo DeviceInfo, assessment *RiskAssessment) (string, error) {
+	challengeID, err := crypto.GenerateRandomHex(16)
+	if err != nil {
+		return "", err
+	}
+	
+	data, err := json.Marshal(loginChallenge{UserID: user.ID, DeviceInfo: deviceInfo, Reasons: assessment.Reasons})
+	if err != nil {
+		return "", err
+	}
+	if err := cache.Set("login_challenge:"+challengeID, string(data), loginChallengeTTL); err != nil {
+		return "", err
+	}
+	
+	return challengeID, nil
+}
+
+// loadChallenge 读取待完成的二次验证
+func (s *LoginRiskService) loadChallenge(challengeID string) (*loginChallenge, error) {
+	value, err := cache.Get("login_challenge:" + challengeID)
+	if err != nil || value == "" {
+		return nil, errors.New("验证已过期，请重新登录")
+	}
+	
+	var challenge loginChallenge
+	if err := json.Unmarshal([]byte(value), &challenge); err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// Alert 通过邮件/短信通知用户发生了风险登录，附带“不是我本人”链接
+func (s *LoginRiskService) Alert(user *models.User, deviceInfo DeviceInfo, assessment *RiskAssessment) {
+	riskConfig := config.GlobalConfig.Security.LoginRisk
+	
+	alertToken, err := crypto.GenerateRandomHex(32)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(loginAlert{UserID: user.ID, DeviceID: deviceInfo.DeviceID})
+	if err != nil {
+		return
+	}
+	if err := cache.Set("login_alert:"+crypto.SHA256Hex(alertToken), string(data), loginAlertTTL); err != nil {
+		return
+	}
+	
+	reportLink := riskConfig.ReportURL + "?token=" + url.QueryEscape(alertToken)
+	if strings.Contains(riskConfig.ReportURL, "?") {
+		reportLink = riskConfig.ReportURL + "&token=" + url.QueryEscape(alertToken)
+	}
+	
+	if riskConfig.NotifyEmail && user.Email != "" && s.emailService != nil {
+		s.emailService.SendExpiringTemplate(user.Email, "login_alert", user.Language, map[string]interface{}{
+			"username": user.Username,
+			"time":     time.Now().Format("2006-01-02 15:04:05"),
+			"reason":   describeRisk(assessment, user.Language),
+			"ip":       deviceInfo.IP,
+			"location": geoip.Lookup(deviceInfo.IP).String(),
+			"device":   strings.TrimSpace(deviceInfo.DeviceType + " " + deviceInfo.DeviceName),
+			"link":     reportLink,
+		}, loginAlertTTL)
+	}
+	
+	if riskConfig.NotifySMS && user.Phone != "" && s.smsService != nil {
+		s.smsService.Send(&sms.Message{
+			Phone:  user.Phone,
+			Type:   sms.MessageLoginAlert,
+			Locale: user.Language,
+			Params: []sms.Param{
+				{Name: "time", Value: time.Now().Format("01-02 15:04")},
+				{Name: "reason", Value: describeRisk(assessment, user.Language)},
+				{Name: "ip", Value: deviceInfo.IP},
+				{Name: "link", Value: reportLink},
+			},
+			ExpiresAt: time.Now().Add(loginAlertTTL),
+		})
+	}
+}
+
+// DenyLogin 用户确认风险登录不是本人操作：注销该用户的全部登录、停用设备，
+// 并将密码替换为随机值，只能通过邮件中的重置链接或验证码重置密码
+func (s *LoginRiskService) DenyLogin(alertToken string) error {
+	key := "login_alert:" + crypto.SHA256Hex(alertToken)
+	value, err := cache.Get(key)
+	if err != nil || value == "" {
+		return errors.New("链接无效或已过期")
+	}
+	cache.Del(key)
+	
+	var alert loginAlert
+	if err := json.Unmarshal([]byte(value), &alert); err != nil {
+		return err
+	}
+	
+	var user models.User
+	if err := database.DB.Where("id = ?", alert.UserID).First(&user).Error; err != nil {
+		return err
+	}
+	
+	// 注销全部登录：对方可能已刷新Token或在其他设备登录，只注销本次登录的Token无法阻止其继续访问
+	if err := revokeUserTokens(user.ID); err != nil {
+		return err
+	}
+	if alert.DeviceID != "" {
+		database.DB.Model(&models.UserDevice{}).
+			Where("user_id = ? AND device_id = ?", user.ID, alert.DeviceID).
+			Update("is_active", false)
+	}
+	
+	// 对方已掌握密码，替换为随机密码使其失效
+	randomPassword, err := crypto.GenerateRandomHex(32)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := crypto.HashPassword(randomPassword)
+	if err != nil {
+		return err
+	}
+	if err := database.DB.Model(&user).Updates(map[string]interface{}{
+		"password":            hashedPassword,
+		"password_changed_at": time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+	
+	// 发送密码重置链接
+	if user.Email != "" && s.emailService != nil {
+		token, err := createPasswordResetToken(user.ID)
+		if err != nil {
+			return err
+		}
+		s.emailService.SendPasswordResetEmail(user.Email, passwordResetLink(token), passwordResetTokenTTL, user.Language)
+	}
+	
+	return nil
+}
+
+// riskDescriptions 风险原因的描述，按语言区分，会作为参数填入邮件和短信模板
+var riskDescriptions = map[string]map[string]string{
+	"zh": {
+		RiskNewDevice:         "新设备",
+		RiskNewNetwork:        "新的网络位置",
+		RiskImpossibleTravel:  "异常的地理位置",
+		RiskCountryNotAllowed: "非常用国家或地区",
+	},
+	"en": {
+		RiskNewDevice:         "a new device",
+		RiskNewNetwork:        "a new network location",
+		RiskImpossibleTravel:  "an unusual location",
+		RiskCountryNotAllowed: "an unusual country or region",
+	},
+}
+
+// describeRisk 风险原因的描述，没有对应语言时使用中文
+func describeRisk(assessment *RiskAssessment, locale string) string {
+	language := templates.Language(locale)
+	descriptions, ok := riskDescriptions[language]
+	if !ok {
+		language = "zh"
+		descriptions = riskDescriptions[language]
+	}
+	
+	parts := make([]string, 0, len(assessment.Reasons))
+	for _, reason := range assessment.Reasons {
+		parts = append(parts, descriptions[reason])
+	}
+	if language == "zh" {
+		return strings.Join(parts, "、")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ipNetwork 返回IP所在网段（IPv4为/24，IPv6为/48），无法解析时返回空字符串
+func ipNetwork(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+// haversineKm 计算两个经纬度之间的球面距离（公里）
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
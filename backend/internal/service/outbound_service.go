@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	
+	"usercenter/internal/database"
+	"usercenter/internal/models"
+	"usercenter/pkg/outbound"
+	
+	"github.com/google/uuid"
+)
+
+// OutboundQuery 发送队列消息查询条件
+type OutboundQuery struct {
+	Page      int    `form:"page"`
+	PageSize  int    `form:"page_size"`
+	Channel   string `form:"channel"`
+	Status    int    `form:"status"`
+	Recipient string `form:"recipient"`
+}
+
+type OutboundListResponse struct {
+	Total int64                    `json:"total"`
+	Items []models.OutboundMessage `json:"items"`
+}
+
+// OutboundStats 按渠道和状态统计的消息数量
+type OutboundStats struct {
+	Enabled bool                 `json:"enabled"`
+	Counts  []OutboundGroupCount `json:"counts"`
+}
+
+type OutboundGroupCount struct {
+	Channel string `json:"channel"`
+	Status  int    `json:"status"`
+	Count   int64  `json:"count"`
+}
+
+type OutboundService struct{}
+
+func NewOutboundService() *OutboundService {
+	return &OutboundService{}
+}
+
+// List 获取发送队列中的消息
+func (s *OutboundService) List(query *OutboundQuery) (*OutboundListResponse, error) {
+	var messages []models.OutboundMessage
+	var total int64
+	
+	db := database.DB.Model(&models.OutboundMessage{})
+	if query.Channel != "" {
+		db = db.Where("channel = ?", query.Channel)
+	}
+	if query.Status > 0 {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.Recipient != "" {
+		db = db.Where("recipient LIKE ?", "%"+query.Recipient+"%")
+	}
+	
+	db.Count(&total)
+	
+	offset := (query.Page - 1) * query.PageSize
+	err := db.Offset(offset).Limit(query.PageSize).Order("created_at desc").Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	
+	return &OutboundListResponse{
+		Total: total,
+		Items: messages,
+	}, nil
+}
+
+// Stats 统计各渠道各状态的消息数量
+func (s *OutboundService) Stats() (*OutboundStats, error) {
+	stats := &OutboundStats{Enabled: outbound.Enabled()}
+	err := database.DB.Model(&models.OutboundMessage{}).
+		Select("channel, status, COUNT(*) AS count").
+		Group("channel, status").
+		Order("channel, status").
+		Scan(&stats.Counts).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Retry 重新发送一条死信
+func (s *OutboundService) Retry(id uuid.UUID) error {
+	if err := outbound.Retry(id); err != nil {
+		if errors.Is(err, outbound.ErrNotRetryable) {
+			return errors.New("消息不存在、未进入死信或内容已清除")
+		}
+		return err
+	}
+	return nil
+}
+
+// RetryDead 重新发送所有未过期的死信，返回重新加入队列的数量
+func (s *OutboundService) RetryDead() (int64, error) {
+	return outbound.RetryDead()
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	
+	"usercenter/internal/cache"
+	"usercenter/internal/config"
+	"usercenter/internal/database"
+	"usercenter/internal/models"
+	"usercenter/pkg/crypto"
+	"usercenter/pkg/email"
+	"usercenter/pkg/phone"
+	"usercenter/pkg/pwned"
+	
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PasswordOwner 校验密码时用于检查个人信息的用户资料
+type PasswordOwner struct {
+	Username string
+	Email    string
+	Phone    string
+}
+
+type PasswordPolicyService struct {
+	emailService *email.EmailService
+}
+
+func NewPasswordPolicyService() *PasswordPolicyService {
+	return &PasswordPolicyService{
+		emailService: email.NewEmailService(&config.GlobalConfig.SMTP),
+	}
+}
+
+// PolicyForRoles 获取角色集合适用的密码策略，多个策略合并时取更严格的要求
+func (s *PasswordPolicyService) PolicyForRoles(roleCodes []string) config.PasswordPolicyConfig {
+	security := config.GlobalConfig.Security
+	policy := security.PasswordPolicy
+	if policy.MinLength < security.PasswordMinLength {
+		policy.MinLength = security.PasswordMinLength
+	}
+	
+	for _, code := range roleCodes {
+		if rolePolicy, ok := security.RolePasswordPolicies[code]; ok {
+			policy = mergePasswordPolicy(policy, rolePolicy)
+		}
+	}
+	
+	return policy
+}
+
+// PolicyForUser 获取用户当前角色适用的密码策略
+func (s *PasswordPolicyService) PolicyForUser(userID uuid.UUID) (config.PasswordPolicyConfig, *PasswordOwner, error) {
+	var user models.User
+	if err := database.DB.Preload("Roles").Where("id = ?", userID).First(&user).Error; err != nil {
+		return config.PasswordPolicyConfig{}, nil, err
+	}
+	
+	roleCodes := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		roleCodes = append(roleCodes, role.Code)
+	}
+	
+	owner := &PasswordOwner{Username: user.Username, Email: user.Email, Phone: user.Phone}
+	return s.PolicyForRoles(roleCodes), owner, nil
+}
+
+// ValidateForUser 按用户当前角色的密码策略校验新密码
+func (s *PasswordPolicyService) ValidateForUser(userID uuid.UUID, password string) error {
+	policy, owner, err := s.PolicyForUser(userID)
+	if err != nil {
+		return err
+	}
+	return s.Validate(&policy, password, owner)
+}
+
+// Validate 按密码策略校验密码，返回第一条不满足的规则
+func (s *PasswordPolicyService) Validate(policy *config.PasswordPolicyConfig, password string, owner *PasswordOwner) error {
+	length := len([]rune(password))
+	if policy.MinLength > 0 && length < policy.MinLength {
+		return fmt.Errorf("密码长度不能少于%d位", policy.MinLength)
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		return fmt.Errorf("密码长度不能超过%d位", policy.MaxLength)
+	}
+	
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	
+	if policy.RequireUppercase && !hasUpper {
+		return errors.New("密码必须包含大写字母")
+	}
+	if policy.RequireLowercase && !hasLower {
+		return errors.New("密码必须包含小写字母")
+	}
+	if policy.RequireDigit && !hasDigit {
+		return errors.New("密码必须包含数字")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		return errors.New("密码必须包含特殊字符")
+	}
+	
+	classes := 0
+	for _, has := range []bool{hasUpper, hasLower, hasDigit, hasSymbol} {
+		if has {
+			classes++
+		}
+	}
+	if classes < policy.MinCharClasses {
+		return fmt.Errorf("密码需包含大写字母、小写字母、数字、特殊字符中的至少%d种", policy.MinCharClasses)
+	}
+	
+	if policy.DisallowPersonalInfo && owner != nil && containsPersonalInfo(password, owner) {
+		return errors.New("密码不能包含用户名、邮箱或手机号")
+	}
+	
+	if policy.BlockCommon && crypto.IsCommonPassword(password) {
+		return errors.New("密码过于常见，请更换")
+	}
+	
+	// 检查失败（如数据文件不可读）时不阻止设置密码
+	if breached, _ := s.IsBreached(password); breached {
+		return errors.New("该密码已出现在公开泄露的密码库中，请更换")
+	}
+	
+	return nil
+}
+
+// IsBreached 检查密码是否出现在已泄露密码库中，未启用时返回false
+func (s *PasswordPolicyService) IsBreached(password string) (bool, error) {
+	return pwned.IsBreached(password, config.GlobalConfig.Security.BreachedPasswords.MinCount)
+}
+
+// mergePasswordPolicy 合并两个密码策略，取更严格的要求
+func mergePasswordPolicy(base, other config.PasswordPolicyConfig) config.PasswordPolicyConfig {
+	if other.MinLength > base.MinLength {
+		base.MinLength = other.MinLength
+	}
+	if other.MaxLength > 0 && (base.MaxLength == 0 || other.MaxLength < base.MaxLength) {
+		base.MaxLength = other.MaxLength
+	}
+	if other.MinCharClasses > base.MinCharClasses {
+		base.MinCharClasses = other.MinCharClasses
+	}
+	base.RequireUppercase = base.RequireUppercase || other.RequireUppercase
+	base.RequireLowercase = base.RequireLowercase || other.RequireLowercase
+	base.RequireDigit = base.RequireDigit || other.RequireDigit
+	base.RequireSymbol = base.RequireSymbol || other.RequireSymbol
+	base.DisallowPersonalInfo = base.DisallowPersonalInfo || other.DisallowPersonalInfo
+	base.BlockCommon = base.BlockCommon || other.BlockCommon
+	return base
+}
+
+// containsPersonalInfo 检查密码是否包含用户名、邮箱前缀或手机号（不区分大小写）
+func containsPersonalInfo(password string, owner *PasswordOwner) bool {
+	lower := strings.ToLower(password)
+	
+	candidates := []string{owner.Username, owner.Phone}
+	if owner.Email != "" {
+		candidates = append(candidates, strings.SplitN(owner.Email, "@", 2)[0])
+	}
+	// 手机号以E.164格式保存，密码中更常见的是不带国家码的号码
+	if number, err := phone.Parse(owner.Phone); err == nil {
+		candidates = append(candidates, number.National)
+	}
+	
+	for _, candidate := range candidates {
+		candidate = strings.ToLower(strings.TrimSpace(candidate))
+		// 过短的片段容易误判，不做检查
+		if len(candidate) < 3 {
+			continue
+		}
+		if strings.Contains(lower, candidate) {
+			return true
+		}
+	}
+	
+	return false
+}
+
+// CheckPasswordHistory 检查新密码是否与当前密码或最近使用过的密码相同
+func (s *PasswordPolicyService) CheckPasswordHistory(user *models.User, password string) error {
+	size := config.GlobalConfig.Security.PasswordHistorySize
+	if size <= 0 {
+		return nil
+	}
+	
+	hashes := []string{user.Password}
+	var histories []models.PasswordHistory
+	if err := database.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(size).Find(&histories).Error; err != nil {
+		return err
+	}
+	for _, history := range histories {
+		hashes = append(hashes, history.Password)
+	}
+	
+	for _, hash := range hashes {
+		if hash == "" {
+			continue
+		}
+		if matched, _ := crypto.VerifyPassword(password, hash); matched {
+			return fmt.Errorf("新密码不能与最近%d次使用过的密码相同", size)
+		}
+	}
+	
+	return nil
+}
+
+// RecordPasswordHistory 记录新设置的密码，只保留最近N条
+func (s *PasswordPolicyService) RecordPasswordHistory(db *gorm.DB, userID uuid.UUID, hashedPassword string) error {
+	size := config.GlobalConfig.Security.PasswordHistorySize
+	if size <= 0 {
+		return nil
+	}
+	
+	if err := db.Create(&models.PasswordHistory{UserID: userID, Password: hashedPassword}).Error; err != nil {
+		return err
+	}
+	
+	// 清理超出数量的历史记录
+	var staleIDs []uuid.UUID
+	if err := db.Model(&models.PasswordHistory{}).Where("user_id = ?", userID).
+		Order("created_at DESC").Offset(size).Pluck("id", &staleIDs).Error; err != nil {
+		return err
+	}
+	if len(staleIDs) == 0 {
+		return nil
+	}
+	return db.Unscoped().Where("id IN ?", staleIDs).Delete(&models.PasswordHistory{}).Error
+}
+
+// PasswordExpiresAt 计算用户密码的过期时间，未配置密码有效期时返回nil
+func (s *PasswordPolicyService) PasswordExpiresAt(user *models.User) *time.Time {
+	maxAge := config.GlobalConfig.Security.PasswordMaxAge
+	if maxAge <= 0 {
+		return nil
+	}
+	
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+	
+	expiresAt := changedAt.Add(maxAge)
+	return &expiresAt
+}
+
+// IsPasswordExpired 用户密码是否已超过最长有效期
+func (s *PasswordPolicyService) IsPasswordExpired(user *models.User) bool {
+	expiresAt := s.PasswordExpiresAt(user)
+	return expiresAt != nil && time.Now().After(*expiresAt)
+}
+
+// SendExpiryReminders 向密码即将过期的用户发送提醒邮件，同一用户在提醒期内只发送一次
+func (s *PasswordPolicyService) SendExpiryReminders() (int, error) {
+	security := config.GlobalConfig.Security
+	if security.PasswordMaxAge <= 0 || security.PasswordExpiryReminder <= 0 {
+		return 0, nil
+	}
+	
+	// 密码修改时间落在该区间内的用户将在提醒期内过期
+	now := time.Now()
+	from := now.Add(-security.PasswordMaxAge)
+	to := from.Add(security.PasswordExpiryReminder)
+	
+	var users []models.User
+	err := database.DB.Where("status = ? AND email <> ''", models.UserStatusNormal).
+		Where("COALESCE(password_changed_at, created_at) BETWEEN ? AND ?", from, to).
+		Find(&users).Error
+	if err != nil {
+		return 0, err
+	}
+	
+	sent := 0
+	for i := range users {
+		user := &users[i]
+		key := "password_expiry_reminded:" + user.ID.String()
+		if ok, _ := cache.SetNX(key, "1", security.PasswordExpiryReminder); !ok {
+			continue
+		}
+		
+		if err := s.emailService.SendPasswordExpiryReminder(user.Email, user.Username, *s.PasswordExpiresAt(user), user.Language); err != nil {
+			cache.Del(key)
+			continue
+		}
+		sent++
+	}
+	
+	return sent, nil
+}
+
+// StartPasswordExpiryReminder 定期发送密码过期提醒
+func StartPasswordExpiryReminder(interval time.Duration) {
+	security := config.GlobalConfig.Security
+	if security.PasswordMaxAge <= 0 || security.PasswordExpiryReminder <= 0 {
+		return
+	}
+	
+	service := NewPasswordPolicyService()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			service.SendExpiryReminders()
+			<-ticker.C
+		}
+	}()
+}
+
+// passwordResetTokenTTL 密码重置链接有效期
+const passwordResetTokenTTL = 30 * time.Minute
+
+// createPasswordResetToken 生成一次性密码重置令牌，Redis中只保存令牌的哈希
+func createPasswordResetToken(userID uuid.UUID) (string, error) {
+	token, err := crypto.GenerateRandomHex(32)
+	if err != nil {
+		return "", err
+	}
+	
+	key := "password_reset_token:" + crypto.SHA256Hex(token)
+	if err := cache.Set(key, userID.String(), passwordResetTokenTTL); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// passwordResetLink 拼接前端密码重置页面的链接
+func passwordResetLink(token string) string {
+	resetURL := config.GlobalConfig.Security.PasswordResetURL
+	separator := "?"
+	if strings.Contains(resetURL, "?") {
+		separator = "&"
+	}
+	return resetURL + separator + "token=" + url.QueryEscape(token)
+}
+
+// lookupPasswordResetToken 查询密码重置令牌对应的用户ID
+func lookupPasswordResetToken(token string) (uuid.UUID, error) {
+	value, err := cache.Get("password_reset_token:" + crypto.SHA256Hex(token))
+	if err != nil || value == "" {
+		return uuid.Nil, errors.New("重置链接无效或已过期")
+	}
+	return uuid.Parse(value)
+}
+
+// revokePasswordResetToken 作废密码重置令牌
+func revokePasswordResetToken(token string) error {
+	return cache.Del("password_reset_token:" + crypto.SHA256Hex(token))
+}
+
+// markMustChangePassword 同步“必须修改密码”标记到Redis，AuthMiddleware据此限制已签发的Token
+func markMustChangePassword(userID uuid.UUID, mustChange bool) {
+	key := "must_change_password:" + userID.String()
+	if mustChange {
+		cache.Set(key, "1", 0)
+	} else {
+		cache.Del(key)
+	}
+}
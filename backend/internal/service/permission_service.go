@@ -0,0 +1,39 @@
+package service
+
+import (
+	"usercenter/internal/database"
+	"usercenter/internal/models"
+)
+
+type PermissionService struct {
+}
+
+func NewPermissionService() *PermissionService {
+	return &PermissionService{}
+}
+
+// HasPermission 检查角色集合是否拥有指定权限，超级管理员拥有所有权限
+func (s *PermissionService) HasPermission(roleCodes []string, permissionCode string) (bool, error) {
+	if len(roleCodes) == 0 {
+		return false, nil
+	}
+	
+	for _, code := range roleCodes {
+		if code == "super_admin" {
+			return true, nil
+		}
+	}
+	
+	var count int64
+	err := database.DB.Model(&models.Permission{}).
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.code IN ? AND roles.status = ? AND roles.deleted_at IS NULL", roleCodes, 1).
+		Where("permissions.code = ? AND permissions.status = ?", permissionCode, 1).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	
+	return count > 0, nil
+}
@@ -0,0 +1,510 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	
+	"usercenter/internal/cache"
+	"usercenter/internal/config"
+	"usercenter/internal/database"
+	"usercenter/internal/models"
+	"usercenter/pkg/crypto"
+	"usercenter/pkg/jwt"
+	
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OAuth客户端认证相关常量
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	ClientAssertionTypeJWT     = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+)
+
+var (
+	ErrInvalidClient        = errors.New("客户端认证失败")
+	ErrUnsupportedGrantType = errors.New("不支持的授权类型")
+	ErrInvalidScope         = errors.New("申请的scope超出允许范围")
+)
+
+type ServiceAccountService struct {
+}
+
+type CreateServiceAccountRequest struct {
+	Name        string   `json:"name" binding:"required,min=3,max=50"`
+	Description string   `json:"description"`
+	PublicKey   string   `json:"public_key"`
+	Scopes      string   `json:"scopes"`
+	RoleCodes   []string `json:"role_codes"`
+}
+
+type UpdateServiceAccountRequest struct {
+	Description string   `json:"description"`
+	PublicKey   string   `json:"public_key"`
+	Scopes      string   `json:"scopes"`
+	Status      int      `json:"status"`
+	RoleCodes   []string `json:"role_codes"`
+}
+
+type ServiceAccountListQuery struct {
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+	Keyword  string `form:"keyword"`
+	Status   int    `form:"status"`
+}
+
+type ServiceAccountListResponse struct {
+	Total int64                   `json:"total"`
+	Items []models.ServiceAccount `json:"items"`
+}
+
+// ServiceAccountCredentials 创建或轮换密钥时返回的凭据，密钥只展示一次
+type ServiceAccountCredentials struct {
+	Account      models.ServiceAccount `json:"account"`
+	ClientID     string                `json:"client_id"`
+	ClientSecret string                `json:"client_secret"`
+}
+
+// ClientCredentials 客户端认证凭据（RFC 6749 2.3 / RFC 7523）
+type ClientCredentials struct {
+	ClientID            string `form:"client_id" json:"client_id"`
+	ClientSecret        string `form:"client_secret" json:"client_secret"`
+	ClientAssertionType string `form:"client_assertion_type" json:"client_assertion_type"`
+	ClientAssertion     string `form:"client_assertion" json:"client_assertion"`
+}
+
+// TokenRequest client_credentials授权请求（RFC 6749 4.4）
+type TokenRequest struct {
+	ClientCredentials
+	GrantType string `form:"grant_type" json:"grant_type"`
+	Scope     string `form:"scope" json:"scope"`
+	IP        string `form:"-" json:"-"`
+}
+
+// TokenOperationRequest 令牌自省/吊销请求（RFC 7662 / RFC 7009）
+type TokenOperationRequest struct {
+	ClientCredentials
+	Token         string `form:"token" json:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint" json:"token_type_hint"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+func NewServiceAccountService() *ServiceAccountService {
+	return &ServiceAccountService{}
+}
+
+// List 获取服务账号列表
+func (s *ServiceAccountService) List(query *ServiceAccountListQuery) (*ServiceAccountListResponse, error) {
+	var accounts []models.ServiceAccount
+	var total int64
+	
+	db := database.DB.Model(&models.ServiceAccount{}).Preload("Roles")
+	
+	if query.Keyword != "" {
+		db = db.Where("name LIKE ? OR client_id LIKE ?", "%"+query.Keyword+"%", "%"+query.Keyword+"%")
+	}
+	if query.Status > 0 {
+		db = db.Where("status = ?", query.Status)
+	}
+	
+	db.Count(&total)
+	
+	offset := (query.Page - 1) * query.PageSize
+	err := db.Offset(offset).Limit(query.PageSize).Order("created_at desc").Find(&accounts).Error
+	if err != nil {
+		return nil, err
+	}
+	
+	return &ServiceAccountListResponse{
+		Total: total,
+		Items: accounts,
+	}, nil
+}
+
+// Get 获取服务账号详情
+func (s *ServiceAccountService) Get(id uuid.UUID) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	if err := database.DB.Preload("Roles").Where("id = ?", id).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// Create 创建服务账号
+func (s *ServiceAccountService) Create(req *CreateServiceAccountRequest, createdBy uuid.UUID) (*ServiceAccountCredentials, error) {
+	var existing models.ServiceAccount
+	if err := database.DB.Where("name = ?", req.Name).First(&existing).Error; err == nil {
+		return nil, errors.New("服务账号名称已存在")
+	}
+	
+	if err := validatePublicKey(req.PublicKey); err != nil {
+		return nil, err
+	}
+	
+	roles, err := findRolesByCodes(req.RoleCodes)
+	if err != nil {
+		return nil, err
+	}
+	
+	clientID, err := generateClientID()
+	if err != nil {
+		return nil, err
+	}
+	secret, hashedSecret, err := generateClientSecret()
+	if err != nil {
+		return nil, err
+	}
+	
+	account := models.ServiceAccount{
+		Name:         req.Name,
+		ClientID:     clientID,
+		ClientSecret: hashedSecret,
+		PublicKey:    req.PublicKey,
+		Scopes:       normalizeScope(req.Scopes),
+		Description:  req.Description,
+		Status:       models.ServiceAccountStatusNormal,
+		CreatedBy:    createdBy,
+		Roles:        roles,
+	}
+	
+	if err := database.DB.Create(&account).Error; err != nil {
+		return nil, err
+	}
+	
+	return &ServiceAccountCredentials{
+		Account:      account,
+		ClientID:     clientID,
+		ClientSecret: secret,
+	}, nil
+}
+
+// Update 更新服务账号
+func (s *ServiceAccountService) Update(id uuid.UUID, req *UpdateServiceAccountRequest) error {
+	account, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	
+	if err := validatePublicKey(req.PublicKey); err != nil {
+		return err
+	}
+	
+	account.Description = req.Description
+	account.PublicKey = req.PublicKey
+	account.Scopes = normalizeScope(req.Scopes)
+	
+	statusChanged := false
+	if req.Status > 0 && req.Status != account.Status {
+		account.Status = req.Status
+		statusChanged = true
+	}
+	
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	
+	if err := tx.Omit("Roles").Save(account).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	
+	rolesChanged := false
+	if req.RoleCodes != nil {
+		roles, err := findRolesByCodes(req.RoleCodes)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Model(account).Association("Roles").Replace(roles); err != nil {
+			tx.Rollback()
+			return err
+		}
+		rolesChanged = true
+	}
+	
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	
+	// 禁用或调整角色后，已签发的Token立即失效
+	if statusChanged || rolesChanged {
+		revokeServiceAccountTokens(account.ID)
+	}
+	
+	return nil
+}
+
+// Delete 删除服务账号
+func (s *ServiceAccountService) Delete(id uuid.UUID) error {
+	var account models.ServiceAccount
+	if err := database.DB.Where("id = ?", id).First(&account).Error; err != nil {
+		return err
+	}
+	
+	if err := database.DB.Model(&account).Association("Roles").Clear(); err != nil {
+		return err
+	}
+	if err := database.DB.Delete(&account).Error; err != nil {
+		return err
+	}
+	
+	revokeServiceAccountTokens(id)
+	return nil
+}
+
+// RotateSecret 轮换服务账号密钥
+func (s *ServiceAccountService) RotateSecret(id uuid.UUID) (*ServiceAccountCredentials, error) {
+	account, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	
+	secret, hashedSecret, err := generateClientSecret()
+	if err != nil {
+		return nil, err
+	}
+	
+	if err := database.DB.Model(account).Update("client_secret", hashedSecret).Error; err != nil {
+		return nil, err
+	}
+	
+	revokeServiceAccountTokens(account.ID)
+	
+	return &ServiceAccountCredentials{
+		Account:      *account,
+		ClientID:     account.ClientID,
+		ClientSecret: secret,
+	}, nil
+}
+
+// Authenticate 使用client_id/client_secret认证服务账号
+func (s *ServiceAccountService) Authenticate(clientID, clientSecret string) (*models.ServiceAccount, error) {
+	if clientID == "" || clientSecret == "" {
+		return nil, ErrInvalidClient
+	}
+	
+	account, err := s.findActiveByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	
+	isValid, err := crypto.VerifyPassword(clientSecret, account.ClientSecret)
+	if err != nil || !isValid {
+		return nil, ErrInvalidClient
+	}
+	
+	return account, nil
+}
+
+// AuthenticateAssertion 使用签名JWT断言认证服务账号
+func (s *ServiceAccountService) AuthenticateAssertion(clientID, assertion string) (*models.ServiceAccount, error) {
+	if assertion == "" {
+		return nil, ErrInvalidClient
+	}
+	
+	// 断言中iss和sub均应为client_id
+	if clientID == "" {
+		unverified, err := jwt.PeekSubject(assertion)
+		if err != nil {
+			return nil, ErrInvalidClient
+		}
+		clientID = unverified
+	}
+	
+	account, err := s.findActiveByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	
+	claims, err := jwt.ParseClientAssertion(assertion, account.PublicKey)
+	if err != nil {
+		return nil, ErrInvalidClient
+	}
+	if claims.Issuer != account.ClientID || claims.Subject != account.ClientID {
+		return nil, ErrInvalidClient
+	}
+	if claims.ID == "" {
+		return nil, ErrInvalidClient
+	}
+	
+	// 防止断言重放
+	ttl := time.Until(claims.ExpiresAt.Time)
+	key := fmt.Sprintf("client_assertion_jti:%s:%s", account.ClientID, claims.ID)
+	ok, err := cache.SetNX(key, "1", ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidClient
+	}
+	
+	return account, nil
+}
+
+// AuthenticateClient 根据凭据类型选择密钥或JWT断言方式认证客户端
+func (s *ServiceAccountService) AuthenticateClient(creds *ClientCredentials) (*models.ServiceAccount, error) {
+	if creds.ClientAssertionType != "" {
+		if creds.ClientAssertionType != ClientAssertionTypeJWT {
+			return nil, ErrInvalidClient
+		}
+		return s.AuthenticateAssertion(creds.ClientID, creds.ClientAssertion)
+	}
+	return s.Authenticate(creds.ClientID, creds.ClientSecret)
+}
+
+// CanRevokeAnyToken 拥有管理员角色的服务账号可以吊销其他主体的令牌
+func (s *ServiceAccountService) CanRevokeAnyToken(account *models.ServiceAccount) bool {
+	for _, role := range account.Roles {
+		if role.Code == "super_admin" || role.Code == "admin" {
+			return true
+		}
+	}
+	return false
+}
+
+// IssueToken 处理client_credentials授权，签发访问Token
+func (s *ServiceAccountService) IssueToken(req *TokenRequest) (*TokenResponse, error) {
+	if req.GrantType != GrantTypeClientCredentials {
+		return nil, ErrUnsupportedGrantType
+	}
+	
+	account, err := s.AuthenticateClient(&req.ClientCredentials)
+	if err != nil {
+		return nil, err
+	}
+	
+	scope, err := grantScope(account.Scopes, req.Scope)
+	if err != nil {
+		return nil, err
+	}
+	
+	roleCodes := make([]string, 0, len(account.Roles))
+	for _, role := range account.Roles {
+		roleCodes = append(roleCodes, role.Code)
+	}
+	
+	token, expiresAt, err := jwt.GenerateServiceToken(account.ID, account.ClientID, account.Name, roleCodes, scope)
+	if err != nil {
+		return nil, err
+	}
+	
+	// 记录最近使用情况
+	now := time.Now()
+	database.DB.Model(account).Updates(map[string]interface{}{
+		"last_used_at": &now,
+		"last_used_ip": req.IP,
+	})
+	
+	return &TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// findActiveByClientID 查找状态正常的服务账号
+func (s *ServiceAccountService) findActiveByClientID(clientID string) (*models.ServiceAccount, error) {
+	var account models.ServiceAccount
+	err := database.DB.Preload("Roles").Where("client_id = ?", clientID).First(&account).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+	
+	if account.Status != models.ServiceAccountStatusNormal {
+		return nil, ErrInvalidClient
+	}
+	
+	return &account, nil
+}
+
+// revokeServiceAccountTokens 使服务账号此前签发的所有Token失效
+func revokeServiceAccountTokens(accountID uuid.UUID) {
+	jwt.RevokeServiceAccountTokens(accountID, config.GlobalConfig.JWT.ServiceTokenExpires)
+}
+
+// findRolesByCodes 根据角色代码查找角色
+func findRolesByCodes(codes []string) ([]models.Role, error) {
+	if len(codes) == 0 {
+		return []models.Role{}, nil
+	}
+	
+	var roles []models.Role
+	if err := database.DB.Where("code IN ?", codes).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	if len(roles) != len(codes) {
+		return nil, errors.New("角色不存在")
+	}
+	return roles, nil
+}
+
+// validatePublicKey 校验PEM公钥格式
+func validatePublicKey(publicKey string) error {
+	if publicKey == "" {
+		return nil
+	}
+	if !strings.Contains(publicKey, "-----BEGIN PUBLIC KEY-----") &&
+		!strings.Contains(publicKey, "-----BEGIN RSA PUBLIC KEY-----") {
+		return errors.New("公钥必须为PEM格式")
+	}
+	return nil
+}
+
+// grantScope 计算最终授予的scope，未申请时授予全部允许的scope
+func grantScope(allowed, requested string) (string, error) {
+	requested = normalizeScope(requested)
+	if requested == "" {
+		return allowed, nil
+	}
+	
+	allowedSet := make(map[string]bool)
+	for _, scope := range strings.Fields(allowed) {
+		allowedSet[scope] = true
+	}
+	for _, scope := range strings.Fields(requested) {
+		if !allowedSet[scope] {
+			return "", ErrInvalidScope
+		}
+	}
+	return requested, nil
+}
+
+func normalizeScope(scope string) string {
+	return strings.Join(strings.Fields(scope), " ")
+}
+
+func generateClientID() (string, error) {
+	id, err := crypto.GenerateRandomHex(12)
+	if err != nil {
+		return "", err
+	}
+	return "sa_" + id, nil
+}
+
+// generateClientSecret 生成客户端密钥，返回明文和哈希
+func generateClientSecret() (string, string, error) {
+	secret, err := crypto.GenerateRandomString(48)
+	if err != nil {
+		return "", "", err
+	}
+	hashed, err := crypto.HashPassword(secret)
+	if err != nil {
+		return "", "", err
+	}
+	return secret, hashed, nil
+}
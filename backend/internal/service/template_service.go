@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+	
+	"usercenter/pkg/templates"
+)
+
+// TemplatePreviewRequest 模板预览请求，Data为空时使用内置示例数据，提供的字段覆盖示例数据
+type TemplatePreviewRequest struct {
+	Kind   string                 `json:"kind" binding:"required,oneof=email sms"`
+	Name   string                 `json:"name" binding:"required"`
+	Locale string                 `json:"locale"`
+	Data   map[string]interface{} `json:"data"`
+}
+
+// TemplatePreview 模板渲染结果，Locale为实际使用的语言
+type TemplatePreview struct {
+	Kind    string                 `json:"kind"`
+	Name    string                 `json:"name"`
+	Locale  string                 `json:"locale"`
+	Subject string                 `json:"subject,omitempty"`
+	Text    string                 `json:"text"`
+	HTML    string                 `json:"html,omitempty"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+type TemplateService struct{}
+
+func NewTemplateService() *TemplateService {
+	return &TemplateService{}
+}
+
+// List 获取所有邮件和短信模板
+func (s *TemplateService) List() []templates.Info {
+	return templates.List()
+}
+
+// Preview 使用示例数据渲染模板
+func (s *TemplateService) Preview(req *TemplatePreviewRequest) (*TemplatePreview, error) {
+	data := templates.Sample(req.Kind, req.Name)
+	for key, value := range req.Data {
+		data[key] = value
+	}
+	
+	preview := &TemplatePreview{Kind: req.Kind, Name: req.Name, Data: data}
+	switch req.Kind {
+	case templates.KindEmail:
+		content, err := templates.RenderEmail(req.Name, req.Locale, data)
+		if err != nil {
+			return nil, templateError(err)
+		}
+		preview.Locale = content.Locale
+		preview.Subject = content.Subject
+		preview.Text = content.Text
+		preview.HTML = content.HTML
+	default:
+		locale, err := templates.Resolve(req.Kind, req.Name, req.Locale)
+		if err != nil {
+			return nil, templateError(err)
+		}
+		text, err := templates.RenderSMS(req.Name, locale, data)
+		if err != nil {
+			return nil, templateError(err)
+		}
+		preview.Locale = locale
+		preview.Text = text
+	}
+	return preview, nil
+}
+
+// templateError 模板不存在时返回中文提示，模板语法或渲染错误原样返回便于修改模板
+func templateError(err error) error {
+	if errors.Is(err, templates.ErrTemplateNotFound) {
+		return errors.New("模板不存在")
+	}
+	return err
+}
@@ -13,14 +13,20 @@ import (
 	"usercenter/internal/config"
 	"usercenter/internal/database"
 	"usercenter/internal/models"
-	"usercenter/pkg/captcha"
 	"usercenter/pkg/crypto"
+	"usercenter/pkg/email"
+	"usercenter/pkg/phone"
+	"usercenter/pkg/templates"
 	
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type UserService struct {
+	passwordPolicyService *PasswordPolicyService
+	loginLogService       *LoginLogService
+	emailService          *email.EmailService
+	verification          *VerificationService
 }
 
 type UpdateProfileRequest struct {
@@ -28,11 +34,12 @@ type UpdateProfileRequest struct {
 	Gender   int        `json:"gender"`
 	Birthday *time.Time `json:"birthday"`
 	Bio      string     `json:"bio"`
+	Language string     `json:"language"`
 }
 
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=8"`
+	NewPassword string `json:"new_password" binding:"required"`
 }
 
 type BindEmailRequest struct {
@@ -51,6 +58,7 @@ type UserListQuery struct {
 	Keyword  string `form:"keyword"`
 	Status   int    `form:"status"`
 	RoleCode string `form:"role_code"`
+	Country  string `form:"country"` // 最后登录所在国家代码
 }
 
 type UserListResponse struct {
@@ -58,8 +66,36 @@ type UserListResponse struct {
 	Items []models.User `json:"items"`
 }
 
+type UserLogListResponse struct {
+	Total int64            `json:"total"`
+	Items []models.UserLog `json:"items"`
+}
+
+// SecuritySettings 账号安全概况
+type SecuritySettings struct {
+	TwoFactorEnabled   bool                `json:"two_factor_enabled"`
+	Email              string              `json:"email"`
+	EmailVerified      bool                `json:"email_verified"`
+	Phone              string              `json:"phone"`
+	PhoneVerified      bool                `json:"phone_verified"`
+	PasswordChangedAt  *time.Time          `json:"password_changed_at"`
+	PasswordExpiresAt  *time.Time          `json:"password_expires_at"`
+	PasswordBreached   bool                `json:"password_breached"`
+	MustChangePassword bool                `json:"must_change_password"`
+	LastLoginAt        *time.Time          `json:"last_login_at"`
+	LastLoginIP        string              `json:"last_login_ip"`
+	LastLoginLocation  models.GeoLocation  `json:"last_login_location"`
+	ActiveSessions     []models.UserDevice `json:"active_sessions"`
+	RecentRiskyEvents  []models.LoginLog   `json:"recent_risky_events"` // 最近30天的登录失败和风险登录
+}
+
 func NewUserService() *UserService {
-	return &UserService{}
+	return &UserService{
+		passwordPolicyService: NewPasswordPolicyService(),
+		loginLogService:       NewLoginLogService(),
+		emailService:          email.NewEmailService(&config.GlobalConfig.SMTP),
+		verification:          NewVerificationService(),
+	}
 }
 
 // GetProfile 获取用户资料
@@ -89,6 +125,9 @@ func (s *UserService) UpdateProfile(userID uuid.UUID, req *UpdateProfileRequest)
 	user.Gender = req.Gender
 	user.Birthday = req.Birthday
 	user.Bio = req.Bio
+	if req.Language != "" {
+		user.Language = templates.NormalizeLocale(req.Language)
+	}
 	
 	return database.DB.Save(&user).Error
 }
@@ -111,6 +150,16 @@ func (s *UserService) ChangePassword(userID uuid.UUID, req *ChangePasswordReques
 		return errors.New("旧密码不正确")
 	}
 	
+	// 校验密码策略
+	if err := s.passwordPolicyService.ValidateForUser(userID, req.NewPassword); err != nil {
+		return err
+	}
+	
+	// 不允许重复使用最近的密码
+	if err := s.passwordPolicyService.CheckPasswordHistory(&user, req.NewPassword); err != nil {
+		return err
+	}
+	
 	// 加密新密码
 	hashedPassword, err := crypto.HashPassword(req.NewPassword)
 	if err != nil {
@@ -118,8 +167,23 @@ func (s *UserService) ChangePassword(userID uuid.UUID, req *ChangePasswordReques
 	}
 	
 	// 更新密码
+	now := time.Now()
 	user.Password = hashedPassword
-	return database.DB.Save(&user).Error
+	user.PasswordChangedAt = &now
+	user.PasswordBreached = false
+	user.MustChangePassword = false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return s.passwordPolicyService.RecordPasswordHistory(tx, user.ID, hashedPassword)
+	})
+	if err != nil {
+		return err
+	}
+	
+	markMustChangePassword(user.ID, false)
+	return nil
 }
 
 // UploadAvatar 上传头像
@@ -128,8 +192,12 @@ func (s *UserService) UploadAvatar(userID uuid.UUID, file *multipart.FileHeader)
 	allowedTypes := config.GlobalConfig.Upload.AllowedTypes
 	fileExt := strings.ToLower(filepath.Ext(file.Filename))
 	
+	if len(allowedTypes) == 0 {
+		allowedTypes = []string{".jpg", ".jpeg", ".png", ".gif"}
+	}
+	
 	allowed := false
-	for _, ext := range []string{".jpg", ".jpeg", ".png", ".gif"} {
+	for _, ext := range allowedTypes {
 		if fileExt == ext {
 			allowed = true
 			break
@@ -187,35 +255,46 @@ func (s *UserService) UploadAvatar(userID uuid.UUID, file *multipart.FileHeader)
 
 // BindEmail 绑定邮箱
 func (s *UserService) BindEmail(userID uuid.UUID, req *BindEmailRequest) error {
+	address, err := email.NormalizeAddress(req.Email)
+	if err != nil {
+		return err
+	}
+	
 	// 验证邮箱验证码
-	if !captcha.VerifyEmailCode(req.Email, req.EmailCode, "bind_email") {
+	if !s.verification.Verify(VerificationTargetEmail, address, req.EmailCode, "bind_email") {
 		return errors.New("验证码错误或已过期")
 	}
 	
 	// 检查邮箱是否已被使用
 	var existingUser models.User
-	err := database.DB.Where("email = ? AND id != ?", req.Email, userID).First(&existingUser).Error
+	err = database.DB.Where("LOWER(email) = ? AND id != ?", address, userID).First(&existingUser).Error
 	if err == nil {
 		return errors.New("该邮箱已被其他用户绑定")
 	}
 	
 	// 更新用户邮箱
 	return database.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
-		"email":          req.Email,
+		"email":          address,
 		"email_verified": true,
 	}).Error
 }
 
 // BindPhone 绑定手机号
 func (s *UserService) BindPhone(userID uuid.UUID, req *BindPhoneRequest) error {
+	number, err := phone.Normalize(req.Phone)
+	if err != nil {
+		return err
+	}
+	req.Phone = number
+	
 	// 验证短信验证码
-	if !captcha.VerifySMSCode(req.Phone, req.SMSCode, "bind_phone") {
+	if !s.verification.Verify(VerificationTargetPhone, req.Phone, req.SMSCode, "bind_phone") {
 		return errors.New("验证码错误或已过期")
 	}
 	
 	// 检查手机号是否已被使用
 	var existingUser models.User
-	err := database.DB.Where("phone = ? AND id != ?", req.Phone, userID).First(&existingUser).Error
+	err = database.DB.Where("phone = ? AND id != ?", req.Phone, userID).First(&existingUser).Error
 	if err == nil {
 		return errors.New("该手机号已被其他用户绑定")
 	}
@@ -227,6 +306,45 @@ func (s *UserService) BindPhone(userID uuid.UUID, req *BindPhoneRequest) error {
 	}).Error
 }
 
+// NormalizeStoredPhones 将旧数据中未带国家码的手机号转换为E.164格式，返回转换的数量。
+// 无法解析、转换后与其他用户（包括已删除的用户）重复或保存失败的号码保持原样，
+// 逐条交给skipped记录后继续处理其余用户
+func NormalizeStoredPhones(skipped func(userID uuid.UUID, err error)) (int, error) {
+	var users []models.User
+	if err := database.DB.Select("id", "phone").
+		Where("phone <> '' AND phone NOT LIKE ?", "+%").Find(&users).Error; err != nil {
+		return 0, err
+	}
+	
+	converted := 0
+	for _, user := range users {
+		number, err := phone.Normalize(user.Phone)
+		if err != nil {
+			skipped(user.ID, err)
+			continue
+		}
+		
+		// 手机号唯一索引同样包含软删除的用户
+		var count int64
+		if err := database.DB.Unscoped().Model(&models.User{}).Where("phone = ?", number).
+			Count(&count).Error; err != nil {
+			skipped(user.ID, err)
+			continue
+		}
+		if count > 0 {
+			skipped(user.ID, errors.New("转换后的号码已被其他用户使用"))
+			continue
+		}
+		if err := database.DB.Model(&models.User{}).Where("id = ?", user.ID).
+			Update("phone", number).Error; err != nil {
+			skipped(user.ID, err)
+			continue
+		}
+		converted++
+	}
+	return converted, nil
+}
+
 // GetUserDevices 获取用户设备列表
 func (s *UserService) GetUserDevices(userID uuid.UUID) ([]models.UserDevice, error) {
 	var devices []models.UserDevice
@@ -240,7 +358,7 @@ func (s *UserService) RemoveUserDevice(userID uuid.UUID, deviceID string) error
 }
 
 // GetUserLogs 获取用户操作日志
-func (s *UserService) GetUserLogs(userID uuid.UUID, page, pageSize int) (*UserListResponse, error) {
+func (s *UserService) GetUserLogs(userID uuid.UUID, page, pageSize int) (*UserLogListResponse, error) {
 	var logs []models.UserLog
 	var total int64
 	
@@ -256,10 +374,45 @@ func (s *UserService) GetUserLogs(userID uuid.UUID, page, pageSize int) (*UserLi
 		return nil, err
 	}
 	
-	// 转换为用户列表响应格式（这里需要适配）
-	return &UserListResponse{
+	return &UserLogListResponse{
 		Total: total,
-		Items: []models.User{}, // 实际应该返回日志列表
+		Items: logs,
+	}, nil
+}
+
+// GetSecuritySettings 获取账号安全概况：两步验证、绑定的联系方式、密码状态、活跃会话和最近的风险事件
+func (s *UserService) GetSecuritySettings(userID uuid.UUID) (*SecuritySettings, error) {
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	
+	var sessions []models.UserDevice
+	if err := database.DB.Where("user_id = ? AND is_active = ?", userID, true).
+		Order("last_active desc").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	
+	riskyEvents, err := s.loginLogService.RecentRiskyEvents(userID, time.Now().AddDate(0, 0, -30), 10)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &SecuritySettings{
+		TwoFactorEnabled:   user.TwoFactorEnabled,
+		Email:              user.Email,
+		EmailVerified:      user.EmailVerified,
+		Phone:              user.Phone,
+		PhoneVerified:      user.PhoneVerified,
+		PasswordChangedAt:  user.PasswordChangedAt,
+		PasswordExpiresAt:  s.passwordPolicyService.PasswordExpiresAt(&user),
+		PasswordBreached:   user.PasswordBreached,
+		MustChangePassword: user.MustChangePassword,
+		LastLoginAt:        user.LastLoginAt,
+		LastLoginIP:        user.LastLoginIP,
+		LastLoginLocation:  user.LastLoginLocation,
+		ActiveSessions:     sessions,
+		RecentRiskyEvents:  riskyEvents,
 	}, nil
 }
 
@@ -281,6 +434,11 @@ func (s *UserService) AdminGetUsers(query *UserListQuery) (*UserListResponse, er
 		db = db.Where("status = ?", query.Status)
 	}
 	
+	// 最后登录国家筛选
+	if query.Country != "" {
+		db = db.Where("users.last_login_country_code = ?", strings.ToUpper(query.Country))
+	}
+	
 	// 角色筛选
 	if query.RoleCode != "" {
 		db = db.Joins("JOIN user_roles ON users.id = user_roles.user_id").
@@ -320,7 +478,10 @@ func (s *UserService) AdminCreateUser(req *RegisterRequest) error {
 	
 	// 检查邮箱是否已存在
 	if req.Email != "" {
-		err = database.DB.Where("email = ?", req.Email).First(&existingUser).Error
+		if req.Email, err = email.NormalizeAddress(req.Email); err != nil {
+			return err
+		}
+		err = database.DB.Where("LOWER(email) = ?", req.Email).First(&existingUser).Error
 		if err == nil {
 			return errors.New("邮箱已存在")
 		}
@@ -328,12 +489,22 @@ func (s *UserService) AdminCreateUser(req *RegisterRequest) error {
 	
 	// 检查手机号是否已存在
 	if req.Phone != "" {
+		if req.Phone, err = phone.Normalize(req.Phone); err != nil {
+			return err
+		}
 		err = database.DB.Where("phone = ?", req.Phone).First(&existingUser).Error
 		if err == nil {
 			return errors.New("手机号已存在")
 		}
 	}
 	
+	// 校验密码策略（新用户尚未分配角色，使用默认策略）
+	policy := s.passwordPolicyService.PolicyForRoles(nil)
+	owner := &PasswordOwner{Username: req.Username, Email: req.Email, Phone: req.Phone}
+	if err := s.passwordPolicyService.Validate(&policy, req.Password, owner); err != nil {
+		return err
+	}
+	
 	// 加密密码
 	hashedPassword, err := crypto.HashPassword(req.Password)
 	if err != nil {
@@ -341,22 +512,137 @@ func (s *UserService) AdminCreateUser(req *RegisterRequest) error {
 	}
 	
 	// 创建用户
+	now := time.Now()
 	user := models.User{
-		Username:      req.Username,
-		Email:         req.Email,
-		Phone:         req.Phone,
-		Password:      hashedPassword,
-		Nickname:      req.Nickname,
-		Status:        models.UserStatusNormal,
-		EmailVerified: req.Email != "",
-		PhoneVerified: req.Phone != "",
+		Username:          req.Username,
+		Email:             req.Email,
+		Phone:             req.Phone,
+		Password:          hashedPassword,
+		Nickname:          req.Nickname,
+		Language:          templates.NormalizeLocale(req.Language),
+		Status:            models.UserStatusNormal,
+		EmailVerified:     req.Email != "",
+		PhoneVerified:     req.Phone != "",
+		PasswordChangedAt: &now,
+		MustChangePassword: req.MustChangePassword,
 	}
 	
 	if req.Nickname == "" {
 		user.Nickname = req.Username
 	}
 	
-	return database.DB.Create(&user).Error
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return s.passwordPolicyService.RecordPasswordHistory(tx, user.ID, hashedPassword)
+	})
+}
+
+// ImportUser 从旧系统导入的用户，PasswordHash为旧系统中的密码哈希
+type ImportUser struct {
+	Username     string `json:"username" binding:"required,min=3,max=50"`
+	Email        string `json:"email"`
+	Phone        string `json:"phone"`
+	Nickname     string `json:"nickname"`
+	PasswordHash string `json:"password_hash" binding:"required"`
+}
+
+type ImportUsersRequest struct {
+	Users []ImportUser `json:"users" binding:"required,min=1,max=1000,dive"`
+}
+
+type ImportUserFailure struct {
+	Username string `json:"username"`
+	Reason   string `json:"reason"`
+}
+
+type ImportUsersResult struct {
+	Imported int                 `json:"imported"`
+	Failed   []ImportUserFailure `json:"failed"`
+}
+
+// AdminImportUsers 管理员批量导入旧系统用户。密码哈希原样保存（支持argon2id、bcrypt、加盐MD5），
+// 用户首次登录成功后自动升级为当前的argon2id哈希
+func (s *UserService) AdminImportUsers(req *ImportUsersRequest) (*ImportUsersResult, error) {
+	var userRole models.Role
+	if err := database.DB.Where("code = ?", "user").First(&userRole).Error; err != nil {
+		return nil, err
+	}
+	
+	result := &ImportUsersResult{Failed: []ImportUserFailure{}}
+	for _, item := range req.Users {
+		if err := s.importUser(&item, &userRole); err != nil {
+			result.Failed = append(result.Failed, ImportUserFailure{
+				Username: item.Username,
+				Reason:   err.Error(),
+			})
+			continue
+		}
+		result.Imported++
+	}
+	
+	return result, nil
+}
+
+// importUser 导入单个用户
+func (s *UserService) importUser(item *ImportUser, role *models.Role) error {
+	if !crypto.IsSupportedHash(item.PasswordHash) {
+		return errors.New("不支持的密码哈希格式")
+	}
+	if item.Email != "" {
+		address, err := email.NormalizeAddress(item.Email)
+		if err != nil {
+			return err
+		}
+		item.Email = address
+	}
+	if item.Phone != "" {
+		number, err := phone.Normalize(item.Phone)
+		if err != nil {
+			return err
+		}
+		item.Phone = number
+	}
+	
+	var count int64
+	query := database.DB.Model(&models.User{}).Where("username = ?", item.Username)
+	if item.Email != "" {
+		query = query.Or("LOWER(email) = ?", item.Email)
+	}
+	if item.Phone != "" {
+		query = query.Or("phone = ?", item.Phone)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("用户名、邮箱或手机号已存在")
+	}
+	
+	user := models.User{
+		Username:      item.Username,
+		Email:         item.Email,
+		Phone:         item.Phone,
+		Password:      item.PasswordHash,
+		Nickname:      item.Nickname,
+		Status:        models.UserStatusNormal,
+		EmailVerified: item.Email != "",
+		PhoneVerified: item.Phone != "",
+	}
+	if user.Nickname == "" {
+		user.Nickname = user.Username
+	}
+	
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Association("Roles").Append(role); err != nil {
+			return err
+		}
+		return s.passwordPolicyService.RecordPasswordHistory(tx, user.ID, item.PasswordHash)
+	})
 }
 
 // AdminUpdateUser 管理员更新用户
@@ -371,20 +657,129 @@ func (s *UserService) AdminDeleteUser(userID uuid.UUID) error {
 
 // AdminUpdateUserStatus 管理员更新用户状态
 func (s *UserService) AdminUpdateUserStatus(userID uuid.UUID, status int) error {
-	return database.DB.Model(&models.User{}).Where("id = ?", userID).Update("status", status).Error
+	updates := map[string]interface{}{"status": status}
+	if status == models.UserStatusNormal {
+		// 管理员解锁时同时清除失败计数
+		updates["login_attempts"] = 0
+		updates["locked_until"] = nil
+		clearLoginFailures(userID)
+	}
+	return database.DB.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error
+}
+
+// 管理员重置密码方式
+const (
+	ResetModeTemporary = "temporary" // 生成随机临时密码
+	ResetModeLink      = "link"      // 向用户邮箱发送重置链接
+	ResetModeManual    = "manual"    // 管理员指定新密码
+)
+
+// AdminResetPasswordRequest 管理员重置密码请求，未指定mode时生成临时密码
+type AdminResetPasswordRequest struct {
+	Mode               string `json:"mode" binding:"omitempty,oneof=temporary link manual"`
+	NewPassword        string `json:"new_password"`
+	MustChangePassword *bool  `json:"must_change_password"` // 默认为true
 }
 
-// AdminResetUserPassword 管理员重置用户密码
-func (s *UserService) AdminResetUserPassword(userID uuid.UUID, newPassword string) error {
+type AdminResetPasswordResult struct {
+	Mode              string `json:"mode"`
+	TemporaryPassword string `json:"temporary_password,omitempty"`
+	ResetLinkSentTo   string `json:"reset_link_sent_to,omitempty"`
+}
+
+// AdminResetPassword 管理员按指定方式重置用户密码
+func (s *UserService) AdminResetPassword(userID uuid.UUID, req *AdminResetPasswordRequest) (*AdminResetPasswordResult, error) {
+	mustChange := req.MustChangePassword == nil || *req.MustChangePassword
+	
+	switch req.Mode {
+	case ResetModeLink:
+		sentTo, err := s.sendPasswordResetLink(userID)
+		if err != nil {
+			return nil, err
+		}
+		return &AdminResetPasswordResult{Mode: ResetModeLink, ResetLinkSentTo: sentTo}, nil
+	case ResetModeManual:
+		if req.NewPassword == "" {
+			return nil, errors.New("请输入新密码")
+		}
+		if err := s.AdminResetUserPassword(userID, req.NewPassword, mustChange); err != nil {
+			return nil, err
+		}
+		return &AdminResetPasswordResult{Mode: ResetModeManual}, nil
+	default:
+		// 临时密码按用户适用的密码策略长度生成，且总是要求下次登录修改
+		policy, _, err := s.passwordPolicyService.PolicyForUser(userID)
+		if err != nil {
+			return nil, err
+		}
+		length := 16
+		if policy.MinLength > length {
+			length = policy.MinLength
+		}
+		password, err := crypto.GenerateTemporaryPassword(length)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.AdminResetUserPassword(userID, password, true); err != nil {
+			return nil, err
+		}
+		return &AdminResetPasswordResult{Mode: ResetModeTemporary, TemporaryPassword: password}, nil
+	}
+}
+
+// AdminResetUserPassword 管理员重置用户密码，mustChange为true时用户下次登录必须修改密码
+func (s *UserService) AdminResetUserPassword(userID uuid.UUID, newPassword string, mustChange bool) error {
+	// 校验密码策略
+	if err := s.passwordPolicyService.ValidateForUser(userID, newPassword); err != nil {
+		return err
+	}
+	
 	hashedPassword, err := crypto.HashPassword(newPassword)
 	if err != nil {
 		return err
 	}
 	
-	return database.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
-		"password":       hashedPassword,
-		"login_attempts": 0,
-		"locked_until":   nil,
-		"status":         models.UserStatusNormal,
-	}).Error
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"password":             hashedPassword,
+			"password_changed_at":  time.Now(),
+			"password_breached":    false,
+			"must_change_password": mustChange,
+			"login_attempts":       0,
+			"locked_until":         nil,
+			"status":               models.UserStatusNormal,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return s.passwordPolicyService.RecordPasswordHistory(tx, userID, hashedPassword)
+	})
+	if err != nil {
+		return err
+	}
+	
+	markMustChangePassword(userID, mustChange)
+	clearLoginFailures(userID)
+	return nil
+}
+
+// sendPasswordResetLink 向用户邮箱发送一次性密码重置链接，返回收件邮箱
+func (s *UserService) sendPasswordResetLink(userID uuid.UUID) (string, error) {
+	var user models.User
+	if err := database.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		return "", err
+	}
+	if user.Email == "" {
+		return "", errors.New("用户未绑定邮箱，无法发送重置链接")
+	}
+	
+	token, err := createPasswordResetToken(user.ID)
+	if err != nil {
+		return "", err
+	}
+	
+	if err := s.emailService.SendPasswordResetEmail(user.Email, passwordResetLink(token), passwordResetTokenTTL, user.Language); err != nil {
+		return "", err
+	}
+	return user.Email, nil
 }
@@ -0,0 +1,637 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	
+	"usercenter/internal/cache"
+	"usercenter/internal/config"
+	"usercenter/internal/database"
+	"usercenter/internal/models"
+	"usercenter/pkg/captcha"
+	"usercenter/pkg/email"
+	"usercenter/pkg/phone"
+	"usercenter/pkg/sms"
+	
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 验证码接收方类型，同一接收方无论通过哪个渠道发送都使用同一个验证码
+const (
+	VerificationTargetEmail = "email"
+	VerificationTargetPhone = "phone"
+	VerificationTargetUser  = "user" // 站内信，接收方为用户ID
+)
+
+// 验证码发送渠道
+const (
+	VerificationChannelEmail = "email"
+	VerificationChannelSMS   = "sms"
+	VerificationChannelVoice = "voice"
+	VerificationChannelInApp = "in_app"
+)
+
+// VerificationPurpose 验证码用途
+type VerificationPurpose struct {
+	Name       string
+	Action     string // 展示给用户的操作名称
+	TTL        time.Duration
+	Length     int
+	Cooldown   time.Duration // 同一接收方两次发送的最小间隔
+	DailyQuota int           // 每个接收方每天的发送上限，0表示不限制
+	Public     bool          // 是否允许未登录时通过通用发送接口发送
+}
+
+// VerificationChannel 验证码发送渠道
+type VerificationChannel interface {
+	Name() string
+	TargetType() string
+	Send(target, code string, purpose *VerificationPurpose, locale string) error
+}
+
+var (
+	verificationPurposesMu sync.RWMutex
+	verificationPurposes   = map[string]VerificationPurpose{}
+)
+
+// RegisterVerificationPurpose 注册验证码用途，同名用途会被覆盖
+func RegisterVerificationPurpose(purpose VerificationPurpose) {
+	verificationPurposesMu.Lock()
+	defer verificationPurposesMu.Unlock()
+	verificationPurposes[purpose.Name] = purpose
+}
+
+func init() {
+	for _, purpose := range []VerificationPurpose{
+		{Name: "register", Action: "用户注册", TTL: 15 * time.Minute, Public: true},
+		{Name: "reset_password", Action: "密码重置", TTL: 15 * time.Minute, Public: true},
+		{Name: "bind_email", Action: "邮箱绑定", TTL: 15 * time.Minute, Public: true},
+		{Name: "bind_phone", Action: "手机号绑定", TTL: 5 * time.Minute, Public: true},
+		{Name: "login_challenge", Action: "登录验证", TTL: 5 * time.Minute},
+		{Name: "reauth", Action: "身份验证", TTL: 5 * time.Minute},
+	} {
+		purpose.Length = 6
+		purpose.Cooldown = time.Minute
+		purpose.DailyQuota = 10
+		RegisterVerificationPurpose(purpose)
+	}
+}
+
+// lookupVerificationPurpose 查找验证码用途，并应用配置文件中的覆盖项
+func lookupVerificationPurpose(name string) (*VerificationPurpose, error) {
+	verificationPurposesMu.RLock()
+	purpose, ok := verificationPurposes[name]
+	verificationPurposesMu.RUnlock()
+	if !ok {
+		return nil, errors.New("不支持的验证码用途")
+	}
+	
+	if override, ok := config.GlobalConfig.Verification.Purposes[name]; ok {
+		if override.TTL > 0 {
+			purpose.TTL = override.TTL
+		}
+		if override.Length > 0 {
+			purpose.Length = override.Length
+		}
+		if override.Cooldown > 0 {
+			purpose.Cooldown = override.Cooldown
+		}
+		if override.DailyQuota > 0 {
+			purpose.DailyQuota = override.DailyQuota
+		}
+	}
+	return &purpose, nil
+}
+
+// SendVerificationRequest 通用验证码发送请求
+type SendVerificationRequest struct {
+	Type        string `json:"type" binding:"required,oneof=email phone"`
+	Target      string `json:"target" binding:"required"`
+	Purpose     string `json:"purpose" binding:"required"`
+	Channel     string `json:"channel"` // 手机号可选sms、voice，默认sms
+	CaptchaID   string `json:"captcha_id"`
+	CaptchaCode string `json:"captcha_code"`
+	Language    string `json:"language"` // 验证码内容使用的语言，为空时使用请求的Accept-Language
+	IP          string `json:"-"`
+}
+
+// VerificationRecordQuery 验证码发送记录查询条件
+type VerificationRecordQuery struct {
+	Page      int       `form:"page"`
+	PageSize  int       `form:"page_size"`
+	Type      string    `form:"type"`
+	Target    string    `form:"target"`
+	Purpose   string    `form:"purpose"`
+	Status    int       `form:"status"`
+	IP        string    `form:"ip"`
+	StartDate time.Time `form:"start_date" time_format:"2006-01-02"`
+	EndDate   time.Time `form:"end_date" time_format:"2006-01-02"`
+}
+
+type VerificationRecordListResponse struct {
+	Total int64                     `json:"total"`
+	Items []models.VerificationCode `json:"items"`
+}
+
+// VerificationStats 验证码发送量统计
+type VerificationStats struct {
+	Daily         []VerificationDailyCount `json:"daily"`
+	BlockReasons  []VerificationGroupCount `json:"block_reasons"`
+	TopBlockedIPs []VerificationGroupCount `json:"top_blocked_ips"`
+	TopTargets    []VerificationGroupCount `json:"top_targets"`
+}
+
+// VerificationDailyCount 按日期、接收方类型和状态统计的发送次数
+type VerificationDailyCount struct {
+	Date   string `json:"date"`
+	Type   string `json:"type"`
+	Status int    `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+type VerificationGroupCount struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// VerificationBlockRequest 添加验证码发送黑名单的请求
+type VerificationBlockRequest struct {
+	Type    string `json:"type" binding:"required,oneof=email phone"`
+	Pattern string `json:"pattern" binding:"required"`
+	Reason  string `json:"reason"`
+}
+
+// 验证码发送被拦截的原因
+const (
+	VerificationBlockBlocklist    = "blocklist"
+	VerificationBlockCooldown     = "cooldown"
+	VerificationBlockPurposeQuota = "purpose_quota"
+	VerificationBlockTargetQuota  = "target_quota"
+	VerificationBlockIPQuota      = "ip_quota"
+	VerificationBlockGlobalQuota  = "global_quota"
+)
+
+// VerificationService 统一的验证码发送与校验
+type VerificationService struct {
+	channels map[string]VerificationChannel
+}
+
+func NewVerificationService() *VerificationService {
+	cfg := config.GlobalConfig
+	smsSvc := sms.Default()
+	
+	s := &VerificationService{channels: map[string]VerificationChannel{}}
+	for _, channel := range []VerificationChannel{
+		&emailChannel{service: email.NewEmailService(&cfg.SMTP)},
+		&smsChannel{service: smsSvc},
+		&voiceChannel{service: smsSvc},
+		&inAppChannel{},
+	} {
+		s.channels[channel.Name()] = channel
+	}
+	return s
+}
+
+// defaultVerificationChannel 接收方类型的默认发送渠道
+func defaultVerificationChannel(targetType string) string {
+	switch targetType {
+	case VerificationTargetEmail:
+		return VerificationChannelEmail
+	case VerificationTargetPhone:
+		return VerificationChannelSMS
+	case VerificationTargetUser:
+		return VerificationChannelInApp
+	default:
+		return ""
+	}
+}
+
+// SendPublic 未登录用户通过通用接口发送验证码，只允许公开的用途
+func (s *VerificationService) SendPublic(req *SendVerificationRequest) error {
+	// 发送前需通过图形验证码，防止批量刷短信
+	if config.GlobalConfig.Verification.RequireCaptcha {
+		if req.CaptchaID == "" && req.CaptchaCode == "" {
+			return ErrCaptchaRequired
+		}
+		if !captcha.VerifyImageCaptcha(req.CaptchaID, req.CaptchaCode) {
+			return ErrCaptchaInvalid
+		}
+	}
+	
+	purpose, err := lookupVerificationPurpose(req.Purpose)
+	if err != nil {
+		return err
+	}
+	if !purpose.Public {
+		return errors.New("不支持的验证码用途")
+	}
+	
+	switch req.Type {
+	case VerificationTargetEmail:
+		// 验证码按小写地址保存，大小写不同的同一邮箱共用一个验证码和发送限制
+		address, err := email.NormalizeAddress(req.Target)
+		if err != nil {
+			return err
+		}
+		req.Target = address
+	case VerificationTargetPhone:
+		// 验证码按E.164号码保存，国内格式和国际格式的同一号码共用一个验证码
+		number, err := phone.Normalize(req.Target)
+		if err != nil {
+			return err
+		}
+		req.Target = number
+	}
+	
+	return s.send(req.Type, req.Target, req.IP, purpose, req.Channel, req.Language)
+}
+
+// Send 向接收方发送验证码，channel为空时使用接收方类型的默认渠道，locale为接收方的语言
+func (s *VerificationService) Send(targetType, target, purpose, channel, locale string) error {
+	p, err := lookupVerificationPurpose(purpose)
+	if err != nil {
+		return err
+	}
+	return s.send(targetType, target, "", p, channel, locale)
+}
+
+// send 检查黑名单和发送限制后生成并发送验证码，每次请求都写入发送记录，被拦截的请求同样记录
+func (s *VerificationService) send(targetType, target, ip string, purpose *VerificationPurpose, channelName, locale string) error {
+	if channelName == "" {
+		channelName = defaultVerificationChannel(targetType)
+	}
+	channel, ok := s.channels[channelName]
+	if !ok || channel.TargetType() != targetType {
+		return errors.New("不支持的发送方式")
+	}
+	
+	record := &models.VerificationCode{
+		Type:    targetType,
+		Target:  target,
+		Purpose: purpose.Name,
+		Channel: channelName,
+		IP:      ip,
+	}
+	if reason, err := s.checkLimits(record, purpose); err != nil {
+		// 无法检查限制（如Redis不可用）时拒绝发送，不记录为拦截
+		if reason == "" {
+			return err
+		}
+		record.Status = models.VerificationStatusBlocked
+		record.BlockReason = reason
+		record.ExpiresAt = time.Now()
+		database.DB.Create(record)
+		return err
+	}
+	
+	code, err := captcha.GenerateCode(record, purpose.Length, purpose.TTL)
+	if err != nil {
+		return err
+	}
+	
+	if err := channel.Send(target, code, purpose, locale); err != nil {
+		database.DB.Model(record).Update("status", models.VerificationStatusFailed)
+		return err
+	}
+	return nil
+}
+
+// verificationQuota 一项每日发送上限
+type verificationQuota struct {
+	key    string
+	limit  int
+	reason string
+}
+
+// checkLimits 依次检查黑名单、发送间隔和每日发送上限，返回拦截原因。
+// 各项上限在一个Redis脚本中检查并累加，并发请求无法同时通过；全部通过后才累加，被拦截的请求不占用配额。
+// 无法完成检查时返回空的拦截原因和错误
+func (s *VerificationService) checkLimits(record *models.VerificationCode, purpose *VerificationPurpose) (string, error) {
+	if s.isBlocked(record.Type, record.Target) {
+		return VerificationBlockBlocklist, errors.New("该接收方已被禁止接收验证码")
+	}
+	
+	canSend, remaining, err := captcha.CheckCodeSendFrequency(record.Target, record.Type, purpose.Cooldown)
+	if err != nil {
+		return "", errors.New("验证码发送失败，请稍后重试")
+	}
+	if !canSend {
+		return VerificationBlockCooldown, fmt.Errorf("发送过于频繁，请在 %d 秒后重试", int(remaining.Seconds()))
+	}
+	
+	today := time.Now().Format("20060102")
+	limits := config.GlobalConfig.Verification.Limits[record.Type]
+	quotas := []verificationQuota{
+		{fmt.Sprintf("verification_daily:%s:%s:%s:%s", purpose.Name, record.Type, record.Target, today), purpose.DailyQuota, VerificationBlockPurposeQuota},
+		{fmt.Sprintf("verification_daily:target:%s:%s:%s", record.Type, record.Target, today), limits.PerTarget, VerificationBlockTargetQuota},
+		{fmt.Sprintf("verification_daily:global:%s:%s", record.Type, today), limits.Global, VerificationBlockGlobalQuota},
+	}
+	if record.IP != "" {
+		quotas = append(quotas, verificationQuota{fmt.Sprintf("verification_daily:ip:%s:%s:%s", record.Type, record.IP, today), limits.PerIP, VerificationBlockIPQuota})
+	}
+	
+	var keys []string
+	var maxCounts []int
+	var active []verificationQuota
+	for _, quota := range quotas {
+		if quota.limit > 0 {
+			keys = append(keys, quota.key)
+			maxCounts = append(maxCounts, quota.limit)
+			active = append(active, quota)
+		}
+	}
+	if len(keys) == 0 {
+		return "", nil
+	}
+	
+	exceeded, err := cache.ReserveCounters(keys, maxCounts, 24*time.Hour)
+	if err != nil {
+		return "", errors.New("验证码发送失败，请稍后重试")
+	}
+	if exceeded < 0 {
+		return "", nil
+	}
+	if active[exceeded].reason == VerificationBlockGlobalQuota {
+		return active[exceeded].reason, errors.New("今日验证码发送量已达上限，请稍后再试")
+	}
+	return active[exceeded].reason, errors.New("今日验证码发送次数已达上限")
+}
+
+// isBlocked 接收方是否在黑名单中
+func (s *VerificationService) isBlocked(targetType, target string) bool {
+	var blocks []models.VerificationBlock
+	if err := database.DB.Where("type = ?", targetType).Find(&blocks).Error; err != nil {
+		return false
+	}
+	for _, block := range blocks {
+		if matchVerificationBlock(block.Pattern, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchVerificationBlock 黑名单匹配：@开头匹配邮箱域名，*结尾匹配号码前缀，其余完全匹配（不区分大小写）
+func matchVerificationBlock(pattern, target string) bool {
+	switch {
+	case strings.HasPrefix(pattern, "@"):
+		return strings.HasSuffix(strings.ToLower(target), strings.ToLower(pattern))
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(target, strings.TrimSuffix(pattern, "*"))
+	default:
+		return strings.EqualFold(pattern, target)
+	}
+}
+
+// Verify 校验验证码，验证成功后验证码失效
+func (s *VerificationService) Verify(targetType, target, code, purpose string) bool {
+	return captcha.VerifyCode(targetType, target, code, purpose)
+}
+
+// Check 校验验证码但不使用，之后仍需调用Verify
+func (s *VerificationService) Check(targetType, target, code, purpose string) bool {
+	return captcha.CheckCode(targetType, target, code, purpose)
+}
+
+// ListRecords 获取验证码发送记录
+func (s *VerificationService) ListRecords(query *VerificationRecordQuery) (*VerificationRecordListResponse, error) {
+	var records []models.VerificationCode
+	var total int64
+	
+	db := filterVerificationRecords(database.DB.Model(&models.VerificationCode{}), query)
+	if query.Target != "" {
+		db = db.Where("target LIKE ?", "%"+query.Target+"%")
+	}
+	if query.Purpose != "" {
+		db = db.Where("purpose = ?", query.Purpose)
+	}
+	if query.Status > 0 {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.IP != "" {
+		db = db.Where("ip = ?", query.IP)
+	}
+	
+	db.Count(&total)
+	
+	offset := (query.Page - 1) * query.PageSize
+	err := db.Offset(offset).Limit(query.PageSize).Order("created_at desc").Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	
+	return &VerificationRecordListResponse{
+		Total: total,
+		Items: records,
+	}, nil
+}
+
+// Stats 统计发送量和拦截情况，未指定日期范围时统计最近7天
+func (s *VerificationService) Stats(query *VerificationRecordQuery) (*VerificationStats, error) {
+	if query.StartDate.IsZero() {
+		now := time.Now()
+		query.StartDate = time.Date(now.Year(), now.Month(), now.Day()-6, 0, 0, 0, 0, now.Location())
+	}
+	
+	stats := &VerificationStats{}
+	
+	err := filterVerificationRecords(database.DB.Model(&models.VerificationCode{}), query).
+		Select("TO_CHAR(created_at, 'YYYY-MM-DD') AS date, type, status, COUNT(*) AS count").
+		Group("date, type, status").
+		Order("date").
+		Scan(&stats.Daily).Error
+	if err != nil {
+		return nil, err
+	}
+	
+	err = filterVerificationRecords(database.DB.Model(&models.VerificationCode{}), query).
+		Where("status = ?", models.VerificationStatusBlocked).
+		Select("block_reason AS key, COUNT(*) AS count").
+		Group("block_reason").
+		Order("count desc").
+		Scan(&stats.BlockReasons).Error
+	if err != nil {
+		return nil, err
+	}
+	
+	err = filterVerificationRecords(database.DB.Model(&models.VerificationCode{}), query).
+		Where("status = ? AND ip <> ''", models.VerificationStatusBlocked).
+		Select("ip AS key, COUNT(*) AS count").
+		Group("ip").
+		Order("count desc").
+		Limit(10).
+		Scan(&stats.TopBlockedIPs).Error
+	if err != nil {
+		return nil, err
+	}
+	
+	err = filterVerificationRecords(database.DB.Model(&models.VerificationCode{}), query).
+		Select("target AS key, COUNT(*) AS count").
+		Group("target").
+		Order("count desc").
+		Limit(10).
+		Scan(&stats.TopTargets).Error
+	if err != nil {
+		return nil, err
+	}
+	
+	return stats, nil
+}
+
+// filterVerificationRecords 按接收方类型和日期范围过滤发送记录
+func filterVerificationRecords(db *gorm.DB, query *VerificationRecordQuery) *gorm.DB {
+	if query.Type != "" {
+		db = db.Where("type = ?", query.Type)
+	}
+	if !query.StartDate.IsZero() {
+		db = db.Where("created_at >= ?", query.StartDate)
+	}
+	if !query.EndDate.IsZero() {
+		db = db.Where("created_at < ?", query.EndDate.AddDate(0, 0, 1))
+	}
+	return db
+}
+
+// ListBlocks 获取验证码发送黑名单
+func (s *VerificationService) ListBlocks(targetType string) ([]models.VerificationBlock, error) {
+	var blocks []models.VerificationBlock
+	db := database.DB.Model(&models.VerificationBlock{})
+	if targetType != "" {
+		db = db.Where("type = ?", targetType)
+	}
+	if err := db.Order("created_at desc").Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// CreateBlock 添加验证码发送黑名单
+func (s *VerificationService) CreateBlock(req *VerificationBlockRequest, operatorID uuid.UUID) (*models.VerificationBlock, error) {
+	pattern := strings.TrimSpace(req.Pattern)
+	if pattern == "" || pattern == "@" || pattern == "*" {
+		return nil, errors.New("黑名单规则不能为空")
+	}
+	if req.Type == VerificationTargetPhone && !strings.HasSuffix(pattern, "*") {
+		// 完整号码转换为与发送记录一致的E.164格式，前缀规则需直接使用E.164前缀
+		number, err := phone.Normalize(pattern)
+		if err != nil {
+			return nil, err
+		}
+		pattern = number
+	}
+	
+	block := models.VerificationBlock{
+		Type:      req.Type,
+		Pattern:   pattern,
+		Reason:    req.Reason,
+		CreatedBy: operatorID,
+	}
+	if err := database.DB.Create(&block).Error; err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// DeleteBlock 删除验证码发送黑名单
+func (s *VerificationService) DeleteBlock(id uuid.UUID) error {
+	result := database.DB.Where("id = ?", id).Delete(&models.VerificationBlock{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("黑名单规则不存在")
+	}
+	return nil
+}
+
+// emailChannel 邮件验证码
+type emailChannel struct {
+	service *email.EmailService
+}
+
+func (c *emailChannel) Name() string {
+	return VerificationChannelEmail
+}
+
+func (c *emailChannel) TargetType() string {
+	return VerificationTargetEmail
+}
+
+func (c *emailChannel) Send(target, code string, purpose *VerificationPurpose, locale string) error {
+	return c.service.SendVerificationCode(target, code, purpose.Action, purpose.TTL, locale)
+}
+
+// smsChannel 短信验证码
+type smsChannel struct {
+	service *sms.SMSService
+}
+
+func (c *smsChannel) Name() string {
+	return VerificationChannelSMS
+}
+
+func (c *smsChannel) TargetType() string {
+	return VerificationTargetPhone
+}
+
+func (c *smsChannel) Send(target, code string, purpose *VerificationPurpose, locale string) error {
+	if c.service == nil {
+		return errors.New("短信服务未配置")
+	}
+	return c.service.SendVerificationCode(target, code, purpose.TTL, locale)
+}
+
+// voiceChannel 语音验证码，供收不到短信或视障用户使用
+type voiceChannel struct {
+	service *sms.SMSService
+}
+
+func (c *voiceChannel) Name() string {
+	return VerificationChannelVoice
+}
+
+func (c *voiceChannel) TargetType() string {
+	return VerificationTargetPhone
+}
+
+func (c *voiceChannel) Send(target, code string, purpose *VerificationPurpose, locale string) error {
+	if c.service == nil {
+		return errors.New("语音服务未配置")
+	}
+	return c.service.SendVoiceCode(target, code)
+}
+
+// inAppChannel 站内信验证码，接收方为用户ID
+type inAppChannel struct{}
+
+func (c *inAppChannel) Name() string {
+	return VerificationChannelInApp
+}
+
+func (c *inAppChannel) TargetType() string {
+	return VerificationTargetUser
+}
+
+func (c *inAppChannel) Send(target, code string, purpose *VerificationPurpose, locale string) error {
+	userID, err := uuid.Parse(target)
+	if err != nil {
+		return errors.New("用户不存在")
+	}
+	
+	expireAt := time.Now().Add(purpose.TTL)
+	notification := models.SystemNotification{
+		Title:    purpose.Action + "验证码",
+		Content:  fmt.Sprintf("您正在进行%s操作，验证码为：%s，%d分钟内有效。如果这不是您本人操作，请忽略。", purpose.Action, code, int(purpose.TTL.Minutes())),
+		Type:     models.NotificationTypeInfo,
+		Priority: 3,
+		ExpireAt: &expireAt,
+		Recipients: []models.UserNotification{
+			{UserID: userID},
+		},
+	}
+	return database.DB.Create(&notification).Error
+}
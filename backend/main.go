@@ -2,13 +2,27 @@ package main
 
 import (
 	"log"
+	"time"
 	"usercenter/internal/cache"
 	"usercenter/internal/config"
 	"usercenter/internal/database"
 	"usercenter/internal/middleware"
+	"usercenter/internal/models"
 	"usercenter/internal/router"
+	"usercenter/internal/service"
+	"usercenter/pkg/captcha"
+	"usercenter/pkg/crypto"
+	"usercenter/pkg/email"
+	"usercenter/pkg/geoip"
+	"usercenter/pkg/ipfilter"
+	"usercenter/pkg/outbound"
+	"usercenter/pkg/phone"
+	"usercenter/pkg/pwned"
+	"usercenter/pkg/sms"
+	"usercenter/pkg/templates"
 	
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
@@ -55,21 +69,114 @@ func main() {
 		logger.Fatal("Failed to init redis", zap.Error(err))
 	}
 	
+	// 图形验证码类型和存储
+	captcha.Init(&cfg.Captcha)
+	
+	// 手机号解析规则，旧数据中的手机号转换为E.164格式
+	phone.Init(&cfg.Phone)
+	converted, err := service.NormalizeStoredPhones(func(userID uuid.UUID, err error) {
+		logger.Warn("Skipped phone number normalization", zap.String("user_id", userID.String()), zap.Error(err))
+	})
+	if err != nil {
+		logger.Error("Failed to normalize phone numbers", zap.Error(err))
+	} else if converted > 0 {
+		logger.Info("Normalized phone numbers", zap.Int("count", converted))
+	}
+	
+	// 邮件和短信发送队列，需在邮件和短信服务之前设置
+	outbound.Init(&cfg.Outbound)
+	
+	// 邮件和短信内容模板
+	templates.Init(&cfg.Templates)
+	
+	// 邮件发送方式，各服务共用SMTP连接池
+	if err := email.Init(&cfg.SMTP); err != nil {
+		logger.Fatal("Failed to init email transport", zap.Error(err))
+	}
+	
+	// 短信服务商，配置错误时拒绝启动
+	if err := sms.Init(&cfg.SMS); err != nil {
+		logger.Fatal("Failed to init sms providers", zap.Error(err))
+	}
+	
+	// 发送队列worker
+	outbound.Start()
+	
+	// 密码哈希参数
+	argon2Cfg := cfg.Security.Argon2
+	crypto.SetDefaultConfig(&crypto.Config{
+		Time:    argon2Cfg.Time,
+		Memory:  argon2Cfg.Memory,
+		Threads: argon2Cfg.Threads,
+		KeyLen:  argon2Cfg.KeyLen,
+	})
+	
+	// 加载追加的常见弱密码列表
+	if cfg.Security.CommonPasswordsFile != "" {
+		if err := crypto.LoadCommonPasswords(cfg.Security.CommonPasswordsFile); err != nil {
+			logger.Fatal("Failed to load common passwords", zap.Error(err))
+		}
+	}
+	
+	// 已泄露密码检查
+	if cfg.Security.BreachedPasswords.Enabled {
+		checker, err := pwned.NewFileChecker(cfg.Security.BreachedPasswords.Path)
+		if err != nil {
+			logger.Fatal("Failed to open breached password data", zap.Error(err))
+		}
+		pwned.DefaultChecker = checker
+	}
+	
+	// IP地理位置数据库
+	if cfg.GeoIP.Enabled {
+		if err := geoip.Init(&cfg.GeoIP); err != nil {
+			logger.Fatal("Failed to open GeoIP database", zap.Error(err))
+		}
+		defer geoip.Default().Close()
+		service.SetGeoLocator(geoip.Default())
+	}
+	
+	// IP访问规则，规则变更时通过Redis通知各实例重新加载
+	if err := ipfilter.Load(); err != nil {
+		logger.Fatal("Failed to load ip rules", zap.Error(err))
+	}
+	ipfilter.StartSync(5 * time.Minute)
+	
+	// 密码过期提醒
+	service.StartPasswordExpiryReminder(time.Hour)
+	
+	// 自动解除到期的账号锁定
+	service.StartAccountUnlocker(time.Minute)
+	
 	// 设置Gin模式
 	gin.SetMode(cfg.Server.Mode)
 	
 	// 创建Gin实例
 	r := gin.New()
 	
+	// 只信任配置的反向代理转发的客户端IP，IP访问规则和登录防护依赖c.ClientIP()
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
+	r.RemoteIPHeaders = cfg.Server.RemoteIPHeaders
+	
 	// 添加中间件
 	r.Use(middleware.LoggerMiddleware(logger))
 	r.Use(middleware.RecoveryMiddleware())
 	r.Use(middleware.CORSMiddleware())
 	r.Use(middleware.SecurityHeadersMiddleware())
 	r.Use(middleware.HealthCheckMiddleware())
+	r.Use(middleware.IPFilterMiddleware(models.IPRuleScopeGlobal))
 	r.Use(middleware.OperationLogMiddleware())
 	r.Use(middleware.RateLimitMiddleware(cfg.Security.RateLimit.RequestsPerMinute, cfg.Security.RateLimit.Burst))
 	
+	// Cookie会话
+	sessionMiddleware, err := middleware.SessionMiddleware(cfg)
+	if err != nil {
+		logger.Fatal("Failed to init session store", zap.Error(err))
+	}
+	r.Use(sessionMiddleware)
+	
 	// 设置路由
 	router.SetupRoutes(r)
 	
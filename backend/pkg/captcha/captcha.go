@@ -1,192 +1,213 @@
 package captcha
 
 import (
-	"encoding/base64"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"math/rand"
+	"math/big"
 	"time"
 	
 	"usercenter/internal/cache"
+	"usercenter/internal/config"
 	"usercenter/internal/models"
 	"usercenter/internal/database"
 	
 	"github.com/mojocn/base64Captcha"
 )
 
-var store = base64Captcha.DefaultMemStore
+// store 图形验证码存储，默认使用进程内存，启动时由Init按配置替换
+var store base64Captcha.Store = NewMemoryStore(5 * time.Minute)
 
-// ImageCaptcha 图形验证码配置
-type ImageCaptcha struct {
-	Width  int
-	Height int
-	Length int
+// GenerateImageCaptcha 生成默认类型的图形验证码
+func GenerateImageCaptcha() (id, b64s string, err error) {
+	challenge, err := Generate("")
+	if err != nil {
+		return "", "", err
+	}
+	return challenge.ID, challenge.Data, nil
 }
 
-// DefaultImageCaptcha 默认图形验证码配置
-var DefaultImageCaptcha = &ImageCaptcha{
-	Width:  240,
-	Height: 80,
-	Length: 4,
+// VerifyImageCaptcha 验证图形验证码，无论是否正确验证码都只能使用一次
+func VerifyImageCaptcha(id, answer string) bool {
+	if id == "" || answer == "" {
+		return false
+	}
+	return matchAnswer(store.Get(id, true), answer)
 }
 
-// GenerateImageCaptcha 生成图形验证码
-func GenerateImageCaptcha() (id, b64s string, err error) {
-	driver := base64Captcha.NewDriverDigit(
-		DefaultImageCaptcha.Height,
-		DefaultImageCaptcha.Width,
-		DefaultImageCaptcha.Length,
-		0.7,
-		80,
-	)
-	
-	c := base64Captcha.NewCaptcha(driver, store)
-	return c.Generate()
+func codeKey(targetType, target, purpose string) string {
+	return fmt.Sprintf("%s_code:%s:%s", targetType, target, purpose)
 }
 
-// VerifyImageCaptcha 验证图形验证码
-func VerifyImageCaptcha(id, answer string) bool {
-	return store.Verify(id, answer, true)
+func codeAttemptsKey(targetType, target, purpose string) string {
+	return fmt.Sprintf("%s_code_attempts:%s:%s", targetType, target, purpose)
 }
 
-// GenerateEmailCode 生成邮箱验证码
-func GenerateEmailCode(email, purpose string) (string, error) {
-	code := generateNumericCode(6)
-	
-	// 保存到数据库
-	verificationCode := models.VerificationCode{
-		Type:      "email",
-		Target:    email,
-		Code:      code,
-		Purpose:   purpose,
-		ExpiresAt: time.Now().Add(15 * time.Minute), // 15分钟过期
-	}
-	
-	if err := database.DB.Create(&verificationCode).Error; err != nil {
-		return "", err
+// hashCode 计算验证码的HMAC摘要，摘要绑定类型、接收方和用途，Redis和数据库中只保存摘要
+func hashCode(targetType, target, purpose, code string) string {
+	secret := settings.CodeSecret
+	if secret == "" && config.GlobalConfig != nil {
+		secret = config.GlobalConfig.JWT.Secret
 	}
 	
-	// 保存到Redis缓存
-	key := fmt.Sprintf("email_code:%s:%s", email, purpose)
-	if err := cache.Set(key, code, 15*time.Minute); err != nil {
-		return "", err
-	}
-	
-	return code, nil
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(targetType + "\x00" + target + "\x00" + purpose + "\x00" + code))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
-// GenerateSMSCode 生成短信验证码
-func GenerateSMSCode(phone, purpose string) (string, error) {
-	code := generateNumericCode(6)
-	
-	// 保存到数据库
-	verificationCode := models.VerificationCode{
-		Type:      "sms",
-		Target:    phone,
-		Code:      code,
-		Purpose:   purpose,
-		ExpiresAt: time.Now().Add(5 * time.Minute), // 5分钟过期
+// GenerateCode 生成邮箱/短信等验证码并保存摘要，新验证码生成后旧验证码和错误次数一并失效。
+// record中需填写接收方类型（email、phone等）、接收方和用途，同一接收方无论通过哪个渠道发送都使用同一个验证码
+func GenerateCode(record *models.VerificationCode, length int, ttl time.Duration) (string, error) {
+	code, err := generateNumericCode(length)
+	if err != nil {
+		return "", err
 	}
+	hash := hashCode(record.Type, record.Target, record.Purpose, code)
 	
-	if err := database.DB.Create(&verificationCode).Error; err != nil {
+	// 保存到数据库
+	record.Code = hash
+	record.Status = models.VerificationStatusSent
+	record.ExpiresAt = time.Now().Add(ttl)
+	if err := database.DB.Create(record).Error; err != nil {
 		return "", err
 	}
 	
 	// 保存到Redis缓存
-	key := fmt.Sprintf("sms_code:%s:%s", phone, purpose)
-	if err := cache.Set(key, code, 5*time.Minute); err != nil {
+	if err := cache.Set(codeKey(record.Type, record.Target, record.Purpose), hash, ttl); err != nil {
 		return "", err
 	}
+	cache.Del(codeAttemptsKey(record.Type, record.Target, record.Purpose))
 	
 	return code, nil
 }
 
-// VerifyEmailCode 验证邮箱验证码
-func VerifyEmailCode(email, code, purpose string) bool {
-	// 从Redis验证
-	key := fmt.Sprintf("email_code:%s:%s", email, purpose)
-	storedCode, err := cache.Get(key)
-	if err != nil {
+// VerifyCode 验证验证码，错误次数达到上限后验证码作废，验证成功后验证码只能使用一次
+func VerifyCode(targetType, target, code, purpose string) bool {
+	if !CheckCode(targetType, target, code, purpose) {
 		return false
 	}
 	
-	if storedCode != code {
+	// 验证成功后删除验证码，并发请求中只有一个能取到
+	key := codeKey(targetType, target, purpose)
+	if _, err := cache.GetDel(key); err != nil {
 		return false
 	}
-	
-	// 验证成功后删除验证码
-	cache.Del(key)
+	cache.Del(codeAttemptsKey(targetType, target, purpose))
 	
 	// 更新数据库记录为已使用
+	hash := hashCode(targetType, target, purpose, code)
 	database.DB.Model(&models.VerificationCode{}).
-		Where("type = ? AND target = ? AND code = ? AND purpose = ? AND used = false", "email", email, code, purpose).
+		Where("type = ? AND target = ? AND code = ? AND purpose = ? AND used = false", targetType, target, hash, purpose).
 		Update("used", true)
 	
 	return true
 }
 
-// VerifySMSCode 验证短信验证码
-func VerifySMSCode(phone, code, purpose string) bool {
-	// 从Redis验证
-	key := fmt.Sprintf("sms_code:%s:%s", phone, purpose)
-	storedCode, err := cache.Get(key)
-	if err != nil {
+// CheckCode 校验验证码但不使用，用于在执行操作前先确认验证码正确，错误时同样计入错误次数
+func CheckCode(targetType, target, code, purpose string) bool {
+	if code == "" {
 		return false
 	}
 	
-	if storedCode != code {
+	// 先占用一次尝试次数再比较，并发提交的猜测各自占用一次，无法超过错误次数上限
+	attempts, ok := reserveCodeAttempt(targetType, target, purpose)
+	if !ok {
 		return false
 	}
 	
-	// 验证成功后删除验证码
-	cache.Del(key)
+	storedHash, err := cache.Get(codeKey(targetType, target, purpose))
+	if err != nil {
+		return false
+	}
 	
-	// 更新数据库记录为已使用
-	database.DB.Model(&models.VerificationCode{}).
-		Where("type = ? AND target = ? AND code = ? AND purpose = ? AND used = false", "sms", phone, code, purpose).
-		Update("used", true)
+	hash := hashCode(targetType, target, purpose, code)
+	if !hmac.Equal([]byte(storedHash), []byte(hash)) {
+		if settings.CodeMaxAttempts > 0 && attempts >= int64(settings.CodeMaxAttempts) {
+			invalidateCode(targetType, target, purpose)
+		}
+		return false
+	}
 	
+	// 验证码正确时归还占用的次数，先校验再使用的流程不会因此多计一次
+	if settings.CodeMaxAttempts > 0 {
+		cache.Decr(codeAttemptsKey(targetType, target, purpose))
+	}
 	return true
 }
 
-// CheckCodeSendFrequency 检查验证码发送频率
-func CheckCodeSendFrequency(target, codeType string) (bool, time.Duration) {
-	key := fmt.Sprintf("send_frequency:%s:%s", codeType, target)
+// reserveCodeAttempt 原子递增尝试次数，返回递增后的次数，超过上限时作废验证码并返回false。
+// 未限制错误次数时不计数
+func reserveCodeAttempt(targetType, target, purpose string) (int64, bool) {
+	maxAttempts := settings.CodeMaxAttempts
+	if maxAttempts <= 0 {
+		return 0, true
+	}
 	
-	exists, err := cache.Exists(key)
+	attemptsKey := codeAttemptsKey(targetType, target, purpose)
+	attempts, err := cache.Incr(attemptsKey)
 	if err != nil {
-		return true, 0
+		return 0, false
+	}
+	if attempts == 1 {
+		// 计数与验证码同时过期，验证码不存在时不保留计数
+		ttl, err := cache.TTL(codeKey(targetType, target, purpose))
+		if err != nil || ttl <= 0 {
+			cache.Del(attemptsKey)
+			return 0, false
+		}
+		cache.Expire(attemptsKey, ttl)
+	}
+	if attempts > int64(maxAttempts) {
+		invalidateCode(targetType, target, purpose)
+		return attempts, false
+	}
+	return attempts, true
+}
+
+// invalidateCode 错误次数达到上限后作废当前验证码
+func invalidateCode(targetType, target, purpose string) {
+	cache.Del(codeKey(targetType, target, purpose))
+	database.DB.Model(&models.VerificationCode{}).
+		Where("type = ? AND target = ? AND purpose = ? AND used = false", targetType, target, purpose).
+		Update("used", true)
+}
+
+// CheckCodeSendFrequency 检查验证码发送间隔，允许发送时开始新的冷却期。
+// 通过SETNX占用冷却期，并发请求中只有一个能通过；Redis不可用时返回错误，由调用方拒绝发送
+func CheckCodeSendFrequency(target, codeType string, cooldown time.Duration) (bool, time.Duration, error) {
+	if cooldown <= 0 {
+		return true, 0, nil
 	}
 	
-	if exists {
+	key := fmt.Sprintf("send_frequency:%s:%s", codeType, target)
+	ok, err := cache.SetNX(key, "1", cooldown)
+	if err != nil {
+		return false, 0, err
+	}
+	if !ok {
 		// 获取剩余时间
-		ttl := cache.RDB.TTL(cache.RDB.Context(), key).Val()
-		return false, ttl
+		ttl, _ := cache.TTL(key)
+		return false, ttl, nil
 	}
-	
-	// 设置发送频率限制（60秒）
-	cache.Set(key, "1", 60*time.Second)
-	return true, 0
+	return true, 0, nil
 }
 
-// generateNumericCode 生成数字验证码
-func generateNumericCode(length int) string {
-	rand.Seed(time.Now().UnixNano())
-	code := ""
-	for i := 0; i < length; i++ {
-		code += fmt.Sprintf("%d", rand.Intn(10))
-	}
-	return code
+// generateNumericCode 使用加密安全的随机数生成数字验证码
+func generateNumericCode(length int) (string, error) {
+	digits := make([]byte, length)
+	for i := range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits[i] = byte('0' + n.Int64())
+	}
+	return string(digits), nil
 }
 
-// GenerateBase64Captcha 生成Base64编码的验证码
+// GenerateBase64Captcha 生成Base64编码的验证码，返回完整的Data URL
 func GenerateBase64Captcha() (string, string, error) {
-	id, b64s, err := GenerateImageCaptcha()
-	if err != nil {
-		return "", "", err
-	}
-	
-	// 将Base64字符串转换为完整的Data URL
-	dataURL := "data:image/png;base64," + b64s
-	
-	return id, dataURL, nil
+	return GenerateImageCaptcha()
 }
@@ -0,0 +1,186 @@
+package captcha
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	
+	"usercenter/internal/database"
+	"usercenter/internal/models"
+	
+	"gorm.io/gorm"
+	"gorm.io/gorm/utils/tests"
+)
+
+// setupCode 准备Redis和只生成SQL的数据库，按错误次数上限生成一个邮箱验证码
+func setupCode(t *testing.T, maxAttempts int) (string, func(time.Duration)) {
+	t.Helper()
+	mr := setupRedis(t)
+	
+	db, err := gorm.Open(tests.DummyDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatalf("open dummy db: %v", err)
+	}
+	prevDB, prevSettings := database.DB, settings
+	database.DB = db
+	settings.CodeSecret = "test-secret"
+	settings.CodeMaxAttempts = maxAttempts
+	t.Cleanup(func() { database.DB, settings = prevDB, prevSettings })
+	
+	record := &models.VerificationCode{Type: "email", Target: "user@example.com", Purpose: "register"}
+	code, err := GenerateCode(record, 6, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("GenerateCode() error = %v", err)
+	}
+	return code, mr.FastForward
+}
+
+// wrongCode 返回与正确验证码不同的第n个验证码
+func wrongCode(code string, n int) string {
+	for i := n; ; i++ {
+		if guess := fmt.Sprintf("%06d", i); guess != code {
+			return guess
+		}
+	}
+}
+
+func TestVerifyCodeAttempts(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		wrong       int
+		want        bool
+	}{
+		{name: "首次即正确", maxAttempts: 3, wrong: 0, want: true},
+		{name: "未达上限时仍可验证", maxAttempts: 3, wrong: 2, want: true},
+		{name: "达到上限后作废", maxAttempts: 3, wrong: 3, want: false},
+		{name: "超过上限后作废", maxAttempts: 3, wrong: 5, want: false},
+		{name: "不限制错误次数", maxAttempts: 0, wrong: 10, want: true},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := setupCode(t, tt.maxAttempts)
+			for i := 0; i < tt.wrong; i++ {
+				if VerifyCode("email", "user@example.com", wrongCode(code, i), "register") {
+					t.Fatalf("wrong code #%d accepted", i)
+				}
+			}
+			if got := VerifyCode("email", "user@example.com", code, "register"); got != tt.want {
+				t.Errorf("VerifyCode() after %d wrong codes = %v, want %v", tt.wrong, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyCodeSingleUse(t *testing.T) {
+	code, _ := setupCode(t, 3)
+	
+	if !VerifyCode("email", "user@example.com", code, "register") {
+		t.Fatal("first VerifyCode() = false, want true")
+	}
+	if VerifyCode("email", "user@example.com", code, "register") {
+		t.Error("second VerifyCode() = true, want false")
+	}
+}
+
+func TestVerifyCodeBoundToTargetAndPurpose(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		purpose string
+	}{
+		{name: "其他接收方", target: "other@example.com", purpose: "register"},
+		{name: "其他用途", target: "user@example.com", purpose: "reset_password"},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := setupCode(t, 3)
+			if VerifyCode("email", tt.target, code, tt.purpose) {
+				t.Errorf("VerifyCode(%q, %q) = true, want false", tt.target, tt.purpose)
+			}
+		})
+	}
+}
+
+func TestVerifyCodeExpired(t *testing.T) {
+	code, fastForward := setupCode(t, 3)
+	fastForward(5 * time.Minute)
+	
+	if VerifyCode("email", "user@example.com", code, "register") {
+		t.Error("VerifyCode() after expiry = true, want false")
+	}
+}
+
+func TestCheckCodeDoesNotConsumeAttempt(t *testing.T) {
+	code, _ := setupCode(t, 1)
+	
+	if !CheckCode("email", "user@example.com", code, "register") {
+		t.Fatal("CheckCode() = false, want true")
+	}
+	if !VerifyCode("email", "user@example.com", code, "register") {
+		t.Error("VerifyCode() after CheckCode() = false, want true")
+	}
+}
+
+func TestCheckCodeConcurrentGuesses(t *testing.T) {
+	const maxAttempts = 3
+	code, _ := setupCode(t, maxAttempts)
+	
+	// 并发提交的猜测中包含正确验证码，最多只有maxAttempts次能参与比较
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := 0; i < 50; i++ {
+		guess := wrongCode(code, i)
+		if i == 25 {
+			guess = code
+		}
+		wg.Add(1)
+		go func(guess string) {
+			defer wg.Done()
+			if VerifyCode("email", "user@example.com", guess, "register") {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}(guess)
+	}
+	wg.Wait()
+	
+	if accepted > 1 {
+		t.Fatalf("accepted %d guesses, want at most 1", accepted)
+	}
+	if VerifyCode("email", "user@example.com", code, "register") {
+		t.Error("code still valid after exceeding max attempts")
+	}
+}
+
+func TestCheckCodeSendFrequency(t *testing.T) {
+	mr := setupRedis(t)
+	
+	tests := []struct {
+		name     string
+		advance  time.Duration
+		cooldown time.Duration
+		want     bool
+	}{
+		{name: "首次发送", cooldown: time.Minute, want: true},
+		{name: "冷却期内", advance: 30 * time.Second, cooldown: time.Minute, want: false},
+		{name: "冷却期结束", advance: 30 * time.Second, cooldown: time.Minute, want: true},
+		{name: "未设置冷却期", cooldown: 0, want: true},
+	}
+	
+	for _, tt := range tests {
+		mr.FastForward(tt.advance)
+		ok, _, err := CheckCodeSendFrequency("user@example.com", "email", tt.cooldown)
+		if err != nil {
+			t.Fatalf("%s: CheckCodeSendFrequency() error = %v", tt.name, err)
+		}
+		if ok != tt.want {
+			t.Errorf("%s: CheckCodeSendFrequency() = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package captcha
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	
+	"usercenter/internal/config"
+	
+	"github.com/mojocn/base64Captcha"
+)
+
+// 图形验证码类型
+const (
+	DriverDigit  = "digit"  // 数字
+	DriverString = "string" // 字母数字，校验时不区分大小写
+	DriverMath   = "math"   // 算术表达式
+	DriverAudio  = "audio"  // 语音朗读数字，供视障用户使用
+	DriverSlider = "slider" // 滑块拼图，答案为缺口的横坐标
+)
+
+// ErrUnsupportedDriver 不支持的验证码类型
+var ErrUnsupportedDriver = errors.New("不支持的验证码类型")
+
+// stringSource 字母数字验证码的字符集，去掉了容易混淆的0、1、i、l、o
+const stringSource = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// Challenge 生成的验证码，Data为图片或音频的Data URL，滑块验证码另有拼图块
+type Challenge struct {
+	ID     string `json:"captcha_id"`
+	Type   string `json:"captcha_type"`
+	Data   string `json:"captcha_img"`
+	Piece  string `json:"captcha_piece,omitempty"`   // 滑块拼图块图片
+	PieceY int    `json:"captcha_piece_y,omitempty"` // 拼图块的纵坐标
+}
+
+// settings 当前的验证码配置，启动时由Init按配置替换
+var settings = config.CaptchaConfig{
+	Driver:          DriverDigit,
+	Width:           240,
+	Height:          80,
+	Length:          4,
+	AudioLanguage:   "zh",
+	SliderTolerance: 5,
+}
+
+// newDriver 创建指定类型的验证码驱动
+func newDriver(kind string) (base64Captcha.Driver, error) {
+	cfg := settings
+	switch kind {
+	case DriverDigit:
+		return base64Captcha.NewDriverDigit(cfg.Height, cfg.Width, cfg.Length, 0.7, 80), nil
+	case DriverString:
+		return base64Captcha.NewDriverString(cfg.Height, cfg.Width, 20, base64Captcha.OptionShowHollowLine,
+			cfg.Length, stringSource, nil, nil, nil), nil
+	case DriverMath:
+		return base64Captcha.NewDriverMath(cfg.Height, cfg.Width, 20, base64Captcha.OptionShowHollowLine,
+			nil, nil, nil), nil
+	case DriverAudio:
+		return base64Captcha.NewDriverAudio(cfg.Length, cfg.AudioLanguage), nil
+	case DriverSlider:
+		return newDriverSlider(cfg.Width, cfg.Height), nil
+	default:
+		return nil, ErrUnsupportedDriver
+	}
+}
+
+// Generate 生成指定类型的验证码，kind为空时使用配置的默认类型，其他类型需在AllowedDrivers中，
+// 防止客户端自行选择最容易识别的类型。存储的答案带有类型前缀，校验时按类型比较
+func Generate(kind string) (*Challenge, error) {
+	if kind == "" {
+		kind = settings.Driver
+	}
+	if !driverAllowed(kind) {
+		return nil, ErrUnsupportedDriver
+	}
+	driver, err := newDriver(kind)
+	if err != nil {
+		return nil, err
+	}
+	
+	id, question, answer := driver.GenerateIdQuestionAnswer()
+	item, err := driver.DrawCaptcha(question)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Set(id, kind+":"+answer); err != nil {
+		return nil, err
+	}
+	
+	challenge := &Challenge{
+		ID:   id,
+		Type: kind,
+		Data: item.EncodeB64string(),
+	}
+	if slider, ok := item.(*sliderItem); ok {
+		challenge.Piece = slider.pieceB64string()
+		challenge.PieceY = slider.y
+	}
+	return challenge, nil
+}
+
+// driverAllowed 判断客户端能否使用该类型，默认类型和AllowedDrivers中的类型可用
+func driverAllowed(kind string) bool {
+	if kind == settings.Driver {
+		return true
+	}
+	for _, allowed := range settings.AllowedDrivers {
+		if kind == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnswer 按验证码类型比较答案
+func matchAnswer(stored, answer string) bool {
+	kind, expected, found := strings.Cut(stored, ":")
+	if !found {
+		return false
+	}
+	answer = strings.TrimSpace(answer)
+	
+	switch kind {
+	case DriverString:
+		return strings.EqualFold(expected, answer)
+	case DriverSlider:
+		// 缺口横坐标只保存在服务端，验证码与其他类型一样读取后即删除，每个缺口只能提交一次
+		target, err := strconv.Atoi(expected)
+		if err != nil {
+			return false
+		}
+		offset, err := strconv.Atoi(answer)
+		if err != nil {
+			return false
+		}
+		diff := offset - target
+		if diff < 0 {
+			diff = -diff
+		}
+		return diff <= settings.SliderTolerance
+	default:
+		return expected == answer
+	}
+}
@@ -0,0 +1,101 @@
+package captcha
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchAnswer(t *testing.T) {
+	tests := []struct {
+		name   string
+		stored string
+		answer string
+		want   bool
+	}{
+		{name: "数字正确", stored: "digit:1234", answer: "1234", want: true},
+		{name: "数字错误", stored: "digit:1234", answer: "1235", want: false},
+		{name: "忽略首尾空白", stored: "digit:1234", answer: " 1234 ", want: true},
+		{name: "字母数字不区分大小写", stored: "string:ab3d", answer: "AB3D", want: true},
+		{name: "字母数字错误", stored: "string:ab3d", answer: "ab3e", want: false},
+		{name: "算术结果", stored: "math:12", answer: "12", want: true},
+		{name: "算术结果错误", stored: "math:12", answer: "21", want: false},
+		{name: "语音数字", stored: "audio:5678", answer: "5678", want: true},
+		{name: "滑块位置准确", stored: "slider:120", answer: "120", want: true},
+		{name: "滑块误差在范围内", stored: "slider:120", answer: "115", want: true},
+		{name: "滑块误差超出范围", stored: "slider:120", answer: "126", want: false},
+		{name: "滑块答案不是数字", stored: "slider:120", answer: "120px", want: false},
+		{name: "其他类型区分大小写", stored: "digit:ab", answer: "AB", want: false},
+		{name: "验证码不存在", stored: "", answer: "", want: false},
+		{name: "缺少类型前缀", stored: "1234", answer: "1234", want: false},
+		{name: "空答案", stored: "digit:1234", answer: "", want: false},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchAnswer(tt.stored, tt.answer); got != tt.want {
+				t.Errorf("matchAnswer(%q, %q) = %v, want %v", tt.stored, tt.answer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateRejectsDisallowedDriver(t *testing.T) {
+	prevSettings, prevStore := settings, store
+	t.Cleanup(func() { settings, store = prevSettings, prevStore })
+	store = NewMemoryStore(time.Minute)
+	
+	tests := []struct {
+		name    string
+		allowed []string
+		kind    string
+		wantErr bool
+	}{
+		{name: "默认类型", kind: "", wantErr: false},
+		{name: "显式指定默认类型", kind: DriverDigit, wantErr: false},
+		{name: "未开放的类型", kind: DriverString, wantErr: true},
+		{name: "已开放的类型", allowed: []string{DriverAudio}, kind: DriverAudio, wantErr: false},
+		{name: "已开放的滑块", allowed: []string{DriverSlider}, kind: DriverSlider, wantErr: false},
+		{name: "未知类型", allowed: []string{"puzzle"}, kind: "puzzle", wantErr: true},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings.AllowedDrivers = tt.allowed
+			challenge, err := Generate(tt.kind)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generate(%q) error = %v, wantErr %v", tt.kind, err, tt.wantErr)
+			}
+			if err == nil && store.Get(challenge.ID, false) == "" {
+				t.Errorf("Generate(%q) did not store the answer", tt.kind)
+			}
+		})
+	}
+}
+
+func TestSliderVerifiedOnServer(t *testing.T) {
+	prevSettings, prevStore := settings, store
+	t.Cleanup(func() { settings, store = prevSettings, prevStore })
+	store = NewMemoryStore(time.Minute)
+	settings.Driver = DriverSlider
+	
+	challenge, err := Generate("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenge.Piece == "" {
+		t.Fatal("slider challenge has no piece image")
+	}
+	
+	// 响应中只有拼图块的纵坐标，缺口横坐标只保存在服务端
+	kind, x, _ := strings.Cut(store.Get(challenge.ID, false), ":")
+	if kind != DriverSlider {
+		t.Fatalf("stored answer type = %q, want %q", kind, DriverSlider)
+	}
+	if !VerifyImageCaptcha(challenge.ID, x) {
+		t.Fatal("VerifyImageCaptcha rejected the correct offset")
+	}
+	if VerifyImageCaptcha(challenge.ID, x) {
+		t.Error("slider captcha accepted twice")
+	}
+}
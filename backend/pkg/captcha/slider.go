@@ -0,0 +1,122 @@
+package captcha
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	
+	"github.com/mojocn/base64Captcha"
+)
+
+// sliderPieceSize 拼图块边长
+const sliderPieceSize = 40
+
+// driverSlider 滑块拼图验证码：背景图上挖出一个缺口，用户将拼图块拖到缺口处，
+// 提交拼图块的横坐标
+type driverSlider struct {
+	width  int
+	height int
+}
+
+func newDriverSlider(width, height int) *driverSlider {
+	if height < sliderPieceSize+10 {
+		height = sliderPieceSize + 10
+	}
+	if width < sliderPieceSize*3 {
+		width = sliderPieceSize * 3
+	}
+	return &driverSlider{width: width, height: height}
+}
+
+// GenerateIdQuestionAnswer 随机生成缺口位置，问题为"x,y"，答案为x。
+// 缺口不出现在最左侧的拼图块初始位置
+func (d *driverSlider) GenerateIdQuestionAnswer() (id, q, a string) {
+	x := sliderPieceSize + 10 + rand.Intn(d.width-sliderPieceSize*2-10)
+	y := 5 + rand.Intn(d.height-sliderPieceSize-10)
+	
+	id = base64Captcha.RandomId()
+	a = strconv.Itoa(x)
+	q = a + "," + strconv.Itoa(y)
+	return id, q, a
+}
+
+// DrawCaptcha 绘制带缺口的背景图和拼图块
+func (d *driverSlider) DrawCaptcha(content string) (base64Captcha.Item, error) {
+	xs, ys, _ := strings.Cut(content, ",")
+	x, _ := strconv.Atoi(xs)
+	y, _ := strconv.Atoi(ys)
+	
+	background := image.NewRGBA(image.Rect(0, 0, d.width, d.height))
+	start := randomColor()
+	end := randomColor()
+	for px := 0; px < d.width; px++ {
+		c := blend(start, end, float64(px)/float64(d.width))
+		for py := 0; py < d.height; py++ {
+			background.Set(px, py, c)
+		}
+	}
+	// 随机色块作为干扰，使缺口无法仅凭颜色定位
+	for i := 0; i < 12; i++ {
+		size := 10 + rand.Intn(30)
+		rect := image.Rect(rand.Intn(d.width), rand.Intn(d.height), 0, 0)
+		rect.Max = rect.Min.Add(image.Pt(size, size))
+		draw.Draw(background, rect, &image.Uniform{randomColor()}, image.Point{}, draw.Over)
+	}
+	
+	pieceRect := image.Rect(x, y, x+sliderPieceSize, y+sliderPieceSize)
+	piece := image.NewRGBA(image.Rect(0, 0, sliderPieceSize, sliderPieceSize))
+	draw.Draw(piece, piece.Bounds(), background, pieceRect.Min, draw.Src)
+	
+	// 缺口处压暗
+	draw.Draw(background, pieceRect, &image.Uniform{color.RGBA{0, 0, 0, 140}}, image.Point{}, draw.Over)
+	
+	return &sliderItem{background: background, piece: piece, y: y}, nil
+}
+
+// sliderItem 滑块验证码图片
+type sliderItem struct {
+	background *image.RGBA
+	piece      *image.RGBA
+	y          int
+}
+
+// WriteTo 输出背景图PNG
+func (item *sliderItem) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, item.background); err != nil {
+		return 0, err
+	}
+	return buf.WriteTo(w)
+}
+
+// EncodeB64string 背景图的Data URL
+func (item *sliderItem) EncodeB64string() string {
+	return encodePNG(item.background)
+}
+
+// pieceB64string 拼图块的Data URL
+func (item *sliderItem) pieceB64string() string {
+	return encodePNG(item.piece)
+}
+
+func encodePNG(img image.Image) string {
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return "data:" + base64Captcha.MimeTypeImage + ";base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func randomColor() color.RGBA {
+	return color.RGBA{uint8(80 + rand.Intn(150)), uint8(80 + rand.Intn(150)), uint8(80 + rand.Intn(150)), 255}
+}
+
+func blend(a, b color.RGBA, t float64) color.RGBA {
+	mix := func(x, y uint8) uint8 { return uint8(float64(x)*(1-t) + float64(y)*t) }
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
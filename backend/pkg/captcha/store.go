@@ -0,0 +1,142 @@
+package captcha
+
+import (
+	"sync"
+	"time"
+	
+	"usercenter/internal/cache"
+	"usercenter/internal/config"
+	
+	"github.com/mojocn/base64Captcha"
+)
+
+// 图形验证码存储类型
+const (
+	StoreRedis  = "redis"
+	StoreMemory = "memory"
+)
+
+// RedisStore 基于Redis的图形验证码存储，多实例部署时任一实例生成的验证码都能在其他实例校验。
+// 验证码到期自动删除，校验时原子地读取并删除，保证只能使用一次
+type RedisStore struct {
+	prefix     string
+	expiration time.Duration
+}
+
+// NewRedisStore 创建Redis验证码存储
+func NewRedisStore(expiration time.Duration) *RedisStore {
+	return &RedisStore{
+		prefix:     "captcha:",
+		expiration: expiration,
+	}
+}
+
+// Set 保存验证码答案
+func (s *RedisStore) Set(id string, value string) error {
+	return cache.Set(s.prefix+id, value, s.expiration)
+}
+
+// Get 获取验证码答案，clear为true时同时删除
+func (s *RedisStore) Get(id string, clear bool) string {
+	var value string
+	var err error
+	if clear {
+		value, err = cache.GetDel(s.prefix + id)
+	} else {
+		value, err = cache.Get(s.prefix + id)
+	}
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+// Verify 校验验证码答案，clear为true时无论是否正确都删除验证码
+func (s *RedisStore) Verify(id, answer string, clear bool) bool {
+	value := s.Get(id, clear)
+	return value != "" && value == answer
+}
+
+// MemoryStore 进程内存验证码存储，仅适用于单实例部署和测试。
+// 读取时检查过期时间，过期的验证码在保存新验证码时清理
+type MemoryStore struct {
+	mu         sync.Mutex
+	expiration time.Duration
+	items      map[string]memoryItem
+	lastSweep  time.Time
+	now        func() time.Time
+}
+
+type memoryItem struct {
+	value     string
+	expiresAt time.Time
+}
+
+// NewMemoryStore 创建进程内存验证码存储
+func NewMemoryStore(expiration time.Duration) *MemoryStore {
+	return &MemoryStore{
+		expiration: expiration,
+		items:      map[string]memoryItem{},
+		now:        time.Now,
+	}
+}
+
+// Set 保存验证码答案
+func (s *MemoryStore) Set(id string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	now := s.now()
+	if now.Sub(s.lastSweep) >= s.expiration {
+		for key, item := range s.items {
+			if !now.Before(item.expiresAt) {
+				delete(s.items, key)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.items[id] = memoryItem{value: value, expiresAt: now.Add(s.expiration)}
+	return nil
+}
+
+// Get 获取验证码答案，clear为true时同时删除，过期的验证码返回空
+func (s *MemoryStore) Get(id string, clear bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	item, ok := s.items[id]
+	if !ok {
+		return ""
+	}
+	expired := !s.now().Before(item.expiresAt)
+	if clear || expired {
+		delete(s.items, id)
+	}
+	if expired {
+		return ""
+	}
+	return item.value
+}
+
+// Verify 校验验证码答案，clear为true时无论是否正确都删除验证码
+func (s *MemoryStore) Verify(id, answer string, clear bool) bool {
+	value := s.Get(id, clear)
+	return value != "" && value == answer
+}
+
+// SetStore 设置图形验证码存储
+func SetStore(s base64Captcha.Store) {
+	store = s
+}
+
+// Init 按配置设置图形验证码类型和存储
+func Init(cfg *config.CaptchaConfig) {
+	settings = *cfg
+	
+	switch cfg.Store {
+	case StoreMemory:
+		SetStore(NewMemoryStore(cfg.Expiration))
+	default:
+		SetStore(NewRedisStore(cfg.Expiration))
+	}
+}
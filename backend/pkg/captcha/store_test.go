@@ -0,0 +1,118 @@
+package captcha
+
+import (
+	"testing"
+	"time"
+	
+	"usercenter/internal/cache"
+	
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// setupRedis 使用miniredis替换全局Redis客户端，测试结束后恢复
+func setupRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	prev := cache.RDB
+	cache.RDB = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		cache.RDB.Close()
+		cache.RDB = prev
+	})
+	return mr
+}
+
+func TestRedisStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		advance time.Duration
+		answers []string
+		want    []bool
+	}{
+		{name: "正确答案只能使用一次", answers: []string{"abcd", "abcd"}, want: []bool{true, false}},
+		{name: "错误答案同样作废验证码", answers: []string{"wrong", "abcd"}, want: []bool{false, false}},
+		{name: "过期前有效", advance: 59 * time.Second, answers: []string{"abcd"}, want: []bool{true}},
+		{name: "过期后无效", advance: time.Minute, answers: []string{"abcd"}, want: []bool{false}},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := setupRedis(t)
+			s := NewRedisStore(time.Minute)
+			if err := s.Set("id", "abcd"); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			mr.FastForward(tt.advance)
+			
+			for i, answer := range tt.answers {
+				if got := s.Verify("id", answer, true); got != tt.want[i] {
+					t.Errorf("Verify(%q) #%d = %v, want %v", answer, i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRedisStoreGetWithoutClear(t *testing.T) {
+	setupRedis(t)
+	s := NewRedisStore(time.Minute)
+	s.Set("id", "abcd")
+	
+	if got := s.Get("id", false); got != "abcd" {
+		t.Fatalf("Get(clear=false) = %q, want %q", got, "abcd")
+	}
+	if got := s.Get("id", true); got != "abcd" {
+		t.Fatalf("Get(clear=true) = %q, want %q", got, "abcd")
+	}
+	if got := s.Get("id", false); got != "" {
+		t.Fatalf("Get() after clear = %q, want empty", got)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		advance time.Duration
+		answers []string
+		want    []bool
+	}{
+		{name: "正确答案只能使用一次", answers: []string{"abcd", "abcd"}, want: []bool{true, false}},
+		{name: "错误答案同样作废验证码", answers: []string{"wrong", "abcd"}, want: []bool{false, false}},
+		{name: "过期前有效", advance: 59 * time.Second, answers: []string{"abcd"}, want: []bool{true}},
+		{name: "过期后无效", advance: time.Minute, answers: []string{"abcd"}, want: []bool{false}},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			s := NewMemoryStore(time.Minute)
+			s.now = func() time.Time { return now }
+			s.Set("id", "abcd")
+			now = now.Add(tt.advance)
+			
+			for i, answer := range tt.answers {
+				if got := s.Verify("id", answer, true); got != tt.want[i] {
+					t.Errorf("Verify(%q) #%d = %v, want %v", answer, i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreSweepsExpired(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryStore(time.Minute)
+	s.now = func() time.Time { return now }
+	s.Set("old", "abcd")
+	
+	now = now.Add(2 * time.Minute)
+	s.Set("new", "efgh")
+	
+	if _, ok := s.items["old"]; ok {
+		t.Errorf("expired captcha was not swept")
+	}
+	if got := s.Get("new", false); got != "efgh" {
+		t.Errorf("Get(new) = %q, want %q", got, "efgh")
+	}
+}
@@ -0,0 +1,65 @@
+package crypto
+
+import (
+	"bufio"
+	_ "embed"
+	"os"
+	"strings"
+	"sync"
+)
+
+//go:embed common_passwords.txt
+var bundledCommonPasswords string
+
+var (
+	commonPasswords     map[string]struct{}
+	commonPasswordsOnce sync.Once
+	commonPasswordsMu   sync.RWMutex
+)
+
+// IsCommonPassword 检查密码是否在常见弱密码列表中（不区分大小写）
+func IsCommonPassword(password string) bool {
+	commonPasswordsOnce.Do(loadBundledCommonPasswords)
+	
+	commonPasswordsMu.RLock()
+	defer commonPasswordsMu.RUnlock()
+	
+	_, ok := commonPasswords[strings.ToLower(password)]
+	return ok
+}
+
+// LoadCommonPasswords 从文件追加常见弱密码（每行一个，#开头为注释）
+func LoadCommonPasswords(path string) error {
+	commonPasswordsOnce.Do(loadBundledCommonPasswords)
+	
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	commonPasswordsMu.Lock()
+	defer commonPasswordsMu.Unlock()
+	
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		addCommonPassword(scanner.Text())
+	}
+	
+	return scanner.Err()
+}
+
+func loadBundledCommonPasswords() {
+	commonPasswords = make(map[string]struct{})
+	for _, line := range strings.Split(bundledCommonPasswords, "\n") {
+		addCommonPassword(line)
+	}
+}
+
+func addCommonPassword(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	commonPasswords[strings.ToLower(line)] = struct{}{}
+}
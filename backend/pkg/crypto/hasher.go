@@ -0,0 +1,164 @@
+package crypto
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher 密码哈希算法，按哈希字符串的前缀识别格式
+type Hasher interface {
+	// Name 算法名称
+	Name() string
+	// Match 是否能识别该哈希字符串
+	Match(encodedHash string) bool
+	// Verify 校验密码
+	Verify(password, encodedHash string) (bool, error)
+	// NeedsRehash 该哈希是否需要升级为当前算法和参数
+	NeedsRehash(encodedHash string) bool
+}
+
+// ErrUnknownHashFormat 无法识别的密码哈希格式
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// hashers 已注册的哈希算法，按注册顺序匹配
+var hashers = []Hasher{
+	argon2Hasher{},
+	bcryptHasher{},
+	saltedMD5Hasher{},
+}
+
+// RegisterHasher 注册额外的密码哈希算法（用于导入其他系统的密码）
+func RegisterHasher(h Hasher) {
+	hashers = append(hashers, h)
+}
+
+// findHasher 按前缀查找能识别该哈希的算法
+func findHasher(encodedHash string) (Hasher, error) {
+	for _, h := range hashers {
+		if h.Match(encodedHash) {
+			return h, nil
+		}
+	}
+	return nil, ErrUnknownHashFormat
+}
+
+// IsSupportedHash 是否为可识别的密码哈希格式
+func IsSupportedHash(encodedHash string) bool {
+	_, err := findHasher(encodedHash)
+	return err == nil
+}
+
+// NeedsRehash 哈希是否需要在下次登录成功时升级为当前的argon2id参数
+func NeedsRehash(encodedHash string) bool {
+	h, err := findHasher(encodedHash)
+	if err != nil {
+		return false
+	}
+	return h.NeedsRehash(encodedHash)
+}
+
+// argon2Hasher 当前使用的argon2id算法
+type argon2Hasher struct{}
+
+func (argon2Hasher) Name() string {
+	return "argon2id"
+}
+
+func (argon2Hasher) Match(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$argon2id$")
+}
+
+func (argon2Hasher) Verify(password, encodedHash string) (bool, error) {
+	config, salt, hash, err := decodeHash(encodedHash)
+	if err != nil {
+		return false, err
+	}
+	
+	otherHash := argon2.IDKey([]byte(password), salt, config.Time, config.Memory, config.Threads, config.KeyLen)
+	
+	return subtle.ConstantTimeCompare(hash, otherHash) == 1, nil
+}
+
+func (argon2Hasher) NeedsRehash(encodedHash string) bool {
+	config, _, _, err := decodeHash(encodedHash)
+	if err != nil {
+		return true
+	}
+	return config.Time != DefaultConfig.Time ||
+		config.Memory != DefaultConfig.Memory ||
+		config.Threads != DefaultConfig.Threads ||
+		config.KeyLen != DefaultConfig.KeyLen
+}
+
+// bcryptHasher 旧系统的bcrypt哈希（$2a$、$2b$、$2y$）
+type bcryptHasher struct{}
+
+func (bcryptHasher) Name() string {
+	return "bcrypt"
+}
+
+func (bcryptHasher) Match(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$2a$") ||
+		strings.HasPrefix(encodedHash, "$2b$") ||
+		strings.HasPrefix(encodedHash, "$2y$")
+}
+
+func (bcryptHasher) Verify(password, encodedHash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (bcryptHasher) NeedsRehash(encodedHash string) bool {
+	return true
+}
+
+// saltedMD5Hasher 旧系统的加盐MD5哈希，格式为 $md5$<salt>$<hex(md5(salt+password))>
+type saltedMD5Hasher struct{}
+
+func (saltedMD5Hasher) Name() string {
+	return "salted-md5"
+}
+
+func (saltedMD5Hasher) Match(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$md5$")
+}
+
+func (saltedMD5Hasher) Verify(password, encodedHash string) (bool, error) {
+	vals := strings.Split(encodedHash, "$")
+	if len(vals) != 4 {
+		return false, fmt.Errorf("invalid hash format")
+	}
+	
+	expected, err := hex.DecodeString(strings.ToLower(vals[3]))
+	if err != nil {
+		return false, err
+	}
+	
+	sum := md5.Sum([]byte(vals[2] + password))
+	return subtle.ConstantTimeCompare(expected, sum[:]) == 1, nil
+}
+
+func (saltedMD5Hasher) NeedsRehash(encodedHash string) bool {
+	return true
+}
+
+// encodeArgon2Hash 按PHC格式编码argon2id哈希
+func encodeArgon2Hash(c *Config, salt, hash []byte) string {
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, c.Memory, c.Time, c.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash))
+}
@@ -0,0 +1,162 @@
+package crypto
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strings"
+	"testing"
+	
+	"golang.org/x/crypto/bcrypt"
+)
+
+// useConfig 使用较小的argon2id参数加快测试，测试结束后恢复
+func useConfig(t *testing.T, c *Config) {
+	t.Helper()
+	prev := DefaultConfig
+	DefaultConfig = c
+	t.Cleanup(func() { DefaultConfig = prev })
+}
+
+var testConfig = &Config{Time: 1, Memory: 1024, Threads: 1, KeyLen: 32}
+
+func bcryptHash(t *testing.T, password, prefix string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prefix + string(hash)[len("$2a$"):]
+}
+
+func md5Hash(salt, password string) string {
+	sum := md5.Sum([]byte(salt + password))
+	return "$md5$" + salt + "$" + hex.EncodeToString(sum[:])
+}
+
+func TestVerifyPassword(t *testing.T) {
+	useConfig(t, testConfig)
+	argon2Hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+		wantErr  bool
+	}{
+		{name: "argon2id正确", hash: argon2Hash, password: "secret", want: true},
+		{name: "argon2id错误", hash: argon2Hash, password: "Secret", want: false},
+		{name: "bcrypt $2a$", hash: bcryptHash(t, "secret", "$2a$"), password: "secret", want: true},
+		{name: "bcrypt $2b$", hash: bcryptHash(t, "secret", "$2b$"), password: "secret", want: true},
+		{name: "bcrypt $2y$", hash: bcryptHash(t, "secret", "$2y$"), password: "secret", want: true},
+		{name: "bcrypt错误", hash: bcryptHash(t, "secret", "$2a$"), password: "wrong", want: false},
+		{name: "加盐MD5正确", hash: md5Hash("salt", "secret"), password: "secret", want: true},
+		{name: "加盐MD5大写摘要", hash: "$md5$salt$" + strings.ToUpper(md5Hash("salt", "secret")[len("$md5$salt$"):]), password: "secret", want: true},
+		{name: "加盐MD5错误", hash: md5Hash("salt", "secret"), password: "wrong", want: false},
+		{name: "加盐MD5格式错误", hash: "$md5$salt", password: "secret", wantErr: true},
+		{name: "未知格式", hash: "plaintext", password: "plaintext", wantErr: true},
+		{name: "空哈希", hash: "", password: "", wantErr: true},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyPassword(tt.password, tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	useConfig(t, testConfig)
+	current, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useConfig(t, &Config{Time: 2, Memory: 1024, Threads: 1, KeyLen: 32})
+	weaker, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useConfig(t, testConfig)
+	
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{name: "当前参数的argon2id", hash: current, want: false},
+		{name: "参数不同的argon2id", hash: weaker, want: true},
+		{name: "无法解析的argon2id", hash: "$argon2id$broken", want: true},
+		{name: "bcrypt", hash: bcryptHash(t, "secret", "$2b$"), want: true},
+		{name: "加盐MD5", hash: md5Hash("salt", "secret"), want: true},
+		{name: "未知格式", hash: "plaintext", want: false},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash(%q) = %v, want %v", tt.hash, got, tt.want)
+			}
+		})
+	}
+}
+
+// plainHasher 测试用的明文“哈希”，格式为 $plain$<password>
+type plainHasher struct{}
+
+func (plainHasher) Name() string { return "plain" }
+
+func (plainHasher) Match(encodedHash string) bool { return strings.HasPrefix(encodedHash, "$plain$") }
+
+func (plainHasher) Verify(password, encodedHash string) (bool, error) {
+	return encodedHash == "$plain$"+password, nil
+}
+
+func (plainHasher) NeedsRehash(string) bool { return true }
+
+func TestRegisterHasher(t *testing.T) {
+	prev := hashers
+	t.Cleanup(func() { hashers = prev })
+	
+	if IsSupportedHash("$plain$secret") {
+		t.Fatal("IsSupportedHash() = true before RegisterHasher")
+	}
+	RegisterHasher(plainHasher{})
+	
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+	}{
+		{name: "注册的算法正确", hash: "$plain$secret", password: "secret", want: true},
+		{name: "注册的算法错误", hash: "$plain$secret", password: "wrong", want: false},
+		{name: "内置算法仍然可用", hash: md5Hash("salt", "secret"), password: "secret", want: true},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !IsSupportedHash(tt.hash) {
+				t.Fatalf("IsSupportedHash(%q) = false", tt.hash)
+			}
+			got, err := VerifyPassword(tt.password, tt.hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if !NeedsRehash("$plain$secret") {
+		t.Error("NeedsRehash() = false for registered hasher")
+	}
+}
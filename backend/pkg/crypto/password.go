@@ -2,9 +2,11 @@ package crypto
 
 import (
 	"crypto/rand"
-	"crypto/subtle"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"math/big"
 	"strings"
 	
 	"golang.org/x/crypto/argon2"
@@ -24,6 +26,14 @@ var DefaultConfig = &Config{
 	KeyLen:  32,
 }
 
+// SetDefaultConfig 设置新密码使用的argon2id参数，参数不同的已有哈希会在登录时升级
+func SetDefaultConfig(c *Config) {
+	if c.Time == 0 || c.Memory == 0 || c.Threads == 0 || c.KeyLen == 0 {
+		return
+	}
+	DefaultConfig = c
+}
+
 // HashPassword 加密密码
 func HashPassword(password string) (string, error) {
 	salt, err := generateRandomBytes(16)
@@ -33,28 +43,16 @@ func HashPassword(password string) (string, error) {
 	
 	hash := argon2.IDKey([]byte(password), salt, DefaultConfig.Time, DefaultConfig.Memory, DefaultConfig.Threads, DefaultConfig.KeyLen)
 	
-	b64Salt := base64.RawStdEncoding.EncodeToString(salt)
-	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
-	
-	encodedHash := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", 
-		argon2.Version, DefaultConfig.Memory, DefaultConfig.Time, DefaultConfig.Threads, b64Salt, b64Hash)
-	
-	return encodedHash, nil
+	return encodeArgon2Hash(DefaultConfig, salt, hash), nil
 }
 
-// VerifyPassword 验证密码
+// VerifyPassword 验证密码，按哈希前缀选择对应的算法（argon2id、bcrypt、加盐MD5等）
 func VerifyPassword(password, encodedHash string) (bool, error) {
-	config, salt, hash, err := decodeHash(encodedHash)
+	h, err := findHasher(encodedHash)
 	if err != nil {
 		return false, err
 	}
-	
-	otherHash := argon2.IDKey([]byte(password), salt, config.Time, config.Memory, config.Threads, config.KeyLen)
-	
-	if subtle.ConstantTimeCompare(hash, otherHash) == 1 {
-		return true, nil
-	}
-	return false, nil
+	return h.Verify(password, encodedHash)
 }
 
 // generateRandomBytes 生成随机字节
@@ -112,3 +110,61 @@ func GenerateRandomString(length int) (string, error) {
 	}
 	return base64.URLEncoding.EncodeToString(bytes)[:length], nil
 }
+
+// GenerateRandomHex 生成指定字节数的随机十六进制字符串
+func GenerateRandomHex(n int) (string, error) {
+	bytes, err := generateRandomBytes(uint32(n))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// SHA256Hex 计算字符串的SHA-256十六进制摘要，用于保存一次性令牌
+func SHA256Hex(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// 临时密码字符集（去除了容易混淆的字符）
+const (
+	tempPasswordUpper  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	tempPasswordLower  = "abcdefghijkmnopqrstuvwxyz"
+	tempPasswordDigit  = "23456789"
+	tempPasswordSymbol = "!@#$%^&*-_=+?"
+)
+
+// GenerateTemporaryPassword 生成包含大小写字母、数字和符号的随机临时密码
+func GenerateTemporaryPassword(length int) (string, error) {
+	classes := []string{tempPasswordUpper, tempPasswordLower, tempPasswordDigit, tempPasswordSymbol}
+	if length < len(classes) {
+		length = len(classes)
+	}
+	all := strings.Join(classes, "")
+	
+	password := make([]byte, 0, length)
+	for i := 0; i < length; i++ {
+		charset := all
+		// 保证每类字符至少出现一次
+		if i < len(classes) {
+			charset = classes[i]
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return "", err
+		}
+		password = append(password, charset[n.Int64()])
+	}
+	
+	// 打乱顺序，避免固定位置出现固定类型的字符
+	for i := len(password) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		j := n.Int64()
+		password[i], password[j] = password[j], password[i]
+	}
+	
+	return string(password), nil
+}
@@ -1,199 +1,161 @@
 package email
 
 import (
-	"fmt"
-	"strconv"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
 	
 	"usercenter/internal/config"
-	
-	"gopkg.in/gomail.v2"
+	"usercenter/pkg/outbound"
+	"usercenter/pkg/templates"
 )
 
 type EmailService struct {
-	config *config.SMTPConfig
+	config    *config.SMTPConfig
+	transport Transport
 }
 
 type EmailMessage struct {
+	From    string // 为空时使用配置的发件人
+	ReplyTo string // 为空时使用配置的回复地址
 	To      []string
 	Subject string
 	Body    string
+	Text    string // IsHTML时作为HTML正文的纯文本替代内容
 	IsHTML  bool
+	Date    time.Time
+	
+	// 通过发送队列发送时，超过该时间仍未发出则放弃。包含验证码、重置链接等有时效凭据的邮件必须设置，
+	// 发送队列据此在消息进入死信时清除内容
+	ExpiresAt time.Time
+}
+
+// ErrInvalidAddress 邮箱地址格式不正确
+var ErrInvalidAddress = errors.New("邮箱格式不正确")
+
+// NormalizeAddress 校验邮箱地址并转换为小写，账号和验证码都按转换后的地址保存和查找。
+// 只接受纯地址，"张三 <a@example.com>"这类带显示名或尖括号的形式视为格式不正确
+func NormalizeAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	addr, err := mail.ParseAddress(address)
+	if err != nil || addr.Name != "" || addr.Address != address {
+		return "", ErrInvalidAddress
+	}
+	return strings.ToLower(addr.Address), nil
 }
 
+// NewEmailService 使用Init创建的共用发送方式，未初始化时单独创建SMTP发送方式
 func NewEmailService(cfg *config.SMTPConfig) *EmailService {
-	return &EmailService{config: cfg}
+	transport := defaultTransport
+	if transport == nil {
+		transport = NewSMTPTransport(cfg)
+	}
+	return &EmailService{config: cfg, transport: transport}
 }
 
-// SendEmail 发送邮件
+// SendEmail 发送邮件，启用发送队列时加入队列后立即返回，发送失败由队列重试
 func (s *EmailService) SendEmail(msg *EmailMessage) error {
-	m := gomail.NewMessage()
-	m.SetHeader("From", s.config.Username)
-	m.SetHeader("To", msg.To...)
-	m.SetHeader("Subject", msg.Subject)
-	
-	if msg.IsHTML {
-		m.SetBody("text/html", msg.Body)
-	} else {
-		m.SetBody("text/plain", msg.Body)
+	if msg.From == "" {
+		msg.From = s.sender()
+	}
+	if msg.ReplyTo == "" {
+		msg.ReplyTo = s.config.ReplyTo
+	}
+	if msg.Date.IsZero() {
+		msg.Date = time.Now()
 	}
 	
-	d := gomail.NewDialer(s.config.Host, s.config.Port, s.config.Username, s.config.Password)
-	
-	return d.DialAndSend(m)
+	if outbound.Enabled() {
+		return outbound.Enqueue(outbound.ChannelEmail, strings.Join(msg.To, ","), msg.Subject, msg, msg.ExpiresAt)
+	}
+	return s.transport.Send(msg)
 }
 
-// SendVerificationCode 发送验证码邮件
-func (s *EmailService) SendVerificationCode(email, code, purpose string) error {
-	subject := getEmailSubject(purpose)
-	body := getEmailBody(code, purpose)
-	
-	msg := &EmailMessage{
-		To:      []string{email},
-		Subject: subject,
-		Body:    body,
-		IsHTML:  true,
+// sender 配置的发件人，未配置发件地址时使用SMTP用户名
+func (s *EmailService) sender() string {
+	address := s.config.FromAddress
+	if address == "" {
+		address = s.config.Username
 	}
-	
-	return s.SendEmail(msg)
+	if address == "" || s.config.FromName == "" {
+		return address
+	}
+	return (&mail.Address{Name: s.config.FromName, Address: address}).String()
 }
 
-// SendWelcomeEmail 发送欢迎邮件
-func (s *EmailService) SendWelcomeEmail(email, username string) error {
-	subject := "欢迎注册用户中心"
-	body := fmt.Sprintf(`
-		<html>
-		<body>
-			<h2>欢迎注册用户中心</h2>
-			<p>亲爱的 %s，</p>
-			<p>欢迎您注册我们的用户中心！您的账号已成功创建。</p>
-			<p>现在您可以登录并开始使用我们的服务。</p>
-			<p>如果您有任何问题，请随时联系我们的客服团队。</p>
-			<br>
-			<p>祝好！</p>
-			<p>用户中心团队</p>
-		</body>
-		</html>
-	`, username)
-	
-	msg := &EmailMessage{
-		To:      []string{email},
-		Subject: subject,
-		Body:    body,
-		IsHTML:  true,
-	}
-	
-	return s.SendEmail(msg)
+// SendTemplate 渲染模板并发送邮件，locale为收件人的语言，没有对应语言的模板时使用默认语言
+func (s *EmailService) SendTemplate(to, name, locale string, data map[string]interface{}) error {
+	return s.SendExpiringTemplate(to, name, locale, data, 0)
 }
 
-// SendPasswordResetEmail 发送密码重置邮件
-func (s *EmailService) SendPasswordResetEmail(email, resetLink string) error {
-	subject := "密码重置请求"
-	body := fmt.Sprintf(`
-		<html>
-		<body>
-			<h2>密码重置请求</h2>
-			<p>您好，</p>
-			<p>我们收到了您的密码重置请求。请点击下面的链接来重置您的密码：</p>
-			<p><a href="%s" style="background-color: #007bff; color: white; padding: 10px 20px; text-decoration: none; border-radius: 5px;">重置密码</a></p>
-			<p>如果您无法点击上面的按钮，请复制以下链接到浏览器地址栏：</p>
-			<p>%s</p>
-			<p>此链接将在30分钟后失效。</p>
-			<p>如果您没有请求密码重置，请忽略此邮件。</p>
-			<br>
-			<p>祝好！</p>
-			<p>用户中心团队</p>
-		</body>
-		</html>
-	`, resetLink, resetLink)
-	
-	msg := &EmailMessage{
-		To:      []string{email},
-		Subject: subject,
-		Body:    body,
-		IsHTML:  true,
+// SendExpiringTemplate 发送包含有时效的验证码或链接的模板邮件，ttl为凭据的有效期，过期后不再重试发送
+func (s *EmailService) SendExpiringTemplate(to, name, locale string, data map[string]interface{}, ttl time.Duration) error {
+	msg, err := renderTemplate(to, name, locale, data)
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		msg.ExpiresAt = time.Now().Add(ttl)
 	}
-	
 	return s.SendEmail(msg)
 }
 
-// SendNotificationEmail 发送通知邮件
-func (s *EmailService) SendNotificationEmail(email, title, content string) error {
-	body := fmt.Sprintf(`
-		<html>
-		<body>
-			<h2>%s</h2>
-			<div>%s</div>
-			<br>
-			<p>祝好！</p>
-			<p>用户中心团队</p>
-		</body>
-		</html>
-	`, title, content)
-	
-	msg := &EmailMessage{
-		To:      []string{email},
-		Subject: title,
-		Body:    body,
-		IsHTML:  true,
-	}
-	
-	return s.SendEmail(msg)
+// SendVerificationCode 发送验证码邮件，action为展示给用户的操作名称，验证码过期后不再重试发送
+func (s *EmailService) SendVerificationCode(email, code, action string, ttl time.Duration, locale string) error {
+	return s.SendExpiringTemplate(email, "verification_code", locale, map[string]interface{}{
+		"code":    code,
+		"action":  action,
+		"minutes": int(ttl.Minutes()),
+	}, ttl)
 }
 
-func getEmailSubject(purpose string) string {
-	switch purpose {
-	case "register":
-		return "用户注册验证码"
-	case "reset_password":
-		return "密码重置验证码"
-	case "login":
-		return "登录验证码"
-	case "bind_email":
-		return "邮箱绑定验证码"
-	default:
-		return "验证码"
-	}
+// SendWelcomeEmail 发送欢迎邮件
+func (s *EmailService) SendWelcomeEmail(email, username, locale string) error {
+	return s.SendTemplate(email, "welcome", locale, map[string]interface{}{
+		"username": username,
+	})
 }
 
-func getEmailBody(code, purpose string) string {
-	action := getActionText(purpose)
-	
-	return fmt.Sprintf(`
-		<html>
-		<body style="font-family: Arial, sans-serif; line-height: 1.6; color: #333;">
-			<div style="max-width: 600px; margin: 0 auto; padding: 20px;">
-				<h2 style="color: #007bff; text-align: center;">验证码</h2>
-				<p>您好，</p>
-				<p>您正在进行%s操作，验证码为：</p>
-				<div style="text-align: center; margin: 30px 0;">
-					<span style="font-size: 36px; font-weight: bold; color: #007bff; letter-spacing: 5px; border: 2px dashed #007bff; padding: 15px 25px; display: inline-block;">%s</span>
-				</div>
-				<p style="color: #666;">验证码有效期为15分钟，请及时使用。</p>
-				<p style="color: #666;">如果这不是您本人操作，请忽略此邮件。</p>
-				<br>
-				<p>祝好！</p>
-				<p>用户中心团队</p>
-				<hr style="border: none; border-top: 1px solid #eee; margin: 30px 0;">
-				<p style="font-size: 12px; color: #999; text-align: center;">
-					此邮件由系统自动发送，请勿直接回复。
-				</p>
-			</div>
-		</body>
-		</html>
-	`, action, code)
+// SendPasswordResetEmail 发送密码重置邮件，重置链接过期后不再重试发送
+func (s *EmailService) SendPasswordResetEmail(email, resetLink string, ttl time.Duration, locale string) error {
+	return s.SendExpiringTemplate(email, "password_reset", locale, map[string]interface{}{
+		"link":    resetLink,
+		"minutes": int(ttl.Minutes()),
+	}, ttl)
+}
+
+// SendPasswordExpiryReminder 发送密码即将过期提醒邮件
+func (s *EmailService) SendPasswordExpiryReminder(email, username string, expiresAt time.Time, locale string) error {
+	return s.SendTemplate(email, "password_expiry", locale, map[string]interface{}{
+		"username":   username,
+		"expires_at": expiresAt.Format("2006-01-02 15:04"),
+	})
+}
+
+// SendNotificationEmail 发送通知邮件，content为纯文本
+func (s *EmailService) SendNotificationEmail(email, title, content, locale string) error {
+	return s.SendTemplate(email, "notification", locale, map[string]interface{}{
+		"title":   title,
+		"content": content,
+	})
 }
 
-func getActionText(purpose string) string {
-	switch purpose {
-	case "register":
-		return "用户注册"
-	case "reset_password":
-		return "密码重置"
-	case "login":
-		return "登录验证"
-	case "bind_email":
-		return "邮箱绑定"
-	default:
-		return "身份验证"
+func renderTemplate(to, name, locale string, data map[string]interface{}) (*EmailMessage, error) {
+	content, err := templates.RenderEmail(name, locale, data)
+	if err != nil {
+		return nil, err
+	}
+	
+	msg := &EmailMessage{
+		To:      []string{to},
+		Subject: content.Subject,
+		Body:    content.Text,
+	}
+	if content.HTML != "" {
+		msg.Body = content.HTML
+		msg.Text = content.Text
+		msg.IsHTML = true
 	}
+	return msg, nil
 }
@@ -0,0 +1,36 @@
+package email
+
+import "testing"
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{name: "纯地址", raw: "user@example.com", want: "user@example.com"},
+		{name: "大写转换为小写", raw: "User.Name@Example.COM", want: "user.name@example.com"},
+		{name: "去除首尾空格", raw: " user@example.com ", want: "user@example.com"},
+		{name: "带显示名", raw: "张三 <user@example.com>", wantErr: true},
+		{name: "带尖括号", raw: "<user@example.com>", wantErr: true},
+		{name: "带注释", raw: "user@example.com (张三)", wantErr: true},
+		{name: "缺少域名", raw: "user@", wantErr: true},
+		{name: "多个地址", raw: "a@example.com, b@example.com", wantErr: true},
+		{name: "空地址", raw: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeAddress(tt.raw)
+			if tt.wantErr {
+				if err != ErrInvalidAddress {
+					t.Fatalf("NormalizeAddress(%q) = %q, %v; want ErrInvalidAddress", tt.raw, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("NormalizeAddress(%q) = %q, %v; want %q", tt.raw, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package email
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	
+	"github.com/google/uuid"
+)
+
+// MaildirTransport 把邮件按Maildir格式保存到本地目录而不真正发送，仅用于开发环境，
+// 可以用邮件客户端（如mutt -f）直接打开目录查看
+type MaildirTransport struct {
+	dir string
+}
+
+func NewMaildirTransport(dir string) (*MaildirTransport, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, err
+		}
+	}
+	return &MaildirTransport{dir: dir}, nil
+}
+
+// Send 先写入tmp再移动到new，邮件客户端不会读到写了一半的邮件
+func (t *MaildirTransport) Send(msg *EmailMessage) error {
+	name := fmt.Sprintf("%d.%s.usercenter", time.Now().Unix(), uuid.New().String())
+	tmpPath := filepath.Join(t.dir, "tmp", name)
+	
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := writeMIME(file, msg); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	
+	return os.Rename(tmpPath, filepath.Join(t.dir, "new", name))
+}
@@ -0,0 +1,63 @@
+package email
+
+import (
+	"strings"
+	"sync"
+)
+
+// MemoryTransport 把邮件保存在内存中，供集成测试读取验证码、重置链接等内容
+type MemoryTransport struct {
+	mu       sync.Mutex
+	messages []EmailMessage
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{}
+}
+
+func (t *MemoryTransport) Send(msg *EmailMessage) error {
+	sent := *msg
+	sent.To = append([]string(nil), msg.To...)
+	
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = append(t.messages, sent)
+	return nil
+}
+
+// Messages 返回所有已发送的邮件，按发送顺序排列
+func (t *MemoryTransport) Messages() []EmailMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]EmailMessage(nil), t.messages...)
+}
+
+// MessagesTo 返回发给指定收件人的邮件，收件人不区分大小写
+func (t *MemoryTransport) MessagesTo(address string) []EmailMessage {
+	var result []EmailMessage
+	for _, msg := range t.Messages() {
+		for _, to := range msg.To {
+			if strings.EqualFold(to, address) {
+				result = append(result, msg)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Last 返回发给指定收件人的最后一封邮件
+func (t *MemoryTransport) Last(address string) (EmailMessage, bool) {
+	messages := t.MessagesTo(address)
+	if len(messages) == 0 {
+		return EmailMessage{}, false
+	}
+	return messages[len(messages)-1], true
+}
+
+// Reset 清空收件箱
+func (t *MemoryTransport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = nil
+}
@@ -0,0 +1,130 @@
+package email
+
+import (
+	"strings"
+	"testing"
+	"time"
+	
+	"usercenter/internal/config"
+)
+
+// setupMailbox 使用内存方式初始化邮件服务，测试结束后恢复
+func setupMailbox(t *testing.T) (*EmailService, *MemoryTransport) {
+	t.Helper()
+	prev := defaultTransport
+	t.Cleanup(func() { defaultTransport = prev })
+	
+	cfg := &config.SMTPConfig{
+		Transport:   TransportMemory,
+		FromName:    "User Center",
+		FromAddress: "noreply@example.com",
+		ReplyTo:     "support@example.com",
+	}
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	mailbox := Mailbox()
+	if mailbox == nil {
+		t.Fatal("Mailbox() = nil with memory transport")
+	}
+	return NewEmailService(cfg), mailbox
+}
+
+func TestMemoryTransportVerificationCode(t *testing.T) {
+	service, mailbox := setupMailbox(t)
+	
+	if err := service.SendVerificationCode("Alice@Example.com", "654321", "register", 10*time.Minute, "en"); err != nil {
+		t.Fatal(err)
+	}
+	
+	msg, ok := mailbox.Last("alice@example.com")
+	if !ok {
+		t.Fatal("Last() found no message")
+	}
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "From", got: msg.From, want: `"User Center" <noreply@example.com>`},
+		{name: "ReplyTo", got: msg.ReplyTo, want: "support@example.com"},
+		{name: "Subject", got: msg.Subject, want: "Your verification code"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+	if !msg.IsHTML || !strings.Contains(msg.Body, "654321") || !strings.Contains(msg.Text, "654321") {
+		t.Errorf("message does not contain the code in both parts: %+v", msg)
+	}
+	if msg.ExpiresAt.IsZero() || msg.Date.IsZero() {
+		t.Errorf("ExpiresAt = %v, Date = %v, want both set", msg.ExpiresAt, msg.Date)
+	}
+}
+
+func TestMemoryTransportMailbox(t *testing.T) {
+	service, mailbox := setupMailbox(t)
+	
+	sends := []struct {
+		to    string
+		title string
+	}{
+		{to: "alice@example.com", title: "first"},
+		{to: "bob@example.com", title: "second"},
+		{to: "ALICE@example.com", title: "third"},
+	}
+	for _, s := range sends {
+		if err := service.SendNotificationEmail(s.to, s.title, "content", "en"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	
+	tests := []struct {
+		address   string
+		wantCount int
+		wantLast  string
+	}{
+		{address: "alice@example.com", wantCount: 2, wantLast: "third"},
+		{address: "Bob@Example.com", wantCount: 1, wantLast: "second"},
+		{address: "carol@example.com", wantCount: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := len(mailbox.MessagesTo(tt.address)); got != tt.wantCount {
+				t.Errorf("MessagesTo() returned %d messages, want %d", got, tt.wantCount)
+			}
+			msg, ok := mailbox.Last(tt.address)
+			if ok != (tt.wantCount > 0) {
+				t.Fatalf("Last() ok = %v", ok)
+			}
+			if ok && msg.Subject != tt.wantLast {
+				t.Errorf("Last().Subject = %q, want %q", msg.Subject, tt.wantLast)
+			}
+		})
+	}
+	
+	if got := len(mailbox.Messages()); got != len(sends) {
+		t.Errorf("Messages() returned %d messages, want %d", got, len(sends))
+	}
+	mailbox.Reset()
+	if got := len(mailbox.Messages()); got != 0 {
+		t.Errorf("Messages() after Reset returned %d messages", got)
+	}
+}
+
+func TestMemoryTransportCopiesMessage(t *testing.T) {
+	mailbox := NewMemoryTransport()
+	msg := &EmailMessage{To: []string{"alice@example.com"}, Subject: "hello"}
+	mailbox.Send(msg)
+	
+	msg.To[0] = "mallory@example.com"
+	msg.Subject = "changed"
+	messages := mailbox.Messages()
+	messages[0].Subject = "changed again"
+	
+	got, ok := mailbox.Last("alice@example.com")
+	if !ok || got.Subject != "hello" {
+		t.Errorf("stored message was modified: %+v", got)
+	}
+}
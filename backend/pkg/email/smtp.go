@@ -0,0 +1,184 @@
+package email
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+	
+	"usercenter/internal/config"
+)
+
+// SMTP连接加密方式
+const (
+	EncryptionAuto     = "auto"     // 465端口使用TLS，其他端口服务器支持时使用STARTTLS
+	EncryptionSTARTTLS = "starttls" // 要求服务器支持STARTTLS
+	EncryptionTLS      = "tls"      // 连接建立时即使用TLS
+	EncryptionNone     = "none"     // 不加密，只用于本机或内网的邮件服务器
+)
+
+const smtpDialTimeout = 10 * time.Second
+
+// SMTPTransport 通过SMTP服务器发送邮件，发送完成的连接放回连接池供后续邮件复用
+type SMTPTransport struct {
+	config *config.SMTPConfig
+	idle   chan *smtpConn
+}
+
+type smtpConn struct {
+	client   *smtp.Client
+	lastUsed time.Time
+}
+
+func NewSMTPTransport(cfg *config.SMTPConfig) *SMTPTransport {
+	size := cfg.PoolSize
+	if size < 0 {
+		size = 0
+	}
+	return &SMTPTransport{
+		config: cfg,
+		idle:   make(chan *smtpConn, size),
+	}
+}
+
+// Send 使用空闲连接发送邮件，复用的连接可能已被服务器关闭，失败时换新连接重试一次
+func (t *SMTPTransport) Send(msg *EmailMessage) error {
+	conn, reused, err := t.get()
+	if err != nil {
+		return err
+	}
+	
+	if err := t.send(conn, msg); err != nil {
+		conn.client.Close()
+		if !reused {
+			return err
+		}
+		
+		if conn, err = t.dial(); err != nil {
+			return err
+		}
+		if err := t.send(conn, msg); err != nil {
+			conn.client.Close()
+			return err
+		}
+	}
+	
+	t.put(conn)
+	return nil
+}
+
+func (t *SMTPTransport) send(conn *smtpConn, msg *EmailMessage) error {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return err
+	}
+	
+	if err := conn.client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := conn.client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	
+	w, err := conn.client.Data()
+	if err != nil {
+		return err
+	}
+	if err := writeMIME(w, msg); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// get 取出未超过空闲时间的连接，没有可用连接时新建
+func (t *SMTPTransport) get() (*smtpConn, bool, error) {
+	for {
+		select {
+		case conn := <-t.idle:
+			if t.config.IdleTimeout > 0 && time.Since(conn.lastUsed) > t.config.IdleTimeout {
+				conn.client.Quit()
+				continue
+			}
+			return conn, true, nil
+		default:
+			conn, err := t.dial()
+			return conn, false, err
+		}
+	}
+}
+
+// put 放回连接，连接池已满时关闭
+func (t *SMTPTransport) put(conn *smtpConn) {
+	conn.lastUsed = time.Now()
+	select {
+	case t.idle <- conn:
+	default:
+		conn.client.Quit()
+	}
+}
+
+func (t *SMTPTransport) dial() (*smtpConn, error) {
+	host := t.config.Host
+	addr := net.JoinHostPort(host, strconv.Itoa(t.config.Port))
+	tlsConfig := &tls.Config{ServerName: host}
+	
+	encryption := t.config.Encryption
+	if encryption == "" {
+		encryption = EncryptionAuto
+	}
+	if encryption == EncryptionAuto && t.config.Port == 465 {
+		encryption = EncryptionTLS
+	}
+	
+	dialer := &net.Dialer{Timeout: smtpDialTimeout}
+	var conn net.Conn
+	var err error
+	switch encryption {
+	case EncryptionTLS:
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	case EncryptionAuto, EncryptionSTARTTLS, EncryptionNone:
+		conn, err = dialer.Dial("tcp", addr)
+	default:
+		return nil, errors.New("unknown smtp encryption " + encryption)
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	
+	if encryption == EncryptionAuto || encryption == EncryptionSTARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, err
+			}
+		} else if encryption == EncryptionSTARTTLS {
+			client.Close()
+			return nil, errors.New("smtp server does not support STARTTLS")
+		}
+	}
+	
+	if t.config.Username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			// PlainAuth只允许在加密连接或本机连接上发送密码
+			auth := smtp.PlainAuth("", t.config.Username, t.config.Password, host)
+			if err := client.Auth(auth); err != nil {
+				client.Close()
+				return nil, err
+			}
+		}
+	}
+	
+	return &smtpConn{client: client}, nil
+}
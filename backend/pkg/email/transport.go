@@ -0,0 +1,99 @@
+package email
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	
+	"usercenter/internal/config"
+	"usercenter/pkg/outbound"
+	
+	"gopkg.in/gomail.v2"
+)
+
+// 邮件发送方式
+const (
+	TransportSMTP    = "smtp"
+	TransportMaildir = "maildir"
+	TransportMemory  = "memory"
+)
+
+// Transport 邮件发送方式，收到的邮件已填好发件人
+type Transport interface {
+	Send(msg *EmailMessage) error
+}
+
+var defaultTransport Transport
+
+// NewTransport 按配置创建邮件发送方式
+func NewTransport(cfg *config.SMTPConfig) (Transport, error) {
+	switch cfg.Transport {
+	case "", TransportSMTP:
+		return NewSMTPTransport(cfg), nil
+	case TransportMaildir:
+		return NewMaildirTransport(cfg.MaildirPath)
+	case TransportMemory:
+		return NewMemoryTransport(), nil
+	default:
+		return nil, fmt.Errorf("unknown email transport %s", cfg.Transport)
+	}
+}
+
+// Init 按配置创建各服务共用的邮件发送方式，SMTP连接在服务间复用，并作为发送队列中邮件的发送方式
+func Init(cfg *config.SMTPConfig) error {
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return err
+	}
+	defaultTransport = transport
+	
+	// 队列中的邮件已填好发件人，直接交给发送方式
+	outbound.RegisterSender(outbound.ChannelEmail, func(payload []byte) error {
+		var msg EmailMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return err
+		}
+		return transport.Send(&msg)
+	})
+	return nil
+}
+
+// Default 返回共用的邮件发送方式，未初始化时返回nil
+func Default() Transport {
+	return defaultTransport
+}
+
+// Mailbox 使用内存方式时返回已发送邮件的收件箱，供集成测试检查邮件内容，其他方式返回nil
+func Mailbox() *MemoryTransport {
+	mailbox, _ := defaultTransport.(*MemoryTransport)
+	return mailbox
+}
+
+// writeMIME 生成邮件原文
+func writeMIME(w io.Writer, msg *EmailMessage) error {
+	if msg.From == "" {
+		return errors.New("email sender not configured")
+	}
+	
+	m := gomail.NewMessage()
+	m.SetHeader("From", msg.From)
+	m.SetHeader("To", msg.To...)
+	if msg.ReplyTo != "" {
+		m.SetHeader("Reply-To", msg.ReplyTo)
+	}
+	m.SetHeader("Subject", msg.Subject)
+	m.SetDateHeader("Date", msg.Date)
+	
+	if msg.IsHTML && msg.Text != "" {
+		m.SetBody("text/plain", msg.Text)
+		m.AddAlternative("text/html", msg.Body)
+	} else if msg.IsHTML {
+		m.SetBody("text/html", msg.Body)
+	} else {
+		m.SetBody("text/plain", msg.Body)
+	}
+	
+	_, err := m.WriteTo(w)
+	return err
+}
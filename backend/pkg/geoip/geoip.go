@@ -0,0 +1,140 @@
+package geoip
+
+import (
+	"errors"
+	"net"
+	"strings"
+	
+	"usercenter/internal/config"
+	"usercenter/internal/models"
+	
+	"github.com/oschwald/geoip2-golang"
+)
+
+// Resolver 基于本地MaxMind格式数据库（GeoLite2/GeoIP2 City、ASN）的IP地理位置查询
+type Resolver struct {
+	city     *geoip2.Reader
+	asn      *geoip2.Reader
+	language string
+}
+
+var defaultResolver *Resolver
+
+// Open 打开City和ASN数据库，任一路径为空时跳过对应数据库
+func Open(cityPath, asnPath, language string) (*Resolver, error) {
+	if cityPath == "" && asnPath == "" {
+		return nil, errors.New("未配置GeoIP数据库路径")
+	}
+	
+	r := &Resolver{language: language}
+	if cityPath != "" {
+		reader, err := geoip2.Open(cityPath)
+		if err != nil {
+			return nil, err
+		}
+		r.city = reader
+	}
+	if asnPath != "" {
+		reader, err := geoip2.Open(asnPath)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.asn = reader
+	}
+	
+	return r, nil
+}
+
+// Init 按配置打开数据库并设为默认查询
+func Init(cfg *config.GeoIPConfig) error {
+	resolver, err := Open(cfg.CityDatabase, cfg.ASNDatabase, cfg.Language)
+	if err != nil {
+		return err
+	}
+	defaultResolver = resolver
+	return nil
+}
+
+// Default 返回默认查询，未初始化时返回nil
+func Default() *Resolver {
+	return defaultResolver
+}
+
+// Lookup 使用默认数据库查询IP地理位置，未初始化或查询失败时返回空值
+func Lookup(ip string) models.GeoLocation {
+	if defaultResolver == nil {
+		return models.GeoLocation{}
+	}
+	return defaultResolver.Lookup(ip)
+}
+
+// Lookup 查询IP的国家、地区、城市和ASN
+func (r *Resolver) Lookup(ip string) models.GeoLocation {
+	var location models.GeoLocation
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return location
+	}
+	
+	if r.city != nil {
+		if record, err := r.city.City(parsed); err == nil {
+			location.Country = r.name(record.Country.Names)
+			location.CountryCode = record.Country.IsoCode
+			if len(record.Subdivisions) > 0 {
+				location.Region = r.name(record.Subdivisions[0].Names)
+			}
+			location.City = r.name(record.City.Names)
+		}
+	}
+	if r.asn != nil {
+		if record, err := r.asn.ASN(parsed); err == nil {
+			location.ASN = record.AutonomousSystemNumber
+			location.ASOrg = record.AutonomousSystemOrganization
+		}
+	}
+	
+	return location
+}
+
+// Locate 查询IP的经纬度，用于检测不可能的移动速度
+func (r *Resolver) Locate(ip string) (latitude, longitude float64, ok bool) {
+	parsed := net.ParseIP(ip)
+	if r.city == nil || parsed == nil {
+		return 0, 0, false
+	}
+	
+	record, err := r.city.City(parsed)
+	if err != nil || (record.Location.Latitude == 0 && record.Location.Longitude == 0) {
+		return 0, 0, false
+	}
+	return record.Location.Latitude, record.Location.Longitude, true
+}
+
+// Close 关闭数据库
+func (r *Resolver) Close() error {
+	var err error
+	if r.city != nil {
+		err = r.city.Close()
+	}
+	if r.asn != nil {
+		if closeErr := r.asn.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// name 按配置的语言取名称，没有对应语言时使用英文
+func (r *Resolver) name(names map[string]string) string {
+	if name, ok := names[r.language]; ok && name != "" {
+		return name
+	}
+	// zh-CN等带地区的语言回退到不带地区的名称
+	if idx := strings.Index(r.language, "-"); idx > 0 {
+		if name, ok := names[r.language[:idx]]; ok && name != "" {
+			return name
+		}
+	}
+	return names["en"]
+}
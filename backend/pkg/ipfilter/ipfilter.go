@@ -0,0 +1,182 @@
+package ipfilter
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	
+	"usercenter/internal/cache"
+	"usercenter/internal/database"
+	"usercenter/internal/models"
+	
+	"github.com/google/uuid"
+)
+
+// invalidateChannel 规则变更后通过Redis发布的失效通知频道，各实例收到后重新加载
+const invalidateChannel = "ip_rules:invalidate"
+
+type rule struct {
+	network *net.IPNet
+	action  string
+}
+
+// RuleSet 编译后的IP规则
+type RuleSet struct {
+	scopes map[string][]rule
+	users  map[uuid.UUID][]*net.IPNet
+}
+
+var (
+	mu      sync.RWMutex
+	current = Compile(nil)
+)
+
+// ParseCIDR 解析CIDR，单个IP视为/32（IPv6为/128）
+func ParseCIDR(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, errors.New("无效的IP或CIDR: " + value)
+		}
+		if v4 := ip.To4(); v4 != nil {
+			return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, errors.New("无效的IP或CIDR: " + value)
+	}
+	return network, nil
+}
+
+// Compile 编译规则，忽略停用和无法解析的规则
+func Compile(rules []models.IPRule) *RuleSet {
+	set := &RuleSet{
+		scopes: map[string][]rule{},
+		users:  map[uuid.UUID][]*net.IPNet{},
+	}
+	for _, r := range rules {
+		if r.Status != models.IPRuleStatusEnabled {
+			continue
+		}
+		network, err := ParseCIDR(r.CIDR)
+		if err != nil {
+			continue
+		}
+		if r.Scope == models.IPRuleScopeUser {
+			if r.UserID != nil && r.Action == models.IPRuleActionAllow {
+				set.users[*r.UserID] = append(set.users[*r.UserID], network)
+			}
+			continue
+		}
+		set.scopes[r.Scope] = append(set.scopes[r.Scope], rule{network: network, action: r.Action})
+	}
+	return set
+}
+
+// Allowed 判断IP能否访问指定范围：命中拒绝规则时拒绝；存在允许规则时必须命中其中之一
+func (s *RuleSet) Allowed(scope, ip string) bool {
+	rules := s.scopes[scope]
+	if len(rules) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	
+	hasAllow, allowed := false, false
+	for _, r := range rules {
+		if r.action == models.IPRuleActionDeny {
+			if r.network.Contains(parsed) {
+				return false
+			}
+			continue
+		}
+		hasAllow = true
+		if r.network.Contains(parsed) {
+			allowed = true
+		}
+	}
+	return !hasAllow || allowed
+}
+
+// UserAllowed 判断IP是否在用户的个人白名单中，未设置个人白名单的用户不受限制
+func (s *RuleSet) UserAllowed(userID uuid.UUID, ip string) bool {
+	networks := s.users[userID]
+	if len(networks) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed 使用当前缓存的规则判断IP能否访问指定范围
+func Allowed(scope, ip string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current.Allowed(scope, ip)
+}
+
+// UserAllowed 使用当前缓存的规则判断IP是否在用户的个人白名单中
+func UserAllowed(userID uuid.UUID, ip string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current.UserAllowed(userID, ip)
+}
+
+// Load 从数据库加载规则到内存
+func Load() error {
+	var rules []models.IPRule
+	if err := database.DB.Where("status = ?", models.IPRuleStatusEnabled).Find(&rules).Error; err != nil {
+		return err
+	}
+	
+	set := Compile(rules)
+	mu.Lock()
+	current = set
+	mu.Unlock()
+	return nil
+}
+
+// Invalidate 规则变更后重新加载本实例的规则，并通知其他实例重新加载
+func Invalidate() error {
+	if err := Load(); err != nil {
+		return err
+	}
+	return cache.Publish(invalidateChannel, time.Now().Unix())
+}
+
+// StartSync 订阅规则失效通知，收到通知后重新加载。
+// 订阅断开期间可能错过通知，因此同时按interval定期全量重新加载
+func StartSync(interval time.Duration) {
+	go func() {
+		pubsub := cache.Subscribe(invalidateChannel)
+		defer pubsub.Close()
+		
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-messages:
+			case <-ticker.C:
+			}
+			Load()
+		}
+	}()
+}
@@ -0,0 +1,178 @@
+package ipfilter
+
+import (
+	"testing"
+	
+	"usercenter/internal/models"
+	
+	"github.com/google/uuid"
+)
+
+func ipRule(scope, action, cidr string) models.IPRule {
+	return models.IPRule{CIDR: cidr, Action: action, Scope: scope, Status: models.IPRuleStatusEnabled}
+}
+
+func TestParseCIDR(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "10.0.0.0/8", want: "10.0.0.0/8"},
+		{value: "10.1.2.3/8", want: "10.0.0.0/8"},
+		{value: "192.168.1.10", want: "192.168.1.10/32"},
+		{value: " 192.168.1.10 ", want: "192.168.1.10/32"},
+		{value: "2001:db8::1", want: "2001:db8::1/128"},
+		{value: "2001:db8::/32", want: "2001:db8::/32"},
+		{value: "::ffff:192.168.1.10", want: "192.168.1.10/32"},
+		{value: "10.0.0.0/33", wantErr: true},
+		{value: "not-an-ip", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			network, err := ParseCIDR(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCIDR(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if err == nil && network.String() != tt.want {
+				t.Errorf("ParseCIDR(%q) = %s, want %s", tt.value, network, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleSetAllowed(t *testing.T) {
+	disabled := ipRule(models.IPRuleScopeAdmin, models.IPRuleActionDeny, "0.0.0.0/0")
+	disabled.Status = models.IPRuleStatusDisabled
+	
+	tests := []struct {
+		name  string
+		rules []models.IPRule
+		scope string
+		ip    string
+		want  bool
+	}{
+		{name: "没有规则", scope: models.IPRuleScopeLogin, ip: "203.0.113.1", want: true},
+		{
+			name:  "命中拒绝规则",
+			rules: []models.IPRule{ipRule(models.IPRuleScopeLogin, models.IPRuleActionDeny, "203.0.113.0/24")},
+			scope: models.IPRuleScopeLogin, ip: "203.0.113.7", want: false,
+		},
+		{
+			name:  "未命中拒绝规则",
+			rules: []models.IPRule{ipRule(models.IPRuleScopeLogin, models.IPRuleActionDeny, "203.0.113.0/24")},
+			scope: models.IPRuleScopeLogin, ip: "203.0.114.7", want: true,
+		},
+		{
+			name:  "命中允许规则",
+			rules: []models.IPRule{ipRule(models.IPRuleScopeAdmin, models.IPRuleActionAllow, "10.0.0.0/8")},
+			scope: models.IPRuleScopeAdmin, ip: "10.20.30.40", want: true,
+		},
+		{
+			name:  "存在允许规则但未命中",
+			rules: []models.IPRule{ipRule(models.IPRuleScopeAdmin, models.IPRuleActionAllow, "10.0.0.0/8")},
+			scope: models.IPRuleScopeAdmin, ip: "11.0.0.1", want: false,
+		},
+		{
+			name: "拒绝优先于允许",
+			rules: []models.IPRule{
+				ipRule(models.IPRuleScopeAdmin, models.IPRuleActionAllow, "10.0.0.0/8"),
+				ipRule(models.IPRuleScopeAdmin, models.IPRuleActionDeny, "10.0.0.5"),
+			},
+			scope: models.IPRuleScopeAdmin, ip: "10.0.0.5", want: false,
+		},
+		{
+			name: "多条允许规则命中其一",
+			rules: []models.IPRule{
+				ipRule(models.IPRuleScopeAdmin, models.IPRuleActionAllow, "10.0.0.0/8"),
+				ipRule(models.IPRuleScopeAdmin, models.IPRuleActionAllow, "192.168.0.0/16"),
+			},
+			scope: models.IPRuleScopeAdmin, ip: "192.168.3.4", want: true,
+		},
+		{
+			name:  "其他范围的规则不生效",
+			rules: []models.IPRule{ipRule(models.IPRuleScopeAdmin, models.IPRuleActionDeny, "0.0.0.0/0")},
+			scope: models.IPRuleScopeLogin, ip: "203.0.113.1", want: true,
+		},
+		{
+			name:  "停用的规则不生效",
+			rules: []models.IPRule{disabled},
+			scope: models.IPRuleScopeAdmin, ip: "203.0.113.1", want: true,
+		},
+		{
+			name:  "无法解析的规则被忽略",
+			rules: []models.IPRule{ipRule(models.IPRuleScopeAdmin, models.IPRuleActionAllow, "bogus")},
+			scope: models.IPRuleScopeAdmin, ip: "203.0.113.1", want: true,
+		},
+		{
+			name:  "IPv6允许规则",
+			rules: []models.IPRule{ipRule(models.IPRuleScopeGlobal, models.IPRuleActionAllow, "2001:db8::/32")},
+			scope: models.IPRuleScopeGlobal, ip: "2001:db8::42", want: true,
+		},
+		{
+			name:  "IPv4映射地址匹配IPv4规则",
+			rules: []models.IPRule{ipRule(models.IPRuleScopeGlobal, models.IPRuleActionDeny, "203.0.113.0/24")},
+			scope: models.IPRuleScopeGlobal, ip: "::ffff:203.0.113.9", want: false,
+		},
+		{
+			name:  "存在规则时无效IP被拒绝",
+			rules: []models.IPRule{ipRule(models.IPRuleScopeGlobal, models.IPRuleActionDeny, "203.0.113.0/24")},
+			scope: models.IPRuleScopeGlobal, ip: "unknown", want: false,
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Compile(tt.rules).Allowed(tt.scope, tt.ip); got != tt.want {
+				t.Errorf("Allowed(%q, %q) = %v, want %v", tt.scope, tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleSetUserAllowed(t *testing.T) {
+	userID, otherID := uuid.New(), uuid.New()
+	userRule := func(action, cidr string) models.IPRule {
+		r := ipRule(models.IPRuleScopeUser, action, cidr)
+		r.UserID = &userID
+		return r
+	}
+	
+	tests := []struct {
+		name   string
+		rules  []models.IPRule
+		userID uuid.UUID
+		ip     string
+		want   bool
+	}{
+		{name: "未设置个人白名单", userID: userID, ip: "203.0.113.1", want: true},
+		{name: "命中个人白名单", rules: []models.IPRule{userRule(models.IPRuleActionAllow, "198.51.100.0/24")}, userID: userID, ip: "198.51.100.20", want: true},
+		{name: "未命中个人白名单", rules: []models.IPRule{userRule(models.IPRuleActionAllow, "198.51.100.0/24")}, userID: userID, ip: "203.0.113.1", want: false},
+		{name: "其他用户不受影响", rules: []models.IPRule{userRule(models.IPRuleActionAllow, "198.51.100.0/24")}, userID: otherID, ip: "203.0.113.1", want: true},
+		{name: "个人拒绝规则被忽略", rules: []models.IPRule{userRule(models.IPRuleActionDeny, "203.0.113.0/24")}, userID: userID, ip: "203.0.113.1", want: true},
+		{name: "设置白名单时无效IP被拒绝", rules: []models.IPRule{userRule(models.IPRuleActionAllow, "198.51.100.0/24")}, userID: userID, ip: "bogus", want: false},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Compile(tt.rules).UserAllowed(tt.userID, tt.ip); got != tt.want {
+				t.Errorf("UserAllowed(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserRulesDoNotAffectScopes(t *testing.T) {
+	userID := uuid.New()
+	r := ipRule(models.IPRuleScopeUser, models.IPRuleActionAllow, "198.51.100.0/24")
+	r.UserID = &userID
+	set := Compile([]models.IPRule{r})
+	
+	for _, scope := range []string{models.IPRuleScopeGlobal, models.IPRuleScopeLogin, models.IPRuleScopeUser} {
+		if !set.Allowed(scope, "203.0.113.1") {
+			t.Errorf("Allowed(%q) = false, personal allowlist must not restrict scopes", scope)
+		}
+	}
+}
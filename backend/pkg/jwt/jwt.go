@@ -10,14 +10,49 @@ import (
 	"github.com/google/uuid"
 )
 
+// Issuer Token签发方，同时作为客户端JWT断言的受众
+const Issuer = "usercenter"
+
+// Token类型
+const (
+	TokenTypeUser           = "user"
+	TokenTypeServiceAccount = "service_account"
+	TokenTypePasswordChange = "password_change" // 受限Token，只能用于修改密码
+)
+
 type Claims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Username string    `json:"username"`
-	Role     string    `json:"role"`
-	DeviceID string    `json:"device_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	DeviceID  string    `json:"device_id"`
+	TokenType string    `json:"token_type,omitempty"`
+	Roles     []string  `json:"roles,omitempty"`
+	ClientID  string    `json:"client_id,omitempty"`
+	Scope     string    `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// IsServiceAccount 是否为服务账号Token
+func (c *Claims) IsServiceAccount() bool {
+	return c.TokenType == TokenTypeServiceAccount
+}
+
+// IsPasswordChangeOnly 是否为只能修改密码的受限Token
+func (c *Claims) IsPasswordChangeOnly() bool {
+	return c.TokenType == TokenTypePasswordChange
+}
+
+// RoleCodes 返回Token携带的全部角色
+func (c *Claims) RoleCodes() []string {
+	if len(c.Roles) > 0 {
+		return c.Roles
+	}
+	if c.Role != "" {
+		return []string{c.Role}
+	}
+	return []string{}
+}
+
 // GenerateToken 生成JWT Token
 func GenerateToken(userID uuid.UUID, username, role, deviceID string) (string, error) {
 	cfg := config.GlobalConfig
@@ -34,7 +69,7 @@ func GenerateToken(userID uuid.UUID, username, role, deviceID string) (string, e
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.JWT.Expires)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "usercenter",
+			Issuer:    Issuer,
 			Subject:   userID.String(),
 			ID:        uuid.New().String(),
 		},
@@ -44,6 +79,122 @@ func GenerateToken(userID uuid.UUID, username, role, deviceID string) (string, e
 	return token.SignedString([]byte(cfg.JWT.Secret))
 }
 
+// GeneratePasswordChangeToken 生成只能用于修改密码的受限Token
+func GeneratePasswordChangeToken(userID uuid.UUID, username, deviceID string) (string, time.Time, error) {
+	cfg := config.GlobalConfig
+	if cfg == nil {
+		return "", time.Time{}, errors.New("config not initialized")
+	}
+	
+	now := time.Now()
+	expiresAt := now.Add(cfg.JWT.PasswordChangeTokenExpires)
+	claims := Claims{
+		UserID:    userID,
+		Username:  username,
+		DeviceID:  deviceID,
+		TokenType: TokenTypePasswordChange,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    Issuer,
+			Subject:   userID.String(),
+			ID:        uuid.New().String(),
+		},
+	}
+	
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString([]byte(cfg.JWT.Secret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// GenerateServiceToken 为服务账号生成访问Token
+func GenerateServiceToken(accountID uuid.UUID, clientID, name string, roles []string, scope string) (string, time.Time, error) {
+	cfg := config.GlobalConfig
+	if cfg == nil {
+		return "", time.Time{}, errors.New("config not initialized")
+	}
+	
+	var role string
+	if len(roles) > 0 {
+		role = roles[0]
+	}
+	
+	now := time.Now()
+	expiresAt := now.Add(cfg.JWT.ServiceTokenExpires)
+	claims := Claims{
+		UserID:    accountID,
+		Username:  name,
+		Role:      role,
+		TokenType: TokenTypeServiceAccount,
+		Roles:     roles,
+		ClientID:  clientID,
+		Scope:     scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    Issuer,
+			Subject:   clientID,
+			ID:        uuid.New().String(),
+		},
+	}
+	
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString([]byte(cfg.JWT.Secret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// ParseClientAssertion 使用客户端登记的PEM公钥校验JWT断言（RFC 7523）
+func ParseClientAssertion(assertion, publicKeyPEM string) (*jwt.RegisteredClaims, error) {
+	if publicKeyPEM == "" {
+		return nil, errors.New("client has no public key")
+	}
+	
+	token, err := jwt.ParseWithClaims(assertion, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			return jwt.ParseRSAPublicKeyFromPEM([]byte(publicKeyPEM))
+		case *jwt.SigningMethodECDSA:
+			return jwt.ParseECPublicKeyFromPEM([]byte(publicKeyPEM))
+		case *jwt.SigningMethodEd25519:
+			return jwt.ParseEdPublicKeyFromPEM([]byte(publicKeyPEM))
+		default:
+			return nil, errors.New("unexpected signing method")
+		}
+	}, jwt.WithAudience(Issuer))
+	if err != nil {
+		return nil, err
+	}
+	
+	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid assertion")
+	}
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("assertion has no expiration")
+	}
+	return claims, nil
+}
+
+// PeekSubject 在不校验签名的情况下读取断言的sub，仅用于查找校验所需的公钥
+func PeekSubject(assertion string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(assertion, claims); err != nil {
+		return "", err
+	}
+	if claims.Subject == "" {
+		return "", errors.New("assertion has no subject")
+	}
+	return claims.Subject, nil
+}
+
 // ParseToken 解析JWT Token
 func ParseToken(tokenString string) (*Claims, error) {
 	cfg := config.GlobalConfig
@@ -0,0 +1,52 @@
+package jwt
+
+import (
+	"strconv"
+	"time"
+	
+	"usercenter/internal/cache"
+	
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// 签发时间精确到毫秒，吊销时间点也按毫秒记录，与吊销在同一秒内稍早签发的Token同样失效
+func init() {
+	jwt.TimePrecision = time.Millisecond
+}
+
+func userRevokedKey(userID uuid.UUID) string {
+	return "user_tokens_revoked:" + userID.String()
+}
+
+func serviceAccountRevokedKey(accountID uuid.UUID) string {
+	return "service_account_revoked:" + accountID.String()
+}
+
+// RevokeUserTokens 使用户此前签发的所有Token失效，expiration应不短于最后一个Token的剩余有效期
+func RevokeUserTokens(userID uuid.UUID, expiration time.Duration) error {
+	return cache.Set(userRevokedKey(userID), time.Now().UnixMilli(), expiration)
+}
+
+// RevokeServiceAccountTokens 使服务账号此前签发的所有Token失效
+func RevokeServiceAccountTokens(accountID uuid.UUID, expiration time.Duration) error {
+	return cache.Set(serviceAccountRevokedKey(accountID), time.Now().UnixMilli(), expiration)
+}
+
+// IsRevoked 检查Token是否签发于所属用户或服务账号的吊销时间点之前，与吊销时间点相同也视为失效
+func IsRevoked(claims *Claims) bool {
+	key := userRevokedKey(claims.UserID)
+	if claims.IsServiceAccount() {
+		key = serviceAccountRevokedKey(claims.UserID)
+	}
+	
+	value, err := cache.Get(key)
+	if err != nil {
+		return false
+	}
+	revokedAt, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || claims.IssuedAt == nil {
+		return false
+	}
+	return claims.IssuedAt.UnixMilli() <= revokedAt
+}
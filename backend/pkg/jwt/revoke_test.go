@@ -0,0 +1,68 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+	
+	"usercenter/internal/cache"
+	"usercenter/internal/config"
+	
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// setupRevoke 使用miniredis和测试配置，测试结束后恢复
+func setupRevoke(t *testing.T) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	prevRDB, prevConfig := cache.RDB, config.GlobalConfig
+	cache.RDB = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	config.GlobalConfig = &config.Config{
+		JWT: config.JWTConfig{Secret: "test-secret", Expires: time.Hour},
+	}
+	t.Cleanup(func() {
+		cache.RDB.Close()
+		cache.RDB, config.GlobalConfig = prevRDB, prevConfig
+	})
+}
+
+// issue 签发并解析Token，得到经过编码的签发时间
+func issue(t *testing.T, userID uuid.UUID) *Claims {
+	t.Helper()
+	token, err := GenerateToken(userID, "alice", "user", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestIsRevoked(t *testing.T) {
+	setupRevoke(t)
+	userID := uuid.New()
+	
+	before := issue(t, userID)
+	if IsRevoked(before) {
+		t.Fatal("token revoked before RevokeUserTokens")
+	}
+	
+	// 吊销紧接在签发之后，两者通常在同一秒内
+	if err := RevokeUserTokens(userID, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if !IsRevoked(before) {
+		t.Error("token issued just before the revocation is still valid")
+	}
+	
+	time.Sleep(2 * time.Millisecond)
+	if after := issue(t, userID); IsRevoked(after) {
+		t.Error("token issued after the revocation is revoked")
+	}
+	if other := issue(t, uuid.New()); IsRevoked(other) {
+		t.Error("revocation applied to another user")
+	}
+}
@@ -0,0 +1,278 @@
+package outbound
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+	
+	"usercenter/internal/config"
+	"usercenter/internal/database"
+	"usercenter/internal/models"
+	
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 消息渠道
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+var ErrNotRetryable = errors.New("message is not dead or has no payload")
+
+// Sender 发送一条消息，payload为入队时序列化的内容
+type Sender func(payload []byte) error
+
+var (
+	settings = config.OutboundConfig{
+		Workers:      4,
+		MaxAttempts:  6,
+		BaseDelay:    10 * time.Second,
+		MaxDelay:     30 * time.Minute,
+		PollInterval: time.Second,
+		Lease:        5 * time.Minute,
+	}
+	
+	sendersMu sync.RWMutex
+	senders   = map[string]Sender{}
+	
+	// wake 有新消息时唤醒一个空闲的worker
+	wake = make(chan struct{}, 1)
+)
+
+// Init 设置队列参数，未启用时Enabled返回false，消息由调用方同步发送
+func Init(cfg *config.OutboundConfig) {
+	settings = *cfg
+}
+
+// Enabled 是否通过队列发送
+func Enabled() bool {
+	return settings.Enabled
+}
+
+// RegisterSender 注册渠道的发送函数，同一渠道重复注册时覆盖
+func RegisterSender(channel string, sender Sender) {
+	sendersMu.Lock()
+	defer sendersMu.Unlock()
+	senders[channel] = sender
+}
+
+// Enqueue 加入发送队列，expiresAt不为零时超过该时间仍未发出的消息不再发送。
+// 设置了expiresAt的消息视为包含验证码、重置链接等凭据，进入死信时清除内容，不能重试
+func Enqueue(channel, recipient, summary string, payload interface{}, expiresAt time.Time) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	
+	msg := models.OutboundMessage{
+		Channel:       channel,
+		Recipient:     recipient,
+		Summary:       summary,
+		Payload:       string(data),
+		Status:        models.OutboundStatusPending,
+		MaxAttempts:   settings.MaxAttempts,
+		NextAttemptAt: time.Now(),
+	}
+	if !expiresAt.IsZero() {
+		msg.ExpiresAt = &expiresAt
+	}
+	if err := database.DB.Create(&msg).Error; err != nil {
+		return err
+	}
+	
+	notify()
+	return nil
+}
+
+// Start 启动发送worker，多个实例可同时运行，消息通过行锁保证只被一个worker取出
+func Start() {
+	if !settings.Enabled {
+		return
+	}
+	workers := settings.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go work()
+	}
+}
+
+// Retry 将死信重新加入队列，发送次数清零
+func Retry(id uuid.UUID) error {
+	result := database.DB.Model(&models.OutboundMessage{}).
+		Where("id = ? AND status = ? AND payload <> ''", id, models.OutboundStatusDead).
+		Updates(retryUpdates())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotRetryable
+	}
+	
+	notify()
+	return nil
+}
+
+// RetryDead 将所有未过期的死信重新加入队列，返回重新加入的数量
+func RetryDead() (int64, error) {
+	result := database.DB.Model(&models.OutboundMessage{}).
+		Where("status = ? AND payload <> '' AND (expires_at IS NULL OR expires_at > ?)", models.OutboundStatusDead, time.Now()).
+		Updates(retryUpdates())
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected > 0 {
+		notify()
+	}
+	return result.RowsAffected, nil
+}
+
+func retryUpdates() map[string]interface{} {
+	return map[string]interface{}{
+		"status":          models.OutboundStatusPending,
+		"attempts":        0,
+		"max_attempts":    settings.MaxAttempts,
+		"next_attempt_at": time.Now(),
+		"locked_until":    nil,
+	}
+}
+
+func notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// work 持续取出到期的消息发送，队列为空时等待唤醒或定期检查
+func work() {
+	interval := settings.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	
+	for {
+		msg, err := claim()
+		if err == nil {
+			process(msg)
+			continue
+		}
+		
+		select {
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim 取出一条到期的消息并加锁，租约到期仍未完成的消息视为发送中断，可被重新取出
+func claim() (*models.OutboundMessage, error) {
+	var msg models.OutboundMessage
+	now := time.Now()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?)",
+				models.OutboundStatusPending, now, models.OutboundStatusProcessing, now).
+			Order("next_attempt_at").
+			First(&msg).Error
+		if err != nil {
+			return err
+		}
+		
+		lockedUntil := now.Add(settings.Lease)
+		msg.Status = models.OutboundStatusProcessing
+		msg.Attempts++
+		msg.LockedUntil = &lockedUntil
+		return tx.Model(&msg).Updates(map[string]interface{}{
+			"status":       msg.Status,
+			"attempts":     msg.Attempts,
+			"locked_until": lockedUntil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// process 发送消息并记录结果，失败时按退避时间重新排队，达到最大次数后进入死信
+func process(msg *models.OutboundMessage) {
+	now := time.Now()
+	if msg.ExpiresAt != nil && now.After(*msg.ExpiresAt) {
+		database.DB.Model(msg).Updates(map[string]interface{}{
+			"status":       models.OutboundStatusDead,
+			"payload":      "",
+			"locked_until": nil,
+			"last_error":   "expired before delivery",
+		})
+		return
+	}
+	
+	err := send(msg)
+	if err == nil {
+		database.DB.Model(msg).Updates(map[string]interface{}{
+			"status":       models.OutboundStatusSent,
+			"payload":      "",
+			"sent_at":      time.Now(),
+			"locked_until": nil,
+			"last_error":   "",
+		})
+		return
+	}
+	
+	updates := map[string]interface{}{
+		"status":          models.OutboundStatusPending,
+		"locked_until":    nil,
+		"last_error":      err.Error(),
+		"next_attempt_at": now.Add(backoff(msg.Attempts)),
+	}
+	if msg.Attempts >= msg.MaxAttempts {
+		updates["status"] = models.OutboundStatusDead
+		// 包含凭据的消息不在死信中长期保存明文
+		if msg.ExpiresAt != nil {
+			updates["payload"] = ""
+		}
+	}
+	database.DB.Model(msg).Updates(updates)
+}
+
+// send 调用渠道的发送函数，发送函数panic时按发送失败处理，不影响worker继续运行
+func send(msg *models.OutboundMessage) (err error) {
+	sendersMu.RLock()
+	sender, ok := senders[msg.Channel]
+	sendersMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no sender registered for channel %s", msg.Channel)
+	}
+	
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sender panic: %v", r)
+		}
+	}()
+	return sender([]byte(msg.Payload))
+}
+
+// backoff 第n次发送失败后的等待时间，每次翻倍并加入最多20%的随机抖动，避免大量消息同时重试
+func backoff(attempts int) time.Duration {
+	delay := settings.BaseDelay
+	for i := 1; i < attempts && delay < settings.MaxDelay; i++ {
+		delay *= 2
+	}
+	if settings.MaxDelay > 0 && delay > settings.MaxDelay {
+		delay = settings.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
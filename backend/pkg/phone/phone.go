@@ -0,0 +1,106 @@
+package phone
+
+import (
+	"errors"
+	"strings"
+	
+	"usercenter/internal/config"
+	
+	"github.com/nyaruka/phonenumbers"
+)
+
+var (
+	ErrInvalidNumber    = errors.New("手机号格式不正确")
+	ErrNotMobile        = errors.New("请输入手机号码")
+	ErrRegionNotAllowed = errors.New("暂不支持该国家或地区的手机号")
+)
+
+// settings 号码解析规则，启动时由Init按配置替换
+var settings = config.PhoneConfig{
+	DefaultRegion: "CN",
+	MobileOnly:    true,
+}
+
+// Init 设置号码解析规则
+func Init(cfg *config.PhoneConfig) {
+	settings = *cfg
+	settings.DefaultRegion = strings.ToUpper(settings.DefaultRegion)
+	for i, region := range settings.AllowedRegions {
+		settings.AllowedRegions[i] = strings.ToUpper(region)
+	}
+}
+
+// Number 解析后的号码
+type Number struct {
+	E164        string `json:"e164"`         // +8613800138000
+	Region      string `json:"region"`       // ISO 3166-1代码，如CN
+	CountryCode int    `json:"country_code"` // 86
+	National    string `json:"national"`     // 不含国家码和国内长途前缀的号码，如13800138000
+}
+
+// Parse 解析并校验号码。以+或国际冠字开头的号码按其国家码解析，
+// 其他号码视为默认地区的国内号码，校验使用号码所属地区的规则
+func Parse(raw string) (*Number, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, ErrInvalidNumber
+	}
+	
+	num, err := phonenumbers.Parse(raw, settings.DefaultRegion)
+	if err != nil {
+		return nil, ErrInvalidNumber
+	}
+	
+	region := phonenumbers.GetRegionCodeForNumber(num)
+	if !phonenumbers.IsValidNumberForRegion(num, region) {
+		return nil, ErrInvalidNumber
+	}
+	if settings.MobileOnly {
+		switch phonenumbers.GetNumberType(num) {
+		case phonenumbers.MOBILE, phonenumbers.FIXED_LINE_OR_MOBILE:
+		default:
+			return nil, ErrNotMobile
+		}
+	}
+	if !regionAllowed(region) {
+		return nil, ErrRegionNotAllowed
+	}
+	
+	return &Number{
+		E164:        phonenumbers.Format(num, phonenumbers.E164),
+		Region:      region,
+		CountryCode: int(num.GetCountryCode()),
+		National:    phonenumbers.GetNationalSignificantNumber(num),
+	}, nil
+}
+
+// Normalize 校验号码并返回E.164格式
+func Normalize(raw string) (string, error) {
+	number, err := Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	return number.E164, nil
+}
+
+// Region 返回号码所属地区，无法识别时返回空字符串。
+// 只用于已保存的号码，不检查号码类型和允许的地区
+func Region(e164 string) string {
+	num, err := phonenumbers.Parse(e164, settings.DefaultRegion)
+	if err != nil {
+		return ""
+	}
+	return phonenumbers.GetRegionCodeForNumber(num)
+}
+
+func regionAllowed(region string) bool {
+	if len(settings.AllowedRegions) == 0 {
+		return true
+	}
+	for _, allowed := range settings.AllowedRegions {
+		if allowed == region {
+			return true
+		}
+	}
+	return false
+}
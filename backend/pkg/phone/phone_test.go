@@ -0,0 +1,99 @@
+package phone
+
+import (
+	"testing"
+	
+	"usercenter/internal/config"
+)
+
+func TestParse(t *testing.T) {
+	prev := settings
+	t.Cleanup(func() { settings = prev })
+	
+	defaultCN := config.PhoneConfig{DefaultRegion: "CN", MobileOnly: true}
+	
+	tests := []struct {
+		name    string
+		cfg     config.PhoneConfig
+		raw     string
+		want    Number
+		wantErr error
+	}{
+		{
+			name: "默认地区的国内号码", cfg: defaultCN, raw: "13800138000",
+			want: Number{E164: "+8613800138000", Region: "CN", CountryCode: 86, National: "13800138000"},
+		},
+		{
+			name: "带国家码和空格", cfg: defaultCN, raw: " +86 138 0013 8000 ",
+			want: Number{E164: "+8613800138000", Region: "CN", CountryCode: 86, National: "13800138000"},
+		},
+		{
+			name: "国际冠字", cfg: defaultCN, raw: "008613800138000",
+			want: Number{E164: "+8613800138000", Region: "CN", CountryCode: 86, National: "13800138000"},
+		},
+		{
+			name: "其他国家码按号码所属地区解析", cfg: defaultCN, raw: "+1 650-253-0000",
+			want: Number{E164: "+16502530000", Region: "US", CountryCode: 1, National: "6502530000"},
+		},
+		{
+			name: "英国手机号", cfg: defaultCN, raw: "+44 7400 123456",
+			want: Number{E164: "+447400123456", Region: "GB", CountryCode: 44, National: "7400123456"},
+		},
+		{
+			name: "小写默认地区", cfg: config.PhoneConfig{DefaultRegion: "us", MobileOnly: true}, raw: "(650) 253-0000",
+			want: Number{E164: "+16502530000", Region: "US", CountryCode: 1, National: "6502530000"},
+		},
+		{name: "只接受手机号时拒绝固定电话", cfg: defaultCN, raw: "010-12345678", wantErr: ErrNotMobile},
+		{
+			name: "允许固定电话", cfg: config.PhoneConfig{DefaultRegion: "CN"}, raw: "010-12345678",
+			want: Number{E164: "+861012345678", Region: "CN", CountryCode: 86, National: "1012345678"},
+		},
+		{
+			name: "允许的地区", cfg: config.PhoneConfig{DefaultRegion: "CN", AllowedRegions: []string{"cn", "hk"}, MobileOnly: true}, raw: "13800138000",
+			want: Number{E164: "+8613800138000", Region: "CN", CountryCode: 86, National: "13800138000"},
+		},
+		{name: "不允许的地区", cfg: config.PhoneConfig{DefaultRegion: "CN", AllowedRegions: []string{"cn"}, MobileOnly: true}, raw: "+1 650-253-0000", wantErr: ErrRegionNotAllowed},
+		{name: "位数不足", cfg: defaultCN, raw: "1380013800", wantErr: ErrInvalidNumber},
+		{name: "国家码下无效的号码", cfg: defaultCN, raw: "+86 12345678901", wantErr: ErrInvalidNumber},
+		{name: "非数字", cfg: defaultCN, raw: "not a number", wantErr: ErrInvalidNumber},
+		{name: "空字符串", cfg: defaultCN, raw: "  ", wantErr: ErrInvalidNumber},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.AllowedRegions = append([]string(nil), tt.cfg.AllowedRegions...)
+			Init(&cfg)
+			
+			got, err := Parse(tt.raw)
+			if err != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.raw, err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.raw, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegion(t *testing.T) {
+	prev := settings
+	t.Cleanup(func() { settings = prev })
+	Init(&config.PhoneConfig{DefaultRegion: "CN", AllowedRegions: []string{"CN"}, MobileOnly: true})
+	
+	tests := []struct {
+		e164 string
+		want string
+	}{
+		{e164: "+8613800138000", want: "CN"},
+		{e164: "+16502530000", want: "US"},
+		{e164: "+861012345678", want: "CN"},
+		{e164: "invalid", want: ""},
+	}
+	
+	for _, tt := range tests {
+		if got := Region(tt.e164); got != tt.want {
+			t.Errorf("Region(%q) = %q, want %q", tt.e164, got, tt.want)
+		}
+	}
+}
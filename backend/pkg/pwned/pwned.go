@@ -0,0 +1,193 @@
+package pwned
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Checker 已泄露密码检查器。基于k-匿名（SHA-1前5位分桶）实现，
+// 既可以是本地文件，也可以是远程的range查询服务
+type Checker interface {
+	// Count 返回密码在泄露库中出现的次数，未出现时返回0
+	Count(password string) (int, error)
+}
+
+// DefaultChecker 全局检查器，未启用时为nil
+var DefaultChecker Checker
+
+// PrefixLength k-匿名分桶使用的SHA-1前缀长度
+const PrefixLength = 5
+
+// IsBreached 使用全局检查器判断密码出现次数是否达到阈值，未启用检查时返回false
+func IsBreached(password string, minCount int) (bool, error) {
+	if DefaultChecker == nil {
+		return false, nil
+	}
+	
+	count, err := DefaultChecker.Count(password)
+	if err != nil {
+		return false, err
+	}
+	if minCount <= 0 {
+		minCount = 1
+	}
+	return count >= minCount, nil
+}
+
+// HashPrefix 计算密码SHA-1的大写十六进制前缀和剩余部分
+func HashPrefix(password string) (prefix, suffix string) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return hash[:PrefixLength], hash[PrefixLength:]
+}
+
+// FileChecker 读取Have I Been Pwned格式的本地数据：
+// 路径为目录时，按前缀分桶，每个桶文件名为5位前缀（可带.txt后缀），内容为“后缀:次数”；
+// 路径为文件时，内容为按哈希排序的“完整哈希:次数”，使用二分查找定位
+type FileChecker struct {
+	path  string
+	isDir bool
+	size  int64
+}
+
+// NewFileChecker 创建本地文件检查器
+func NewFileChecker(path string) (*FileChecker, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &FileChecker{
+		path:  path,
+		isDir: info.IsDir(),
+		size:  info.Size(),
+	}, nil
+}
+
+// Count 返回密码在泄露库中出现的次数
+func (f *FileChecker) Count(password string) (int, error) {
+	prefix, suffix := HashPrefix(password)
+	if f.isDir {
+		return f.countInBucket(prefix, suffix)
+	}
+	return f.countInSortedFile(prefix + suffix)
+}
+
+// countInBucket 在前缀分桶文件中查找
+func (f *FileChecker) countInBucket(prefix, suffix string) (int, error) {
+	file, err := os.Open(filepath.Join(f.path, prefix))
+	if os.IsNotExist(err) {
+		file, err = os.Open(filepath.Join(f.path, prefix+".txt"))
+	}
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash, count, ok := parseLine(scanner.Text())
+		if ok && strings.EqualFold(hash, suffix) {
+			return count, nil
+		}
+	}
+	
+	return 0, scanner.Err()
+}
+
+// countInSortedFile 在按哈希排序的文件中二分查找。
+// 查找区间[low, high)为字节偏移，low始终指向行首，目标行（若存在）的起始位置落在区间内
+func (f *FileChecker) countInSortedFile(hash string) (int, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	
+	low, high := int64(0), f.size
+	for low < high {
+		mid := low + (high-low)/2
+		start, line, next, err := lineFrom(file, mid)
+		if err != nil {
+			return 0, err
+		}
+		// [mid, high)内没有行首，目标只可能在mid之前
+		if start >= high {
+			high = mid
+			continue
+		}
+		
+		lineHash, count, ok := parseLine(line)
+		if !ok {
+			return 0, errors.New("invalid breached password file format")
+		}
+		
+		switch strings.Compare(strings.ToUpper(lineHash), hash) {
+		case 0:
+			return count, nil
+		case -1:
+			low = next
+		default:
+			high = start
+		}
+	}
+	
+	return 0, nil
+}
+
+// lineFrom 读取起始位置不小于offset的第一行，返回该行的起始位置、内容和下一行的起始位置
+func lineFrom(file *os.File, offset int64) (int64, string, int64, error) {
+	start := offset
+	if offset > 0 {
+		// 从offset-1开始跳过当前行的剩余部分，offset恰好是行首时不会跳过该行
+		if _, err := file.Seek(offset-1, io.SeekStart); err != nil {
+			return 0, "", 0, err
+		}
+		skipped, err := bufio.NewReader(file).ReadString('\n')
+		if err == io.EOF {
+			return math.MaxInt64, "", 0, nil
+		}
+		if err != nil {
+			return 0, "", 0, err
+		}
+		start = offset - 1 + int64(len(skipped))
+	}
+	
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return 0, "", 0, err
+	}
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", 0, err
+	}
+	if line == "" {
+		return math.MaxInt64, "", 0, nil
+	}
+	
+	return start, strings.TrimRight(line, "\r\n"), start + int64(len(line)), nil
+}
+
+// parseLine 解析“哈希:次数”格式的行
+func parseLine(line string) (string, int, bool) {
+	hash, countText, found := strings.Cut(strings.TrimSpace(line), ":")
+	if !found {
+		return "", 0, false
+	}
+	
+	count, err := strconv.Atoi(strings.TrimSpace(countText))
+	if err != nil {
+		return "", 0, false
+	}
+	return hash, count, true
+}
@@ -0,0 +1,190 @@
+package pwned
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// writeSortedFile 写入按哈希排序的“完整哈希:次数”文件，密码i出现i+1次
+func writeSortedFile(t *testing.T, passwords []string, lineEnd string, trailing bool, lower bool) string {
+	t.Helper()
+	lines := make([]string, len(passwords))
+	for i, password := range passwords {
+		hash := sha1Hex(password)
+		if lower {
+			hash = strings.ToLower(hash)
+		}
+		lines[i] = fmt.Sprintf("%s:%d", hash, i+1)
+	}
+	sort.Slice(lines, func(i, j int) bool { return strings.ToUpper(lines[i]) < strings.ToUpper(lines[j]) })
+	
+	content := strings.Join(lines, lineEnd)
+	if trailing {
+		content += lineEnd
+	}
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileCheckerSortedFile(t *testing.T) {
+	var passwords []string
+	for i := 0; i < 200; i++ {
+		passwords = append(passwords, fmt.Sprintf("password-%d", i))
+	}
+	
+	tests := []struct {
+		name      string
+		passwords []string
+		lineEnd   string
+		trailing  bool
+		lower     bool
+	}{
+		{name: "LF结尾换行", passwords: passwords, lineEnd: "\n", trailing: true},
+		{name: "LF无结尾换行", passwords: passwords, lineEnd: "\n"},
+		{name: "CRLF", passwords: passwords, lineEnd: "\r\n", trailing: true},
+		{name: "小写哈希", passwords: passwords, lineEnd: "\n", trailing: true, lower: true},
+		{name: "单行", passwords: passwords[:1], lineEnd: "\n"},
+		{name: "两行", passwords: passwords[:2], lineEnd: "\n", trailing: true},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker, err := NewFileChecker(writeSortedFile(t, tt.passwords, tt.lineEnd, tt.trailing, tt.lower))
+			if err != nil {
+				t.Fatal(err)
+			}
+			
+			for i, password := range tt.passwords {
+				count, err := checker.Count(password)
+				if err != nil {
+					t.Fatalf("Count(%q) error = %v", password, err)
+				}
+				if count != i+1 {
+					t.Errorf("Count(%q) = %d, want %d", password, count, i+1)
+				}
+			}
+			for _, password := range []string{"not-breached", "password-x", ""} {
+				count, err := checker.Count(password)
+				if err != nil {
+					t.Fatalf("Count(%q) error = %v", password, err)
+				}
+				if count != 0 {
+					t.Errorf("Count(%q) = %d, want 0", password, count)
+				}
+			}
+		})
+	}
+}
+
+func TestFileCheckerEmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	checker, err := NewFileChecker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	if count, err := checker.Count("password"); err != nil || count != 0 {
+		t.Errorf("Count() = %d, %v, want 0, nil", count, err)
+	}
+}
+
+func TestFileCheckerInvalidLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte("not a hash line\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	checker, err := NewFileChecker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	if _, err := checker.Count("password"); err == nil {
+		t.Error("Count() error = nil, want format error")
+	}
+}
+
+func TestFileCheckerBuckets(t *testing.T) {
+	dir := t.TempDir()
+	prefix, suffix := HashPrefix("password")
+	if err := os.WriteFile(filepath.Join(dir, prefix), []byte("0000000000000000000000000000000000A:1\r\n"+suffix+":42\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	txtPrefix, txtSuffix := HashPrefix("123456")
+	if err := os.WriteFile(filepath.Join(dir, txtPrefix+".txt"), []byte(strings.ToLower(txtSuffix)+":7\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	checker, err := NewFileChecker(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{password: "password", want: 42},
+		{password: "123456", want: 7},
+		{password: "not-breached", want: 0},
+	}
+	for _, tt := range tests {
+		count, err := checker.Count(tt.password)
+		if err != nil {
+			t.Fatalf("Count(%q) error = %v", tt.password, err)
+		}
+		if count != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.password, count, tt.want)
+		}
+	}
+}
+
+type fixedChecker int
+
+func (c fixedChecker) Count(string) (int, error) { return int(c), nil }
+
+func TestIsBreached(t *testing.T) {
+	prev := DefaultChecker
+	t.Cleanup(func() { DefaultChecker = prev })
+	
+	tests := []struct {
+		name     string
+		checker  Checker
+		minCount int
+		want     bool
+	}{
+		{name: "未启用检查", checker: nil, minCount: 1, want: false},
+		{name: "未出现", checker: fixedChecker(0), minCount: 1, want: false},
+		{name: "达到阈值", checker: fixedChecker(3), minCount: 3, want: true},
+		{name: "未达阈值", checker: fixedChecker(2), minCount: 3, want: false},
+		{name: "阈值为0时按1处理", checker: fixedChecker(1), minCount: 0, want: true},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			DefaultChecker = tt.checker
+			got, err := IsBreached("password", tt.minCount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("IsBreached() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package sms
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	
+	"usercenter/internal/config"
+	
+	"github.com/google/uuid"
+)
+
+// AliyunProvider 阿里云短信，直接调用RPC接口（签名版本1.0）
+type AliyunProvider struct {
+	client *http.Client
+	config *config.AliyunSMSConfig
+}
+
+func NewAliyunProvider(cfg *config.AliyunSMSConfig) (*AliyunProvider, error) {
+	if cfg.AccessKeyID == "" || cfg.AccessKeySecret == "" || cfg.SignName == "" {
+		return nil, errors.New("access_key_id, access_key_secret and sign_name are required")
+	}
+	
+	return &AliyunProvider{
+		client: &http.Client{Timeout: 10 * time.Second},
+		config: cfg,
+	}, nil
+}
+
+func (p *AliyunProvider) Name() string {
+	return ProviderAliyun
+}
+
+// Send 使用模板发送短信，模板参数按名称传递
+func (p *AliyunProvider) Send(msg *Message) (string, error) {
+	templateCode := templateFor(p.config.Templates, msg)
+	if templateCode == "" {
+		return "", fmt.Errorf("no template configured for %s", msg.Type)
+	}
+	
+	params := make(map[string]string, len(msg.Params))
+	for _, param := range msg.Params {
+		params[param.Name] = param.Value
+	}
+	templateParam, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	
+	query := map[string]string{
+		"AccessKeyId":      p.config.AccessKeyID,
+		"Action":           "SendSms",
+		"Format":           "JSON",
+		"RegionId":         p.config.RegionID,
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureNonce":   uuid.New().String(),
+		"SignatureVersion": "1.0",
+		"Timestamp":        time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		"Version":          "2017-05-25",
+		"PhoneNumbers":     aliyunPhoneNumber(msg.Phone),
+		"SignName":         p.config.SignName,
+		"TemplateCode":     templateCode,
+		"TemplateParam":    string(templateParam),
+	}
+	if query["RegionId"] == "" {
+		query["RegionId"] = "cn-hangzhou"
+	}
+	
+	endpoint := p.config.Endpoint
+	if endpoint == "" {
+		endpoint = "dysmsapi.aliyuncs.com"
+	}
+	
+	resp, err := p.client.Get("https://" + endpoint + "/?" + p.sign(query))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	
+	var result struct {
+		Code    string `json:"Code"`
+		Message string `json:"Message"`
+		BizID   string `json:"BizId"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("SMS API error: %s", resp.Status)
+	}
+	if result.Code != "OK" {
+		return "", fmt.Errorf("SMS send failed: %s %s", result.Code, result.Message)
+	}
+	return result.BizID, nil
+}
+
+// sign 按参数名排序后计算签名，返回带签名的查询字符串
+func (p *AliyunProvider) sign(query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = aliyunEscape(key) + "=" + aliyunEscape(query[key])
+	}
+	canonical := strings.Join(pairs, "&")
+	
+	mac := hmac.New(sha1.New, []byte(p.config.AccessKeySecret+"&"))
+	mac.Write([]byte("GET&" + aliyunEscape("/") + "&" + aliyunEscape(canonical)))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	
+	return "Signature=" + aliyunEscape(signature) + "&" + canonical
+}
+
+// aliyunEscape 阿里云签名要求的URL编码
+func aliyunEscape(value string) string {
+	escaped := url.QueryEscape(value)
+	escaped = strings.ReplaceAll(escaped, "+", "%20")
+	escaped = strings.ReplaceAll(escaped, "*", "%2A")
+	return strings.ReplaceAll(escaped, "%7E", "~")
+}
+
+// aliyunPhoneNumber 中国大陆号码不带国家码，其他号码使用不带+的国际格式
+func aliyunPhoneNumber(phone string) string {
+	phone = strings.TrimPrefix(phone, "+")
+	if strings.HasPrefix(phone, "86") && len(phone) == 13 {
+		return phone[2:]
+	}
+	return phone
+}
@@ -0,0 +1,55 @@
+package sms
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+	
+	"usercenter/internal/config"
+	
+	"github.com/google/uuid"
+)
+
+// FileProvider 把短信写入文件或标准输出而不真正发送，仅用于开发和测试环境。
+// 输出中包含验证码明文，生产环境不要使用
+type FileProvider struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func NewFileProvider(cfg *config.FileSMSConfig) (*FileProvider, error) {
+	if cfg.Path == "" {
+		return &FileProvider{out: os.Stdout}, nil
+	}
+	
+	file, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileProvider{out: file}, nil
+}
+
+func (p *FileProvider) Name() string {
+	return ProviderFile
+}
+
+// Send 每条短信写入一行
+func (p *FileProvider) Send(msg *Message) (string, error) {
+	return p.write(msg.Phone, msg.Type, msg.Content)
+}
+
+// SendVoiceCode 语音验证码同样写入一行
+func (p *FileProvider) SendVoiceCode(phone, code string) (string, error) {
+	return p.write(phone, MessageVoiceCode, code)
+}
+
+func (p *FileProvider) write(phone, messageType, content string) (string, error) {
+	messageID := uuid.New().String()
+	
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := fmt.Fprintf(p.out, "%s\t%s\t%s\t%s\t%s\n", time.Now().Format(time.RFC3339), messageID, phone, messageType, content)
+	return messageID, err
+}
@@ -1,128 +1,296 @@
 package sms
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 	
 	"usercenter/internal/config"
-	
-	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
-	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
-	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
-	sms "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms/v20210111"
+	"usercenter/internal/database"
+	"usercenter/internal/models"
+	"usercenter/pkg/outbound"
+	"usercenter/pkg/phone"
+	"usercenter/pkg/templates"
+)
+
+// 短信消息类型，各服务商按类型配置模板ID
+const (
+	MessageVerification = "verification" // 验证码，参数：code、minutes
+	MessageNotification = "notification" // 通用通知，参数：content
+	MessageLoginAlert   = "login_alert"  // 异常登录提醒，参数：time、reason、ip、link
+	MessageVoiceCode    = "voice_code"   // 语音验证码
+)
+
+// 短信服务商
+const (
+	ProviderTencent = "tencent"
+	ProviderAliyun  = "aliyun"
+	ProviderTwilio  = "twilio"
+	ProviderFile    = "file"
 )
 
+// Param 模板参数，Name供使用命名参数的服务商（阿里云）使用，使用位置参数的服务商（腾讯云）按顺序取Value
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Message 一条短信
+type Message struct {
+	Phone   string
+	Type    string
+	Locale  string // 收件人的语言，用于选择服务商模板和渲染Content
+	Params  []Param
+	Content string // 完整的短信内容，供不使用模板的服务商（Twilio、file）发送，为空时按Type渲染短信模板
+	
+	// 通过发送队列发送时，超过该时间仍未发出则放弃。包含验证码、链接等有时效凭据的短信必须设置，
+	// 发送队列据此在消息进入死信时清除内容
+	ExpiresAt time.Time
+}
+
+// Provider 短信服务商，Send返回服务商的消息ID
+type Provider interface {
+	Name() string
+	Send(msg *Message) (string, error)
+}
+
+// VoiceProvider 支持语音验证码的服务商
+type VoiceProvider interface {
+	SendVoiceCode(phone, code string) (string, error)
+}
+
+// SMSService 按配置的顺序使用服务商发送短信，主服务商失败时依次尝试备用服务商，
+// 每条短信写入一条发送记录
 type SMSService struct {
-	client *sms.Client
-	config *config.TencentSMSConfig
+	providers []Provider
+	routes    map[string][]Provider // 按号码所属地区指定的服务商
 }
 
-func NewSMSService(cfg *config.TencentSMSConfig) (*SMSService, error) {
-	credential := common.NewCredential(cfg.SecretID, cfg.SecretKey)
+var defaultService *SMSService
+
+// NewSMSService 创建主服务商、备用服务商和按地区路由的服务商
+func NewSMSService(cfg *config.SMSConfig) (*SMSService, error) {
+	primary := cfg.Provider
+	if primary == "" && cfg.Tencent.SecretID != "" {
+		// 兼容只配置了腾讯云参数的旧配置
+		primary = ProviderTencent
+	}
+	if primary == "" {
+		return nil, errors.New("sms provider not configured")
+	}
 	
-	cpf := profile.NewClientProfile()
-	cpf.HttpProfile.Endpoint = "sms.tencentcloudapi.com"
+	// 同一服务商在默认顺序和地区路由中共用一个实例
+	instances := map[string]Provider{}
+	resolve := func(names []string) ([]Provider, error) {
+		var providers []Provider
+		seen := map[string]bool{}
+		for _, name := range names {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			
+			provider, ok := instances[name]
+			if !ok {
+				var err error
+				provider, err = newProvider(name, cfg)
+				if err != nil {
+					return nil, fmt.Errorf("sms provider %s: %w", name, err)
+				}
+				instances[name] = provider
+			}
+			providers = append(providers, provider)
+		}
+		return providers, nil
+	}
 	
-	client, err := sms.NewClient(credential, "ap-beijing", cpf)
+	providers, err := resolve(append([]string{primary}, cfg.Fallbacks...))
 	if err != nil {
 		return nil, err
 	}
-	
-	return &SMSService{
-		client: client,
-		config: cfg,
-	}, nil
+	s := &SMSService{providers: providers, routes: map[string][]Provider{}}
+	for region, names := range cfg.Routes {
+		if len(names) == 0 {
+			continue
+		}
+		providers, err := resolve(names)
+		if err != nil {
+			return nil, fmt.Errorf("sms route %s: %w", region, err)
+		}
+		// 配置文件中的键会被转为小写
+		s.routes[strings.ToUpper(region)] = providers
+	}
+	return s, nil
 }
 
-// SendVerificationCode 发送验证码短信
-func (s *SMSService) SendVerificationCode(phone, code string) error {
-	request := sms.NewSendSmsRequest()
-	
-	request.PhoneNumberSet = common.StringPtrs([]string{phone})
-	request.SmsSdkAppId = common.StringPtr(s.config.AppID)
-	request.SignName = common.StringPtr(s.config.SignName)
-	request.TemplateId = common.StringPtr(s.config.TemplateID)
-	request.TemplateParamSet = common.StringPtrs([]string{code, "5"}) // 验证码和有效期
-	
-	response, err := s.client.SendSms(request)
-	if _, ok := err.(*errors.TencentCloudSDKError); ok {
-		return fmt.Errorf("SMS API error: %v", err)
+func newProvider(name string, cfg *config.SMSConfig) (Provider, error) {
+	switch name {
+	case ProviderTencent:
+		return NewTencentProvider(&cfg.Tencent)
+	case ProviderAliyun:
+		return NewAliyunProvider(&cfg.Aliyun)
+	case ProviderTwilio:
+		return NewTwilioProvider(&cfg.Twilio)
+	case ProviderFile:
+		return NewFileProvider(&cfg.File)
+	default:
+		return nil, errors.New("unknown provider")
+	}
+}
+
+// Init 按配置创建默认短信服务，未配置服务商时不启用短信
+func Init(cfg *config.SMSConfig) error {
+	if cfg.Provider == "" && cfg.Tencent.SecretID == "" {
+		return nil
 	}
+	
+	service, err := NewSMSService(cfg)
 	if err != nil {
 		return err
 	}
+	defaultService = service
 	
-	// 检查发送结果
-	if len(response.Response.SendStatusSet) > 0 {
-		status := response.Response.SendStatusSet[0]
-		if *status.Code != "Ok" {
-			return fmt.Errorf("SMS send failed: %s", *status.Message)
+	outbound.RegisterSender(outbound.ChannelSMS, func(payload []byte) error {
+		var msg Message
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return err
 		}
-	}
-	
+		return service.send(&msg)
+	})
 	return nil
 }
 
-// SendNotificationSMS 发送通知短信
-func (s *SMSService) SendNotificationSMS(phone, message string) error {
-	// 这里可以根据需要实现不同的短信模板
-	// 目前简化处理，使用通用模板
-	request := sms.NewSendSmsRequest()
-	
-	request.PhoneNumberSet = common.StringPtrs([]string{phone})
-	request.SmsSdkAppId = common.StringPtr(s.config.AppID)
-	request.SignName = common.StringPtr(s.config.SignName)
-	// 这里需要配置通知类短信的模板ID
-	request.TemplateId = common.StringPtr("notification_template_id")
-	request.TemplateParamSet = common.StringPtrs([]string{message})
-	
-	response, err := s.client.SendSms(request)
-	if _, ok := err.(*errors.TencentCloudSDKError); ok {
-		return fmt.Errorf("SMS API error: %v", err)
-	}
-	if err != nil {
-		return err
-	}
-	
-	// 检查发送结果
-	if len(response.Response.SendStatusSet) > 0 {
-		status := response.Response.SendStatusSet[0]
-		if *status.Code != "Ok" {
-			return fmt.Errorf("SMS send failed: %s", *status.Message)
+// Default 返回默认短信服务，未启用短信时返回nil
+func Default() *SMSService {
+	return defaultService
+}
+
+// Send 发送短信，启用发送队列时加入队列后立即返回，发送失败由队列重试
+func (s *SMSService) Send(msg *Message) error {
+	if msg.Content == "" {
+		content, err := renderContent(msg)
+		if err != nil {
+			return err
 		}
+		msg.Content = content
 	}
 	
-	return nil
+	if outbound.Enabled() {
+		return outbound.Enqueue(outbound.ChannelSMS, msg.Phone, msg.Type, msg, msg.ExpiresAt)
+	}
+	return s.send(msg)
+}
+
+// send 立即发送，主服务商失败时依次尝试备用服务商
+func (s *SMSService) send(msg *Message) error {
+	return s.deliver(msg, s.providersFor(msg.Phone), func(provider Provider) (string, error) {
+		return provider.Send(msg)
+	})
+}
+
+// SendVerificationCode 发送验证码短信，验证码过期后不再重试发送
+func (s *SMSService) SendVerificationCode(phone, code string, ttl time.Duration, locale string) error {
+	return s.Send(&Message{
+		Phone:  phone,
+		Type:   MessageVerification,
+		Locale: locale,
+		Params: []Param{
+			{Name: "code", Value: code},
+			{Name: "minutes", Value: strconv.Itoa(int(ttl.Minutes()))},
+		},
+		ExpiresAt: time.Now().Add(ttl),
+	})
+}
+
+// SendNotificationSMS 发送通知短信
+func (s *SMSService) SendNotificationSMS(phone, message, locale string) error {
+	return s.Send(&Message{
+		Phone:  phone,
+		Type:   MessageNotification,
+		Locale: locale,
+		Params: []Param{{Name: "content", Value: message}},
+	})
 }
 
-// ValidatePhoneNumber 验证手机号格式
-func ValidatePhoneNumber(phone string) bool {
-	// 简单的中国手机号验证
-	if len(phone) != 11 {
-		return false
+// SendVoiceCode 拨打电话播报验证码，只使用支持语音验证码的服务商
+func (s *SMSService) SendVoiceCode(phone, code string) error {
+	var providers []Provider
+	for _, provider := range s.providersFor(phone) {
+		if _, ok := provider.(VoiceProvider); ok {
+			providers = append(providers, provider)
+		}
 	}
-	
-	// 检查是否以1开头
-	if phone[0] != '1' {
-		return false
+	if len(providers) == 0 {
+		return errors.New("voice code not supported by providers for this number")
 	}
 	
-	// 检查第二位是否为有效数字
-	validSecondDigits := []byte{'3', '4', '5', '6', '7', '8', '9'}
-	secondDigit := phone[1]
+	msg := &Message{Phone: phone, Type: MessageVoiceCode}
+	return s.deliver(msg, providers, func(provider Provider) (string, error) {
+		return provider.(VoiceProvider).SendVoiceCode(phone, code)
+	})
+}
+
+// deliver 依次尝试服务商直到发送成功，并记录最终使用的服务商和发送状态。
+// 记录中不保存短信内容，避免验证码落库
+func (s *SMSService) deliver(msg *Message, providers []Provider, send func(Provider) (string, error)) error {
+	delivery := models.SMSDelivery{
+		Phone:  msg.Phone,
+		Type:   msg.Type,
+		Status: models.SMSStatusPending,
+	}
+	database.DB.Create(&delivery)
 	
-	for _, digit := range validSecondDigits {
-		if secondDigit == digit {
-			return true
+	var failures []string
+	for _, provider := range providers {
+		delivery.Attempts++
+		messageID, err := send(provider)
+		if err != nil {
+			failures = append(failures, provider.Name()+": "+err.Error())
+			continue
 		}
+		
+		database.DB.Model(&delivery).Updates(map[string]interface{}{
+			"provider":   provider.Name(),
+			"message_id": messageID,
+			"status":     models.SMSStatusSent,
+			"attempts":   delivery.Attempts,
+			"error":      strings.Join(failures, "\n"),
+		})
+		return nil
 	}
 	
-	return false
+	database.DB.Model(&delivery).Updates(map[string]interface{}{
+		"status":   models.SMSStatusFailed,
+		"attempts": delivery.Attempts,
+		"error":    strings.Join(failures, "\n"),
+	})
+	return fmt.Errorf("SMS send failed: %s", strings.Join(failures, "; "))
+}
+
+// renderContent 使用短信模板渲染完整内容，模板参数与服务商模板参数相同
+func renderContent(msg *Message) (string, error) {
+	data := make(map[string]interface{}, len(msg.Params))
+	for _, param := range msg.Params {
+		data[param.Name] = param.Value
+	}
+	return templates.RenderSMS(msg.Type, msg.Locale, data)
+}
+
+// templateFor 查找服务商模板，优先使用收件人语言的模板（键为 类型_语言，如verification_en）
+func templateFor(configured map[string]string, msg *Message) string {
+	if id := configured[msg.Type+"_"+templates.Language(msg.Locale)]; id != "" {
+		return id
+	}
+	return configured[msg.Type]
 }
 
-// FormatPhoneNumber 格式化手机号（添加+86前缀）
-func FormatPhoneNumber(phone string) string {
-	if len(phone) == 11 && phone[0] == '1' {
-		return "+86" + phone
+// providersFor 返回号码所属地区配置的服务商，未配置时使用默认顺序
+func (s *SMSService) providersFor(phoneNumber string) []Provider {
+	if providers, ok := s.routes[phone.Region(phoneNumber)]; ok {
+		return providers
 	}
-	return phone
+	return s.providers
 }
@@ -0,0 +1,151 @@
+package sms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	
+	"usercenter/internal/config"
+	
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	tcerr "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
+	tchttp "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/http"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	sms "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms/v20210111"
+)
+
+// TencentProvider 腾讯云短信，语音验证码使用腾讯云语音消息（VMS）
+type TencentProvider struct {
+	client      *sms.Client
+	voiceClient *common.Client
+	config      *config.TencentSMSConfig
+}
+
+func NewTencentProvider(cfg *config.TencentSMSConfig) (*TencentProvider, error) {
+	if cfg.SecretID == "" || cfg.SecretKey == "" || cfg.AppID == "" {
+		return nil, errors.New("secret_id, secret_key and app_id are required")
+	}
+	
+	credential := common.NewCredential(cfg.SecretID, cfg.SecretKey)
+	
+	cpf := profile.NewClientProfile()
+	cpf.HttpProfile.Endpoint = "sms.tencentcloudapi.com"
+	
+	region := cfg.Region
+	if region == "" {
+		region = "ap-beijing"
+	}
+	client, err := sms.NewClient(credential, region, cpf)
+	if err != nil {
+		return nil, err
+	}
+	
+	// 语音验证码（VMS）没有引入单独的SDK，使用通用客户端调用
+	voiceProfile := profile.NewClientProfile()
+	voiceProfile.HttpProfile.Endpoint = "vms.tencentcloudapi.com"
+	voiceClient := common.NewCommonClient(credential, "ap-guangzhou", voiceProfile)
+	
+	return &TencentProvider{
+		client:      client,
+		voiceClient: voiceClient,
+		config:      cfg,
+	}, nil
+}
+
+func (p *TencentProvider) Name() string {
+	return ProviderTencent
+}
+
+// templateID 消息类型对应的模板ID，验证码模板兼容旧的template_id配置
+func (p *TencentProvider) templateID(msg *Message) string {
+	if id := templateFor(p.config.Templates, msg); id != "" {
+		return id
+	}
+	if msg.Type == MessageVerification {
+		return p.config.TemplateID
+	}
+	return ""
+}
+
+// Send 使用模板发送短信，模板参数按顺序传递
+func (p *TencentProvider) Send(msg *Message) (string, error) {
+	templateID := p.templateID(msg)
+	if templateID == "" {
+		return "", fmt.Errorf("no template configured for %s", msg.Type)
+	}
+	
+	params := make([]string, len(msg.Params))
+	for i, param := range msg.Params {
+		params[i] = param.Value
+	}
+	
+	request := sms.NewSendSmsRequest()
+	request.PhoneNumberSet = common.StringPtrs([]string{msg.Phone})
+	request.SmsSdkAppId = common.StringPtr(p.config.AppID)
+	request.SignName = common.StringPtr(p.config.SignName)
+	request.TemplateId = common.StringPtr(templateID)
+	request.TemplateParamSet = common.StringPtrs(params)
+	
+	response, err := p.client.SendSms(request)
+	if _, ok := err.(*tcerr.TencentCloudSDKError); ok {
+		return "", fmt.Errorf("SMS API error: %v", err)
+	}
+	if err != nil {
+		return "", err
+	}
+	
+	// 检查发送结果
+	if len(response.Response.SendStatusSet) == 0 {
+		return "", errors.New("empty send status")
+	}
+	status := response.Response.SendStatusSet[0]
+	if status.Code == nil || *status.Code != "Ok" {
+		message := ""
+		if status.Message != nil {
+			message = *status.Message
+		}
+		return "", fmt.Errorf("SMS send failed: %s", message)
+	}
+	
+	messageID := ""
+	if status.SerialNo != nil {
+		messageID = *status.SerialNo
+	}
+	return messageID, nil
+}
+
+// SendVoiceCode 拨打电话播报验证码
+func (p *TencentProvider) SendVoiceCode(phone, code string) (string, error) {
+	if p.config.VoiceAppID == "" {
+		return "", errors.New("voice code not configured")
+	}
+	
+	request := tchttp.NewCommonRequest("vms", "2020-09-02", "SendCodeVoice")
+	if err := request.SetActionParameters(map[string]interface{}{
+		"CodeMessage":   code,
+		"CalledNumber":  phone,
+		"VoiceSdkAppid": p.config.VoiceAppID,
+		"PlayTimes":     2,
+	}); err != nil {
+		return "", err
+	}
+	
+	response := tchttp.NewCommonResponse()
+	err := p.voiceClient.Send(request, response)
+	if _, ok := err.(*tcerr.TencentCloudSDKError); ok {
+		return "", fmt.Errorf("VMS API error: %v", err)
+	}
+	if err != nil {
+		return "", err
+	}
+	
+	var result struct {
+		Response struct {
+			SendStatus struct {
+				CallId string
+			}
+		}
+	}
+	json.Unmarshal(response.GetBody(), &result)
+	return result.Response.SendStatus.CallId, nil
+}
@@ -0,0 +1,81 @@
+package sms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	
+	"usercenter/internal/config"
+)
+
+// TwilioProvider Twilio短信，Twilio不使用模板，直接发送完整内容
+type TwilioProvider struct {
+	client *http.Client
+	config *config.TwilioSMSConfig
+}
+
+func NewTwilioProvider(cfg *config.TwilioSMSConfig) (*TwilioProvider, error) {
+	if cfg.AccountSID == "" || cfg.AuthToken == "" {
+		return nil, errors.New("account_sid and auth_token are required")
+	}
+	if cfg.From == "" && cfg.MessagingServiceSID == "" {
+		return nil, errors.New("from or messaging_service_sid is required")
+	}
+	
+	return &TwilioProvider{
+		client: &http.Client{Timeout: 10 * time.Second},
+		config: cfg,
+	}, nil
+}
+
+func (p *TwilioProvider) Name() string {
+	return ProviderTwilio
+}
+
+// Send 发送短信，返回Twilio的消息SID
+func (p *TwilioProvider) Send(msg *Message) (string, error) {
+	if msg.Content == "" {
+		return "", fmt.Errorf("no content for %s", msg.Type)
+	}
+	
+	form := url.Values{}
+	form.Set("To", msg.Phone)
+	form.Set("Body", msg.Content)
+	if p.config.MessagingServiceSID != "" {
+		form.Set("MessagingServiceSid", p.config.MessagingServiceSID)
+	} else {
+		form.Set("From", p.config.From)
+	}
+	
+	endpoint := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", p.config.AccountSID)
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(p.config.AccountSID, p.config.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	
+	var result struct {
+		SID     string `json:"sid"`
+		Status  string `json:"status"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("SMS API error: %s", resp.Status)
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("SMS send failed: %d %s", result.Code, result.Message)
+	}
+	return result.SID, nil
+}
@@ -0,0 +1,62 @@
+package templates
+
+// samples 预览模板时使用的示例数据，键为 <类型>/<名称>
+var samples = map[string]map[string]interface{}{
+	"email/verification_code": {
+		"code":    "123456",
+		"action":  "用户注册",
+		"purpose": "register",
+		"minutes": 15,
+	},
+	"email/welcome": {
+		"username": "alice",
+	},
+	"email/password_reset": {
+		"link":    "http://localhost:3000/reset-password?token=example",
+		"minutes": 30,
+	},
+	"email/password_expiry": {
+		"username":   "alice",
+		"expires_at": "2024-01-01 08:00",
+	},
+	"email/notification": {
+		"title":   "系统维护通知",
+		"content": "系统将于今晚22:00至23:00进行维护。",
+	},
+	"email/login_alert": {
+		"username": "alice",
+		"time":     "2024-01-01 08:00:00",
+		"reason":   "新设备",
+		"ip":       "203.0.113.10",
+		"location": "美国 加利福尼亚",
+		"device":   "web Chrome",
+		"link":     "http://localhost:3000/login-alert?token=example",
+	},
+	"email/account_locked": {
+		"username":  "alice",
+		"unlock_at": "2024-01-01 08:30:00",
+		"ip":        "203.0.113.10",
+	},
+	"sms/verification": {
+		"code":    "123456",
+		"minutes": "5",
+	},
+	"sms/notification": {
+		"content": "系统将于今晚22:00至23:00进行维护。",
+	},
+	"sms/login_alert": {
+		"time":   "01-01 08:00",
+		"reason": "新设备",
+		"ip":     "203.0.113.10",
+		"link":   "http://localhost:3000/login-alert?token=example",
+	},
+}
+
+// Sample 返回模板的示例数据，没有示例时返回空数据
+func Sample(kind, name string) map[string]interface{} {
+	data := map[string]interface{}{}
+	for key, value := range samples[kind+"/"+name] {
+		data[key] = value
+	}
+	return data
+}
@@ -0,0 +1,259 @@
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	
+	"usercenter/internal/config"
+)
+
+// 模板类型，模板文件位于 <语言>/<类型>/<名称>.txt
+const (
+	KindEmail = "email"
+	KindSMS   = "sms"
+)
+
+var ErrTemplateNotFound = errors.New("template not found")
+
+//go:embed defaults
+var embedded embed.FS
+
+// builtin 内置模板，目录结构与配置的模板目录相同
+var builtin, _ = fs.Sub(embedded, "defaults")
+
+var settings = config.TemplateConfig{DefaultLocale: "zh-CN"}
+
+// sources 按优先级排列的模板来源，配置的模板目录优先于内置模板
+var sources = []fs.FS{builtin}
+
+// Init 设置模板目录和默认语言
+func Init(cfg *config.TemplateConfig) {
+	settings = *cfg
+	settings.DefaultLocale = NormalizeLocale(settings.DefaultLocale)
+	if settings.DefaultLocale == "" {
+		settings.DefaultLocale = "zh-CN"
+	}
+	
+	sources = []fs.FS{builtin}
+	if cfg.Dir != "" {
+		sources = []fs.FS{os.DirFS(cfg.Dir), builtin}
+	}
+}
+
+// Email 渲染后的邮件，Text为纯文本正文，没有HTML模板时HTML为空
+type Email struct {
+	Locale  string `json:"locale"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
+
+// RenderEmail 渲染邮件模板。<名称>.txt使用text/template，需用{{define "subject"}}定义邮件标题；
+// <名称>.html使用html/template，在{{define "content"}}中编写正文，由同目录的layout.html套用统一的页面结构
+func RenderEmail(name, locale string, data interface{}) (*Email, error) {
+	locale, err := Resolve(KindEmail, name, locale)
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Join(locale, KindEmail)
+	
+	source, err := readFile(path.Join(dir, name+".txt"))
+	if err != nil {
+		return nil, err
+	}
+	text, err := texttemplate.New(name).Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	if text.Lookup("subject") == nil {
+		return nil, errors.New("template " + name + " has no subject")
+	}
+	
+	result := &Email{Locale: locale}
+	if result.Subject, err = executeText(text.Lookup("subject"), data); err != nil {
+		return nil, err
+	}
+	if result.Text, err = executeText(text, data); err != nil {
+		return nil, err
+	}
+	
+	source, err = readFile(path.Join(dir, name+".html"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	layout, err := readFile(path.Join(dir, "layout.html"))
+	if errors.Is(err, fs.ErrNotExist) {
+		layout = `{{template "content" .}}`
+	} else if err != nil {
+		return nil, err
+	}
+	
+	html, err := htmltemplate.New("layout").Parse(layout)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := html.New(name).Parse(source); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := html.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return nil, err
+	}
+	result.HTML = buf.String()
+	return result, nil
+}
+
+// RenderSMS 渲染短信模板，模板使用text/template
+func RenderSMS(name, locale string, data interface{}) (string, error) {
+	locale, err := Resolve(KindSMS, name, locale)
+	if err != nil {
+		return "", err
+	}
+	
+	source, err := readFile(path.Join(locale, KindSMS, name+".txt"))
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := texttemplate.New(name).Parse(source)
+	if err != nil {
+		return "", err
+	}
+	return executeText(tmpl, data)
+}
+
+// Resolve 返回模板实际使用的语言：依次尝试指定语言、其主语言（如en-US对应en）和默认语言
+func Resolve(kind, name, locale string) (string, error) {
+	for _, candidate := range localeCandidates(locale) {
+		if _, err := readFile(path.Join(candidate, kind, name+".txt")); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", ErrTemplateNotFound
+}
+
+// Info 模板及其已有的语言版本
+type Info struct {
+	Kind    string   `json:"kind"`
+	Name    string   `json:"name"`
+	Locales []string `json:"locales"`
+}
+
+// List 列出内置和模板目录中的所有模板
+func List() []Info {
+	locales := map[string]map[string]bool{}
+	for _, source := range sources {
+		for _, kind := range []string{KindEmail, KindSMS} {
+			files, _ := fs.Glob(source, "*/"+kind+"/*.txt")
+			for _, file := range files {
+				key := kind + "/" + strings.TrimSuffix(path.Base(file), ".txt")
+				if locales[key] == nil {
+					locales[key] = map[string]bool{}
+				}
+				locales[key][strings.SplitN(file, "/", 2)[0]] = true
+			}
+		}
+	}
+	
+	result := make([]Info, 0, len(locales))
+	for key, set := range locales {
+		parts := strings.SplitN(key, "/", 2)
+		info := Info{Kind: parts[0], Name: parts[1]}
+		for locale := range set {
+			info.Locales = append(info.Locales, locale)
+		}
+		sort.Strings(info.Locales)
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Kind != result[j].Kind {
+			return result[i].Kind < result[j].Kind
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// NormalizeLocale 规范化语言代码，接受Accept-Language请求头，取其中第一个语言，
+// 如 "en_us" 和 "en-US,en;q=0.9" 都返回 "en-US"
+func NormalizeLocale(locale string) string {
+	locale = strings.SplitN(locale, ",", 2)[0]
+	locale = strings.SplitN(locale, ";", 2)[0]
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	if locale == "" || locale == "*" {
+		return ""
+	}
+	
+	parts := strings.Split(locale, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		switch len(parts[i]) {
+		case 2:
+			parts[i] = strings.ToUpper(parts[i])
+		case 4:
+			parts[i] = strings.ToUpper(parts[i][:1]) + strings.ToLower(parts[i][1:])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// Language 返回语言代码中的主语言，如zh-CN返回zh，为空时使用默认语言
+func Language(locale string) string {
+	locale = NormalizeLocale(locale)
+	if locale == "" {
+		locale = settings.DefaultLocale
+	}
+	return strings.SplitN(locale, "-", 2)[0]
+}
+
+// localeCandidates 按匹配优先级排列的语言，如zh-Hans-CN依次为zh-Hans-CN、zh-Hans、zh，最后是默认语言
+func localeCandidates(locale string) []string {
+	var candidates []string
+	seen := map[string]bool{}
+	for _, value := range []string{NormalizeLocale(locale), settings.DefaultLocale} {
+		for value != "" {
+			if !seen[value] {
+				seen[value] = true
+				candidates = append(candidates, value)
+			}
+			i := strings.LastIndex(value, "-")
+			if i < 0 {
+				break
+			}
+			value = value[:i]
+		}
+	}
+	return candidates
+}
+
+// readFile 按优先级从各模板来源读取文件
+func readFile(name string) (string, error) {
+	for _, source := range sources {
+		data, err := fs.ReadFile(source, name)
+		if err == nil {
+			return string(data), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", fs.ErrNotExist
+}
+
+func executeText(tmpl *texttemplate.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,186 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	
+	"usercenter/internal/config"
+)
+
+// useConfig 按配置初始化模板，测试结束后恢复
+func useConfig(t *testing.T, cfg config.TemplateConfig) {
+	t.Helper()
+	prevSettings, prevSources := settings, sources
+	t.Cleanup(func() { settings, sources = prevSettings, prevSources })
+	Init(&cfg)
+}
+
+// writeTemplate 在模板目录中写入模板文件
+func writeTemplate(t *testing.T, dir, name, content string) {
+	t.Helper()
+	file := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{locale: "en", want: "en"},
+		{locale: "EN", want: "en"},
+		{locale: "en_us", want: "en-US"},
+		{locale: "en-US,en;q=0.9", want: "en-US"},
+		{locale: "zh-hans-cn", want: "zh-Hans-CN"},
+		{locale: "zh;q=0.8", want: "zh"},
+		{locale: " fr-ca ", want: "fr-CA"},
+		{locale: "*", want: ""},
+		{locale: "", want: ""},
+	}
+	
+	for _, tt := range tests {
+		if got := NormalizeLocale(tt.locale); got != tt.want {
+			t.Errorf("NormalizeLocale(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	useConfig(t, config.TemplateConfig{DefaultLocale: "zh-cn"})
+	
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{locale: "en", want: "en"},
+		{locale: "en-US", want: "en"},
+		{locale: "en-GB,en;q=0.9", want: "en"},
+		{locale: "zh", want: "zh-CN"},
+		{locale: "zh-CN", want: "zh-CN"},
+		{locale: "fr", want: "zh-CN"},
+		{locale: "", want: "zh-CN"},
+	}
+	
+	for _, tt := range tests {
+		got, err := Resolve(KindEmail, "verification_code", tt.locale)
+		if err != nil {
+			t.Fatalf("Resolve(%q) error = %v", tt.locale, err)
+		}
+		if got != tt.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+	
+	if _, err := Resolve(KindEmail, "missing", "en"); err != ErrTemplateNotFound {
+		t.Errorf("Resolve(missing) error = %v, want ErrTemplateNotFound", err)
+	}
+}
+
+func TestRenderEmailEscaping(t *testing.T) {
+	useConfig(t, config.TemplateConfig{DefaultLocale: "en"})
+	
+	email, err := RenderEmail("notification", "en", map[string]interface{}{
+		"title":   "Tom & Jerry",
+		"content": `<script>alert("x")</script>`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	tests := []struct {
+		name    string
+		got     string
+		want    string
+		notWant string
+	}{
+		{name: "标题不转义", got: email.Subject, want: "Tom & Jerry"},
+		{name: "纯文本不转义", got: email.Text, want: `<script>alert("x")</script>`},
+		{name: "HTML转义正文", got: email.HTML, want: "&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;", notWant: "<script>"},
+		{name: "HTML转义标题", got: email.HTML, want: "Tom &amp; Jerry"},
+		{name: "HTML套用布局", got: email.HTML, want: "<!DOCTYPE html>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(tt.got, tt.want) {
+				t.Errorf("%q does not contain %q", tt.got, tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(tt.got, tt.notWant) {
+				t.Errorf("%q contains %q", tt.got, tt.notWant)
+			}
+		})
+	}
+}
+
+func TestRenderBuiltinSamples(t *testing.T) {
+	useConfig(t, config.TemplateConfig{DefaultLocale: "zh-CN"})
+	
+	for _, info := range List() {
+		for _, locale := range info.Locales {
+			t.Run(info.Kind+"/"+info.Name+"/"+locale, func(t *testing.T) {
+				data := Sample(info.Kind, info.Name)
+				if info.Kind == KindSMS {
+					text, err := RenderSMS(info.Name, locale, data)
+					if err != nil || text == "" {
+						t.Fatalf("RenderSMS() = %q, %v", text, err)
+					}
+					return
+				}
+				
+				email, err := RenderEmail(info.Name, locale, data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if email.Locale != locale || email.Subject == "" || email.Text == "" {
+					t.Errorf("RenderEmail() = %+v", email)
+				}
+				if strings.Contains(email.Text, "<no value>") || strings.Contains(email.HTML, "<no value>") {
+					t.Errorf("sample data is missing a field: %+v", email)
+				}
+			})
+		}
+	}
+}
+
+func TestTemplateDirOverridesBuiltin(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "en/email/notification.txt", `{{define "subject"}}Custom: {{.title}}{{end -}}`+"\n{{.content}}")
+	writeTemplate(t, dir, "en/email/no_subject.txt", "{{.content}}")
+	writeTemplate(t, dir, "en/email/plain.txt", `{{define "subject"}}Plain{{end -}}`+"\nbody")
+	writeTemplate(t, dir, "fr/sms/verification.txt", "Code {{.code}}")
+	useConfig(t, config.TemplateConfig{Dir: dir, DefaultLocale: "en"})
+	
+	email, err := RenderEmail("notification", "en", map[string]interface{}{"title": "hello", "content": "body"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.Subject != "Custom: hello" {
+		t.Errorf("Subject = %q, want the template directory version", email.Subject)
+	}
+	// 模板目录中没有HTML版本时使用内置的HTML模板
+	if !strings.Contains(email.HTML, "<h2>hello</h2>") {
+		t.Errorf("HTML = %q, want builtin HTML", email.HTML)
+	}
+	
+	if email, err := RenderEmail("plain", "en", nil); err != nil || email.HTML != "" || email.Text != "body" {
+		t.Errorf("RenderEmail(plain) = %+v, %v, want text only", email, err)
+	}
+	if _, err := RenderEmail("no_subject", "en", map[string]interface{}{"content": "x"}); err == nil {
+		t.Error("RenderEmail(no_subject) error = nil, want missing subject error")
+	}
+	
+	sms, err := RenderSMS("verification", "fr-FR", map[string]interface{}{"code": "123456"})
+	if err != nil || sms != "Code 123456" {
+		t.Errorf("RenderSMS(fr-FR) = %q, %v", sms, err)
+	}
+	sms, err = RenderSMS("verification", "de", map[string]interface{}{"code": "123456", "minutes": 5})
+	if err != nil || !strings.HasPrefix(sms, "Your verification code is 123456") {
+		t.Errorf("RenderSMS(de) = %q, %v, want the default locale", sms, err)
+	}
+}
@@ -0,0 +1,74 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 默认参数与主流身份验证器（Google Authenticator等）保持一致
+const (
+	Period = 30
+	Digits = 6
+	Skew   = 1
+)
+
+// Validate 校验TOTP动态码（RFC 6238），允许前后各Skew个时间步的偏差
+func Validate(secret, code string) bool {
+	return ValidateAt(secret, code, time.Now())
+}
+
+// ValidateAt 校验指定时间点的TOTP动态码
+func ValidateAt(secret, code string, t time.Time) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return false
+	}
+	
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return false
+	}
+	
+	counter := t.Unix() / Period
+	for i := -Skew; i <= Skew; i++ {
+		expected := generate(key, uint64(counter+int64(i)))
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return true
+		}
+	}
+	
+	return false
+}
+
+// decodeSecret 解码Base32格式的密钥（忽略空格、大小写和填充）
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+}
+
+// generate 按HOTP算法（RFC 4226）计算动态码
+func generate(key []byte, counter uint64) string {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, counter)
+	
+	mac := hmac.New(sha1.New, key)
+	mac.Write(buf)
+	sum := mac.Sum(nil)
+	
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
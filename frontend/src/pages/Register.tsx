@@ -1,9 +1,10 @@
-import React, { useState } from 'react';
+import React, { useState, useEffect } from 'react';
 import { Link, useNavigate } from 'react-router-dom';
-import { Form, Input, Button, Card, Tabs, message, Row, Col, Divider, Checkbox } from 'antd';
+import { Form, Input, Button, Card, Tabs, message, Row, Col, Divider, Checkbox, Image } from 'antd';
 import { UserOutlined, MailOutlined, PhoneOutlined, LockOutlined, SafetyOutlined } from '@ant-design/icons';
 import { useAppDispatch } from '@/store';
-import { register, sendVerificationCode } from '@/services/auth';
+import { register, sendVerificationCode, getCaptcha } from '@/services/auth';
+import { CaptchaResponse, RegisterRequest } from '@/types';
 import './Register.css';
 
 const { TabPane } = Tabs;
@@ -14,6 +15,7 @@ interface RegisterFormData {
   phone: string;
   password: string;
   confirmPassword: string;
+  captchaCode: string;
   verificationCode: string;
   agreement: boolean;
 }
@@ -26,6 +28,22 @@ const Register: React.FC = () => {
   const [sendingCode, setSendingCode] = useState(false);
   const [countdown, setCountdown] = useState(0);
   const [registerType, setRegisterType] = useState<'email' | 'phone'>('email');
+  const [captcha, setCaptcha] = useState<CaptchaResponse | null>(null);
+
+  // 获取图形验证码，发送验证码前需要通过，每个图形验证码只能使用一次
+  const loadCaptcha = async () => {
+    try {
+      const captchaData = await getCaptcha();
+      setCaptcha(captchaData);
+      form.setFieldsValue({ captchaCode: '' });
+    } catch (error) {
+      console.error('获取图形验证码失败:', error);
+    }
+  };
+
+  useEffect(() => {
+    loadCaptcha();
+  }, []);
 
   // 发送验证码
   const handleSendCode = async () => {
@@ -39,11 +57,19 @@ const Register: React.FC = () => {
         return;
       }
 
+      const captchaCode = form.getFieldValue('captchaCode');
+      if (!captcha || !captchaCode) {
+        message.error('请输入图形验证码');
+        return;
+      }
+
       setSendingCode(true);
       await sendVerificationCode({
         type: registerType,
         target,
-        purpose: 'register'
+        purpose: 'register',
+        captcha_id: captcha.captcha_id,
+        captcha_code: captchaCode
       });
       
       message.success('验证码已发送');
@@ -61,9 +87,13 @@ const Register: React.FC = () => {
       }, 1000);
       
     } catch (error: any) {
-      message.error(error.message || '发送验证码失败');
+      // 图形验证码错误（40303）的提示已由请求拦截器显示
+      if (error.response?.data?.code !== 40303) {
+        message.error(error.message || '发送验证码失败');
+      }
     } finally {
       setSendingCode(false);
+      loadCaptcha();
     }
   };
 
@@ -72,13 +102,15 @@ const Register: React.FC = () => {
     try {
       setLoading(true);
       
-      const registerData = {
+      const registerData: RegisterRequest = {
         username: values.username,
-        email: registerType === 'email' ? values.email : '',
-        phone: registerType === 'phone' ? values.phone : '',
+        email: registerType === 'email' ? values.email : undefined,
+        phone: registerType === 'phone' ? values.phone : undefined,
         password: values.password,
-        verificationCode: values.verificationCode,
-        registerType
+        email_code: registerType === 'email' ? values.verificationCode : undefined,
+        sms_code: registerType === 'phone' ? values.verificationCode : undefined,
+        captcha_id: captcha?.captcha_id || '',
+        captcha_code: values.captchaCode
       };
 
       const response = await register(registerData);
@@ -88,6 +120,7 @@ const Register: React.FC = () => {
       
     } catch (error: any) {
       message.error(error.message || '注册失败');
+      loadCaptcha();
     } finally {
       setLoading(false);
     }
@@ -123,6 +156,37 @@ const Register: React.FC = () => {
     }
   };
 
+  const renderCaptcha = () => (
+    <Form.Item>
+      <Row gutter={8}>
+        <Col span={16}>
+          <Form.Item
+            name="captchaCode"
+            noStyle
+            rules={[{ required: true, message: '请输入图形验证码' }]}
+          >
+            <Input
+              prefix={<SafetyOutlined />}
+              placeholder="图形验证码"
+              size="large"
+            />
+          </Form.Item>
+        </Col>
+        <Col span={8}>
+          {captcha && (
+            <Image
+              src={captcha.captcha_img}
+              alt="图形验证码"
+              style={{ width: '100%', height: 40, cursor: 'pointer', borderRadius: 4 }}
+              preview={false}
+              onClick={loadCaptcha}
+            />
+          )}
+        </Col>
+      </Row>
+    </Form.Item>
+  );
+
   const renderEmailRegister = () => (
     <>
       <Form.Item
@@ -139,6 +203,8 @@ const Register: React.FC = () => {
         />
       </Form.Item>
       
+      {renderCaptcha()}
+      
       <Form.Item
         name="verificationCode"
         rules={[{ required: true, message: '请输入邮箱验证码' }]}
@@ -183,6 +249,8 @@ const Register: React.FC = () => {
         />
       </Form.Item>
       
+      {renderCaptcha()}
+      
       <Form.Item
         name="verificationCode"
         rules={[{ required: true, message: '请输入短信验证码' }]}
@@ -16,23 +16,16 @@ export const authApi = {
 
   // 发送邮箱验证码
   sendEmailCode: (email: string, purpose: string): Promise<void> => {
-    return post('/auth/send-email-code', { email, purpose });
+    return post('/auth/verification/send', { type: 'email', target: email, purpose });
   },
 
   // 发送短信验证码
   sendSMSCode: (phone: string, purpose: string): Promise<void> => {
-    return post('/auth/send-sms-code', { phone, purpose });
+    return post('/auth/verification/send', { type: 'phone', target: phone, purpose });
   },
 
   // 注册
-  register: (data: {
-    username: string;
-    email?: string;
-    phone?: string;
-    password: string;
-    verificationCode: string;
-    registerType: 'email' | 'phone';
-  }): Promise<any> => {
+  register: (data: RegisterRequest): Promise<any> => {
     return post('/auth/register', data);
   },
 
@@ -40,9 +33,12 @@ export const authApi = {
   sendVerificationCode: (data: {
     type: 'email' | 'phone';
     target: string;
-    purpose: 'register' | 'login' | 'reset_password';
+    purpose: 'register' | 'reset_password' | 'bind_email' | 'bind_phone';
+    channel?: 'sms' | 'voice';
+    captcha_id?: string;
+    captcha_code?: string;
   }): Promise<any> => {
-    return post('/auth/send-verification-code', data);
+    return post('/auth/verification/send', data);
   },
 
   // 用户登录
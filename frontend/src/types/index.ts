@@ -9,11 +9,13 @@ export interface User {
   gender: number;
   birthday?: string;
   bio?: string;
+  language?: string;
   status: number;
   email_verified: boolean;
   phone_verified: boolean;
   last_login_at?: string;
   last_login_ip?: string;
+  last_login_location?: GeoLocation;
   created_at: string;
   updated_at: string;
   roles: Role[];
@@ -55,9 +57,20 @@ export interface UserDevice {
   user_agent: string;
   last_active: string;
   is_active: boolean;
+  location?: GeoLocation;
   created_at: string;
 }
 
+// IP地理位置
+export interface GeoLocation {
+  country?: string;
+  country_code?: string;
+  region?: string;
+  city?: string;
+  asn?: number;
+  as_org?: string;
+}
+
 // 用户日志
 export interface UserLog {
   id: string;
@@ -67,6 +80,7 @@ export interface UserLog {
   user_agent: string;
   details: string;
   status: number;
+  location?: GeoLocation;
   created_at: string;
 }
 
@@ -125,6 +139,7 @@ export interface RegisterRequest {
   sms_code?: string;
   captcha_id: string;
   captcha_code: string;
+  language?: string;
 }
 
 // 更新个人资料请求
@@ -133,6 +148,7 @@ export interface UpdateProfileRequest {
   gender?: number;
   birthday?: string;
   bio?: string;
+  language?: string;
 }
 
 // 修改密码请求
@@ -156,7 +172,10 @@ export interface BindPhoneRequest {
 // 验证码响应
 export interface CaptchaResponse {
   captcha_id: string;
+  captcha_type: 'digit' | 'string' | 'math' | 'audio' | 'slider';
   captcha_img: string;
+  captcha_piece?: string;
+  captcha_piece_y?: number;
 }
 
 // 分页查询参数